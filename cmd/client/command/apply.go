@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+type (
+	applyRequest struct {
+		Source  string                   `json:"source,omitempty"`
+		Prune   bool                     `json:"prune,omitempty"`
+		Objects []map[string]interface{} `json:"objects"`
+	}
+
+	applyResult struct {
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Action string `json:"action"`
+	}
+)
+
+// ApplyCmd defines apply command.
+func ApplyCmd() *cobra.Command {
+	var files []string
+	var source string
+	var recursive, prune, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create or update objects from yaml files or directories",
+		Long: `Create or update objects from yaml files or directories.
+
+Files could contain multiple yaml documents, objects are created if they don't
+exist, or updated otherwise. All changes are committed at once, and objects are
+started in the order of dependency, e.g. service registries before pipelines,
+and pipelines before HTTP servers.
+
+If --source is specified, the names of the applied objects are saved in the
+server as an apply set, and with --prune, objects previously applied from the
+same source but not in the files any more are deleted.`,
+		Example: `egctl apply -f pipeline.yaml
+egctl apply -f ./config/ -R
+egctl apply -f ./config/ --source demo-app --prune
+cat pipeline.yaml | egctl apply`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if prune && source == "" {
+				return errors.New("--prune requires --source")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			req := &applyRequest{
+				Source:  source,
+				Prune:   prune,
+				Objects: readApplyObjects(files, recursive, cmd),
+			}
+			// pruning all objects of the source is allowed.
+			if len(req.Objects) == 0 && !prune {
+				ExitWithErrorf("%s failed: no objects found", cmd.Short)
+			}
+
+			url := makeURL(applyURL)
+			if dryRun {
+				url += "?dryRun=true"
+			}
+			body := mustRequest(http.MethodPost, url, codectool.MustMarshalJSON(req), cmd)
+
+			var results []*applyResult
+			codectool.MustUnmarshal(body, &results)
+			for _, r := range results {
+				if dryRun {
+					fmt.Printf("%s %s %s (dry run)\n", r.Kind, r.Name, r.Action)
+				} else {
+					fmt.Printf("%s %s %s\n", r.Kind, r.Name, r.Action)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Yaml files or directories specifying the objects, read from stdin if not specified.")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "Process the directories recursively.")
+	cmd.Flags().StringVar(&source, "source", "", "The name of the apply set to track the applied objects.")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Delete the objects previously applied from the same source but not in the files.")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the objects to be changed.")
+
+	return cmd
+}
+
+// applyFiles returns the yaml and json files in the paths, files in a
+// directory are sorted by name.
+func applyFiles(paths []string, recursive bool) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		var dirFiles []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != path && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+				dirFiles = append(dirFiles, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+func readApplyObjects(paths []string, recursive bool, cmd *cobra.Command) []map[string]interface{} {
+	files, err := applyFiles(paths, recursive)
+	if err != nil {
+		ExitWithErrorf("%s failed: %v", cmd.Short, err)
+	}
+
+	objects := []map[string]interface{}{}
+	read := func(source string, visitor YAMLVisitor) {
+		defer visitor.Close()
+
+		err := visitor.Visit(func(yamlDoc []byte) error {
+			var obj map[string]interface{}
+			if err := codectool.Unmarshal(yamlDoc, &obj); err != nil {
+				return fmt.Errorf("%s: %v", source, err)
+			}
+			// skip empty documents.
+			if len(obj) == 0 {
+				return nil
+			}
+			if obj["kind"] == nil || obj["name"] == nil {
+				return fmt.Errorf("%s: kind or name is empty: %s", source, yamlDoc)
+			}
+			objects = append(objects, obj)
+			return nil
+		})
+		if err != nil {
+			ExitWithError(err)
+		}
+	}
+
+	if len(files) == 0 && len(paths) == 0 {
+		read("stdin", buildYAMLVisitor("", cmd))
+	}
+	for _, f := range files {
+		read(f, buildYAMLVisitor(f, cmd))
+	}
+
+	return objects
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package command
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// AuditCmd defines audit command.
+func AuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "View the audit log of configuration changes",
+	}
+
+	cmd.AddCommand(listAuditCmd())
+	return cmd
+}
+
+func listAuditCmd() *cobra.Command {
+	var resource, kind, name, principal, member, since string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit records, the newest first",
+		Example: `egctl audit list
+egctl audit list --kind HTTPServer --name http-server-example
+egctl audit list --principal admin --since 24h --limit 10`,
+		Run: func(cmd *cobra.Command, args []string) {
+			q := url.Values{}
+			for k, v := range map[string]string{
+				"resource":  resource,
+				"kind":      kind,
+				"name":      name,
+				"principal": principal,
+				"member":    member,
+				"since":     since,
+			} {
+				if v != "" {
+					q.Set(k, v)
+				}
+			}
+			if limit > 0 {
+				q.Set("limit", strconv.Itoa(limit))
+			}
+
+			u := makeURL(auditURL)
+			if len(q) > 0 {
+				u += "?" + q.Encode()
+			}
+			handleRequest(http.MethodGet, u, nil, cmd)
+		},
+	}
+
+	cmd.Flags().StringVar(&resource, "resource", "", "Filter by resource type(object, customdatakind, customdata).")
+	cmd.Flags().StringVar(&kind, "kind", "", "Filter by object kind or custom data kind.")
+	cmd.Flags().StringVar(&name, "name", "", "Filter by object name or custom data ID.")
+	cmd.Flags().StringVar(&principal, "principal", "", "Filter by the authenticated principal.")
+	cmd.Flags().StringVar(&member, "member", "", "Filter by the member which handled the request.")
+	cmd.Flags().StringVar(&since, "since", "", "Only list records newer than a duration(e.g. 24h) or an RFC3339 time.")
+	cmd.Flags().IntVar(&limit, "limit", 0, "The max number of records to list, default is 100.")
+
+	return cmd
+}
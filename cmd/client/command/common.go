@@ -19,9 +19,14 @@ package command
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/megaease/easegress/pkg/util/codectool"
 	"github.com/spf13/cobra"
@@ -32,6 +37,18 @@ type (
 	GlobalFlags struct {
 		Server       string
 		OutputFormat string
+
+		// Token is the bearer token, User is the user name and password
+		// of basic auth in the format of "name:password".
+		Token string
+		User  string
+
+		// TLS options, the server is accessed over HTTPS if any of them
+		// is specified or the server address starts with "https://".
+		CAFile             string
+		CertFile           string
+		KeyFile            string
+		InsecureSkipVerify bool
 	}
 
 	// APIErr is the standard return of error.
@@ -56,6 +73,14 @@ const (
 	objectsURL     = apiURL + "/objects"
 	objectURL      = apiURL + "/objects/%s"
 
+	objectRevisionsURL = apiURL + "/objects/%s/revisions"
+	objectRevisionURL  = apiURL + "/objects/%s/revisions/%d"
+	objectRollbackURL  = apiURL + "/objects/%s/rollback?revision=%d"
+
+	applyURL = apiURL + "/apply"
+
+	watchURL = apiURL + "/watch"
+
 	statusObjectURL  = apiURL + "/status/objects/%s"
 	statusObjectsURL = apiURL + "/status/objects"
 
@@ -71,6 +96,8 @@ const (
 	profileStartURL = apiURL + "/profile/start/%s"
 	profileStopURL  = apiURL + "/profile/stop"
 
+	auditURL = apiURL + "/audit"
+
 	// MeshTenantsURL is the mesh tenant prefix.
 	MeshTenantsURL = apiURL + "/mesh/tenants"
 
@@ -115,7 +142,63 @@ const (
 )
 
 func makeURL(urlTemplate string, a ...interface{}) string {
-	return "http://" + CommandlineGlobalFlags.Server + fmt.Sprintf(urlTemplate, a...)
+	return serverURL() + fmt.Sprintf(urlTemplate, a...)
+}
+
+func (flags *GlobalFlags) useTLS() bool {
+	return flags.CAFile != "" || flags.CertFile != "" || flags.InsecureSkipVerify
+}
+
+func serverURL() string {
+	flags := &CommandlineGlobalFlags
+	server := strings.TrimSuffix(flags.Server, "/")
+	if strings.HasPrefix(server, "http://") || strings.HasPrefix(server, "https://") {
+		return server
+	}
+	if flags.useTLS() {
+		return "https://" + server
+	}
+	return "http://" + server
+}
+
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// getHTTPClient returns the HTTP client configured by the global flags.
+func getHTTPClient() *http.Client {
+	httpClientOnce.Do(func() {
+		flags := &CommandlineGlobalFlags
+		if !flags.useTLS() {
+			httpClient = http.DefaultClient
+			return
+		}
+
+		config := &tls.Config{InsecureSkipVerify: flags.InsecureSkipVerify}
+		if flags.CAFile != "" {
+			pem, err := os.ReadFile(flags.CAFile)
+			if err != nil {
+				ExitWithErrorf("read CA file %s failed: %v", flags.CAFile, err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				ExitWithErrorf("no valid certificate in CA file %s", flags.CAFile)
+			}
+		}
+		if flags.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(flags.CertFile, flags.KeyFile)
+			if err != nil {
+				ExitWithErrorf("load client certificate failed: %v", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		httpClient = &http.Client{Transport: transport}
+	})
+	return httpClient
 }
 
 func successfulStatusCode(code int) bool {
@@ -123,6 +206,25 @@ func successfulStatusCode(code int) bool {
 }
 
 func handleRequest(httpMethod string, url string, yamlBody []byte, cmd *cobra.Command) {
+	body := mustRequest(httpMethod, url, yamlBody, cmd)
+	if len(body) != 0 {
+		printBody(body)
+	}
+}
+
+// mustRequest sends the request and returns the response body, it exits
+// if the request fails.
+func mustRequest(httpMethod string, url string, yamlBody []byte, cmd *cobra.Command) []byte {
+	statusCode, body := sendRequest(httpMethod, url, yamlBody, cmd)
+	if !successfulStatusCode(statusCode) {
+		exitWithAPIError(body)
+	}
+	return body
+}
+
+// sendRequest sends the request and returns the status code and body of
+// the response, it exits if the request could not be sent.
+func sendRequest(httpMethod string, url string, yamlBody []byte, cmd *cobra.Command) (int, []byte) {
 	var jsonBody []byte
 	if yamlBody != nil {
 		var err error
@@ -137,7 +239,9 @@ func handleRequest(httpMethod string, url string, yamlBody []byte, cmd *cobra.Co
 		ExitWithError(err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	setCredential(req)
+
+	resp, err := getHTTPClient().Do(req)
 	if err != nil {
 		ExitWithErrorf("%s failed: %v", cmd.Short, err)
 	}
@@ -148,18 +252,31 @@ func handleRequest(httpMethod string, url string, yamlBody []byte, cmd *cobra.Co
 		ExitWithErrorf("%s failed: %v", cmd.Short, err)
 	}
 
-	if !successfulStatusCode(resp.StatusCode) {
-		msg := string(body)
-		apiErr := &APIErr{}
-		err = codectool.Unmarshal(body, apiErr)
-		if err == nil {
-			msg = apiErr.Message
-		}
-		ExitWithErrorf("%d: %s", apiErr.Code, msg)
+	return resp.StatusCode, body
+}
+
+func exitWithAPIError(body []byte) {
+	msg := string(body)
+	apiErr := &APIErr{}
+	err := codectool.Unmarshal(body, apiErr)
+	if err == nil {
+		msg = apiErr.Message
 	}
+	ExitWithErrorf("%d: %s", apiErr.Code, msg)
+}
 
-	if len(body) != 0 {
-		printBody(body)
+// setCredential sets the credential of the request according to the
+// global flags, the token takes precedence over the user.
+func setCredential(req *http.Request) {
+	flags := &CommandlineGlobalFlags
+	if flags.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+flags.Token)
+		return
+	}
+
+	if flags.User != "" {
+		name, password, _ := strings.Cut(flags.User, ":")
+		req.SetBasicAuth(name, password)
 	}
 }
 
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+// specChange is a change of a field of a spec.
+type specChange struct {
+	// op is '+' for added fields, '-' for removed fields and '~' for
+	// modified fields.
+	op       byte
+	path     string
+	oldValue interface{}
+	newValue interface{}
+}
+
+func diffObjectCmd() *cobra.Command {
+	var specFile string
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Diff objects in a yaml file or stdin against the live objects",
+		Long: `Diff objects in a yaml file or stdin against the live objects.
+
+The objects are validated by the server in dry run mode, so the default values
+are filled and nothing is changed. Added fields are prefixed with '+', removed
+fields with '-' and modified fields with '~'.`,
+		Example: "egctl object diff -f pipeline.yaml",
+		Run: func(cmd *cobra.Command, args []string) {
+			visitor := buildSpecVisitor(specFile, cmd)
+			visitor.Visit(func(s *spec) error {
+				diffObject(s, cmd)
+				return nil
+			})
+			visitor.Close()
+		},
+	}
+
+	cmd.Flags().StringVarP(&specFile, "file", "f", "", "A yaml file specifying the objects.")
+
+	return cmd
+}
+
+func diffObject(s *spec, cmd *cobra.Command) {
+	var live map[string]interface{}
+	code, body := sendRequest(http.MethodGet, makeURL(objectURL, s.Name), nil, cmd)
+	switch {
+	case code == http.StatusNotFound:
+	case successfulStatusCode(code):
+		codectool.MustUnmarshal(body, &live)
+	default:
+		exitWithAPIError(body)
+	}
+
+	// validate the object and fill the default values.
+	if live == nil {
+		body = mustRequest(http.MethodPost, makeURL(objectsURL+"?dryRun=true"), []byte(s.doc), cmd)
+	} else {
+		body = mustRequest(http.MethodPut, makeURL(objectURL+"?dryRun=true", s.Name), []byte(s.doc), cmd)
+	}
+	var desired map[string]interface{}
+	codectool.MustUnmarshal(body, &desired)
+
+	header := fmt.Sprintf("%s %s", s.Kind, s.Name)
+	if live == nil {
+		color.New(color.FgGreen).Printf("+ %s (new object)\n", header)
+		return
+	}
+
+	changes := diffSpec("", live, desired, nil)
+	if len(changes) == 0 {
+		fmt.Printf("  %s (no changes)\n", header)
+		return
+	}
+
+	fmt.Printf("~ %s\n", header)
+	for _, c := range changes {
+		c.print()
+	}
+}
+
+// diffSpec compares the old and new value recursively, and returns the
+// changes of the leaf fields.
+func diffSpec(path string, oldValue, newValue interface{}, changes []*specChange) []*specChange {
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make([]string, 0, len(oldMap)+len(newMap))
+		for k := range oldMap {
+			keys = append(keys, k)
+		}
+		for k := range newMap {
+			if _, ok := oldMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			ov, oldExists := oldMap[k]
+			nv, newExists := newMap[k]
+			switch {
+			case !oldExists:
+				changes = append(changes, &specChange{op: '+', path: p, newValue: nv})
+			case !newExists:
+				changes = append(changes, &specChange{op: '-', path: p, oldValue: ov})
+			default:
+				changes = diffSpec(p, ov, nv, changes)
+			}
+		}
+		return changes
+	}
+
+	oldSlice, oldIsSlice := oldValue.([]interface{})
+	newSlice, newIsSlice := newValue.([]interface{})
+	if oldIsSlice && newIsSlice {
+		for i := 0; i < len(oldSlice) || i < len(newSlice); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(oldSlice):
+				changes = append(changes, &specChange{op: '+', path: p, newValue: newSlice[i]})
+			case i >= len(newSlice):
+				changes = append(changes, &specChange{op: '-', path: p, oldValue: oldSlice[i]})
+			default:
+				changes = diffSpec(p, oldSlice[i], newSlice[i], changes)
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		changes = append(changes, &specChange{op: '~', path: path, oldValue: oldValue, newValue: newValue})
+	}
+	return changes
+}
+
+func formatSpecValue(v interface{}) string {
+	buff, err := codectool.MarshalJSON(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(buff)
+}
+
+func (c *specChange) print() {
+	switch c.op {
+	case '+':
+		color.New(color.FgGreen).Printf("    + %s: %s\n", c.path, formatSpecValue(c.newValue))
+	case '-':
+		color.New(color.FgRed).Printf("    - %s: %s\n", c.path, formatSpecValue(c.oldValue))
+	default:
+		color.New(color.FgYellow).Printf("    ~ %s: %s -> %s\n", c.path,
+			formatSpecValue(c.oldValue), formatSpecValue(c.newValue))
+	}
+}
@@ -37,8 +37,11 @@ func ObjectCmd() *cobra.Command {
 	cmd.AddCommand(getObjectCmd())
 	cmd.AddCommand(createObjectCmd())
 	cmd.AddCommand(updateObjectCmd())
+	cmd.AddCommand(diffObjectCmd())
 	cmd.AddCommand(deleteObjectCmd())
 	cmd.AddCommand(statusObjectCmd())
+	cmd.AddCommand(historyObjectCmd())
+	cmd.AddCommand(rollbackObjectCmd())
 
 	return cmd
 }
@@ -117,11 +120,18 @@ func deleteObjectCmd() *cobra.Command {
 }
 
 func getObjectCmd() *cobra.Command {
+	var watch, status bool
+	var kind string
 	cmd := &cobra.Command{
-		Use:     "get",
-		Short:   "Get an object",
-		Example: "egctl object get <object_name>",
+		Use:   "get",
+		Short: "Get an object",
+		Example: `egctl object get <object_name>
+egctl object get <object_name> --watch
+egctl object get --watch --kind HTTPServer --status`,
 		Args: func(cmd *cobra.Command, args []string) error {
+			if watch && len(args) == 0 {
+				return nil
+			}
 			if len(args) != 1 {
 				return errors.New("requires one object name to be retrieved")
 			}
@@ -130,10 +140,78 @@ func getObjectCmd() *cobra.Command {
 		},
 
 		Run: func(cmd *cobra.Command, args []string) {
+			if watch {
+				name := ""
+				if len(args) == 1 {
+					name = args[0]
+				}
+				watchObjects(name, kind, status, cmd)
+				return
+			}
 			handleRequest(http.MethodGet, makeURL(objectURL, args[0]), nil, cmd)
 		},
 	}
 
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch the changes of the object, or all objects if no name is specified.")
+	cmd.Flags().StringVar(&kind, "kind", "", "Only watch the objects of the kind.")
+	cmd.Flags().BoolVar(&status, "status", false, "Watch the status of the objects as well.")
+
+	return cmd
+}
+
+func historyObjectCmd() *cobra.Command {
+	var revision int64
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "View the revision history of an object",
+		Example: `egctl object history <object_name>
+egctl object history <object_name> --revision 3`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires one object name to be retrieved")
+			}
+
+			return nil
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if revision > 0 {
+				handleRequest(http.MethodGet, makeURL(objectRevisionURL, args[0], revision), nil, cmd)
+			} else {
+				handleRequest(http.MethodGet, makeURL(objectRevisionsURL, args[0]), nil, cmd)
+			}
+		},
+	}
+
+	cmd.Flags().Int64Var(&revision, "revision", 0, "Show the details of the revision.")
+
+	return cmd
+}
+
+func rollbackObjectCmd() *cobra.Command {
+	var revision int64
+	cmd := &cobra.Command{
+		Use:     "rollback",
+		Short:   "Rollback an object to a previous revision",
+		Example: "egctl object rollback <object_name> --revision 3",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires one object name to be rolled back")
+			}
+			if revision <= 0 {
+				return errors.New("requires a positive revision")
+			}
+
+			return nil
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			handleRequest(http.MethodPost, makeURL(objectRollbackURL, args[0], revision), nil, cmd)
+		},
+	}
+
+	cmd.Flags().Int64Var(&revision, "revision", 0, "The revision to rollback to, see 'egctl object history'.")
+
 	return cmd
 }
 
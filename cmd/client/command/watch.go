@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+type watchEvent struct {
+	Type      string                 `json:"type"`
+	Kind      string                 `json:"kind"`
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace"`
+	Member    string                 `json:"member"`
+	Spec      map[string]interface{} `json:"spec"`
+	Status    map[string]interface{} `json:"status"`
+}
+
+// watchObjects prints the changes of the objects until the connection is
+// closed.
+func watchObjects(name, kind string, status bool, cmd *cobra.Command) {
+	q := url.Values{}
+	if name != "" {
+		q.Set("name", name)
+	}
+	if kind != "" {
+		q.Set("kind", kind)
+	}
+	if status {
+		q.Set("status", "true")
+	}
+
+	u := makeURL(watchURL)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		ExitWithError(err)
+	}
+	setCredential(req)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		ExitWithErrorf("%s failed: %v", cmd.Short, err)
+	}
+	defer resp.Body.Close()
+
+	if !successfulStatusCode(resp.StatusCode) {
+		body, _ := io.ReadAll(resp.Body)
+		exitWithAPIError(body)
+	}
+
+	err = readServerSentEvents(resp.Body, printWatchEvent)
+	if err != nil {
+		ExitWithErrorf("%s failed: %v", cmd.Short, err)
+	}
+}
+
+// readServerSentEvents reads the server-sent events from r, and calls fn
+// with the name and data of every event.
+func readServerSentEvents(r io.Reader, fn func(event string, data []byte)) error {
+	var event, data string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != "" {
+				fn(event, []byte(data))
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, ":"):
+			// comments are used to keep the connection alive.
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	return scanner.Err()
+}
+
+func printWatchEvent(event string, data []byte) {
+	e := &watchEvent{}
+	if err := codectool.UnmarshalJSON(data, e); err != nil {
+		ExitWithErrorf("unmarshal event %s failed: %v", data, err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	var body map[string]interface{}
+	if event == "status" {
+		fmt.Printf("--- %s %s status of %s %s on %s\n", now, e.Type, e.Kind, e.Name, e.Member)
+		body = e.Status
+	} else {
+		fmt.Printf("--- %s %s %s %s\n", now, e.Type, e.Kind, e.Name)
+		body = e.Spec
+	}
+
+	if len(body) != 0 {
+		printBody(codectool.MustMarshalJSON(body))
+		if CommandlineGlobalFlags.OutputFormat == "json" {
+			fmt.Println()
+		}
+	}
+}
@@ -108,11 +108,13 @@ func main() {
 		command.APICmd(),
 		command.HealthCmd(),
 		command.ObjectCmd(),
+		command.ApplyCmd(),
 		command.MemberCmd(),
 		command.WasmCmd(),
 		command.CustomDataKindCmd(),
 		command.CustomDataCmd(),
 		command.ProfileCmd(),
+		command.AuditCmd(),
 		completionCmd,
 	)
 
@@ -120,6 +122,18 @@ func main() {
 		"server", "localhost:2381", "The address of the Easegress endpoint")
 	rootCmd.PersistentFlags().StringVarP(&command.CommandlineGlobalFlags.OutputFormat,
 		"output", "o", "yaml", "Output format(json, yaml)")
+	rootCmd.PersistentFlags().StringVar(&command.CommandlineGlobalFlags.Token,
+		"token", "", "The bearer token to access the Easegress endpoint")
+	rootCmd.PersistentFlags().StringVarP(&command.CommandlineGlobalFlags.User,
+		"user", "u", "", "The user name and password of basic auth in the format of name:password")
+	rootCmd.PersistentFlags().StringVar(&command.CommandlineGlobalFlags.CAFile,
+		"cacert", "", "The CA file to verify the certificate of the Easegress endpoint")
+	rootCmd.PersistentFlags().StringVar(&command.CommandlineGlobalFlags.CertFile,
+		"cert", "", "The client certificate file to access the Easegress endpoint")
+	rootCmd.PersistentFlags().StringVar(&command.CommandlineGlobalFlags.KeyFile,
+		"key", "", "The private key file of the client certificate")
+	rootCmd.PersistentFlags().BoolVar(&command.CommandlineGlobalFlags.InsecureSkipVerify,
+		"insecure-skip-verify", false, "Skip verifying the certificate of the Easegress endpoint")
 
 	err := rootCmd.Execute()
 	if err != nil {
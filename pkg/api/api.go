@@ -52,7 +52,8 @@ var (
 	apis           = make(map[string]*Group)
 	apisChangeChan = make(chan struct{}, 10)
 
-	appendAddonAPIs []func(s *Server, group *Group)
+	// addonAPIs creates the API groups of addons.
+	addonAPIs []func(s *Server) *Group
 )
 
 type apisByOrder []*Group
@@ -93,24 +94,63 @@ func UnregisterAPIs(group string) {
 	apisChangeChan <- struct{}{}
 }
 
+// The groups of the core APIs, the group of an API is used in the rules
+// of the API authorization.
+const (
+	// APIGroupSystem is the group of the APIs about the API server itself,
+	// e.g. health check and filter metadata.
+	APIGroupSystem = "system"
+	// APIGroupObjects is the group of the APIs of objects, including their
+	// status, revisions, and the apply and watch APIs.
+	APIGroupObjects = "objects"
+	// APIGroupCustomData is the group of the APIs of custom data.
+	APIGroupCustomData = "customdata"
+	// APIGroupCluster is the group of the APIs of cluster members.
+	APIGroupCluster = "cluster"
+	// APIGroupAudit is the group of the audit log APIs.
+	APIGroupAudit = "audit"
+	// APIGroupMetrics is the group of the Prometheus metrics API.
+	APIGroupMetrics = "metrics"
+	// APIGroupProfile is the group of the profiling APIs.
+	APIGroupProfile = "profile"
+)
+
 func (s *Server) registerAPIs() {
-	group := &Group{
-		Group: "admin",
+	groups := []*Group{
+		{Group: APIGroupSystem, Entries: concatEntries(
+			s.listAPIEntries(),
+			s.healthAPIEntries(),
+			s.aboutAPIEntries(),
+			s.metadataAPIEntries(),
+		)},
+		{Group: APIGroupObjects, Entries: concatEntries(
+			s.objectAPIEntries(),
+			s.revisionAPIEntries(),
+			s.applyAPIEntries(),
+			s.watchAPIEntries(),
+		)},
+		{Group: APIGroupCustomData, Entries: s.customDataAPIEntries()},
+		{Group: APIGroupCluster, Entries: s.memberAPIEntries()},
+		{Group: APIGroupAudit, Entries: s.auditAPIEntries()},
+		{Group: APIGroupMetrics, Entries: s.metricsAPIEntries()},
+		{Group: APIGroupProfile, Entries: s.profileAPIEntries()},
+	}
+
+	for _, fn := range addonAPIs {
+		groups = append(groups, fn(s))
 	}
-	group.Entries = append(group.Entries, s.listAPIEntries()...)
-	group.Entries = append(group.Entries, s.memberAPIEntries()...)
-	group.Entries = append(group.Entries, s.objectAPIEntries()...)
-	group.Entries = append(group.Entries, s.metadataAPIEntries()...)
-	group.Entries = append(group.Entries, s.healthAPIEntries()...)
-	group.Entries = append(group.Entries, s.aboutAPIEntries()...)
-	group.Entries = append(group.Entries, s.customDataAPIEntries()...)
-	group.Entries = append(group.Entries, s.profileAPIEntries()...)
-
-	for _, fn := range appendAddonAPIs {
-		fn(s, group)
+
+	for _, group := range groups {
+		RegisterAPIs(group)
 	}
+}
 
-	RegisterAPIs(group)
+func concatEntries(entries ...[]*Entry) []*Entry {
+	var result []*Entry
+	for _, e := range entries {
+		result = append(result, e...)
+	}
+	return result
 }
 
 func (s *Server) listAPIEntries() []*Entry {
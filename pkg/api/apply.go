@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/codectool"
+	"github.com/megaease/easegress/pkg/v"
+)
+
+const (
+	// ApplyPrefix is the URL of the apply API.
+	ApplyPrefix = "/apply"
+
+	// ApplySetPrefix is the prefix of apply set API.
+	ApplySetPrefix = "/applysets"
+)
+
+// maxApplyChanges is the max number of objects created, updated or pruned
+// by an apply request, as all of them are committed in one transaction.
+var maxApplyChanges = cluster.MaxTxnOps
+
+// Actions of applied objects.
+const (
+	ApplyActionCreated    = "created"
+	ApplyActionConfigured = "configured"
+	ApplyActionUnchanged  = "unchanged"
+	ApplyActionPruned     = "pruned"
+)
+
+type (
+	// ApplyRequest is the request to apply a set of objects.
+	ApplyRequest struct {
+		// Source is the name of the apply set, the names of the applied
+		// objects are saved in the apply set, it is required by Prune.
+		Source string `json:"source,omitempty" jsonschema:"omitempty,format=urlname"`
+		// Prune deletes the objects in the apply set which are not in
+		// Objects.
+		Prune   bool                     `json:"prune,omitempty" jsonschema:"omitempty"`
+		Objects []map[string]interface{} `json:"objects,omitempty" jsonschema:"omitempty"`
+	}
+
+	// ApplyResult is the result of applying an object.
+	ApplyResult struct {
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Action string `json:"action"`
+	}
+
+	// ApplySet records the objects applied from the same source.
+	ApplySet struct {
+		Name      string    `json:"name"`
+		Objects   []string  `json:"objects"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+)
+
+// Validate validates ApplyRequest.
+func (req *ApplyRequest) Validate() error {
+	if req.Prune && req.Source == "" {
+		return fmt.Errorf("source is required by prune")
+	}
+	return nil
+}
+
+func (s *Server) applyAPIEntries() []*Entry {
+	return []*Entry{
+		{
+			Path:    ApplyPrefix,
+			Method:  http.MethodPost,
+			Handler: s.apply,
+		},
+		{
+			Path:    ApplySetPrefix + "/{name}",
+			Method:  http.MethodGet,
+			Handler: s.getApplySet,
+		},
+	}
+}
+
+func (s *Server) _getApplySet(name string) *ApplySet {
+	value, err := s.cluster.Get(s.cluster.Layout().ConfigApplySetKey(name))
+	if err != nil {
+		ClusterPanic(err)
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	set := &ApplySet{}
+	err = codectool.UnmarshalJSON([]byte(*value), set)
+	if err != nil {
+		panic(fmt.Errorf("unmarshal %s to json failed: %v", *value, err))
+	}
+
+	return set
+}
+
+func (s *Server) _putApplySet(set *ApplySet) {
+	err := s.cluster.Put(s.cluster.Layout().ConfigApplySetKey(set.Name),
+		string(codectool.MustMarshalJSON(set)))
+	if err != nil {
+		ClusterPanic(err)
+	}
+}
+
+func (s *Server) getApplySet(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	set := s._getApplySet(name)
+	if set == nil {
+		HandleAPIError(w, r, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	WriteBody(w, r, set)
+}
+
+// readApplySpecs creates the specs of the objects in the request, and
+// sorts them in the starting order of their kinds.
+func (s *Server) readApplySpecs(req *ApplyRequest) ([]*supervisor.Spec, error) {
+	specs := make([]*supervisor.Spec, 0, len(req.Objects))
+	names := map[string]struct{}{}
+
+	for i, obj := range req.Objects {
+		spec, err := s.super.NewSpec(string(codectool.MustMarshalJSON(obj)))
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %v", i, err)
+		}
+
+		if _, exists := names[spec.Name()]; exists {
+			return nil, fmt.Errorf("duplicated object name: %s", spec.Name())
+		}
+		names[spec.Name()] = struct{}{}
+
+		specs = append(specs, spec)
+	}
+
+	sort.SliceStable(specs, func(i, j int) bool {
+		return supervisor.ObjectKindOrder(specs[i].Kind()) < supervisor.ObjectKindOrder(specs[j].Kind())
+	})
+
+	return specs, nil
+}
+
+// apply creates or updates the objects in the request, and prunes the
+// objects previously applied from the same source if required. All
+// changes are committed in one transaction, so requests with more than
+// maxApplyChanges changes are rejected, and the supervisor starts
+// the objects in the order of their kinds, so the dependencies are always
+// ready.
+func (s *Server) apply(w http.ResponseWriter, r *http.Request) {
+	req := &ApplyRequest{}
+	err := codectool.DecodeJSON(r.Body, req)
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, fmt.Errorf("decode apply request failed: %v", err))
+		return
+	}
+
+	vr := v.Validate(req)
+	if !vr.Valid() {
+		HandleAPIError(w, r, http.StatusBadRequest, vr)
+		return
+	}
+
+	specs, err := s.readApplySpecs(req)
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	results := make([]*ApplyResult, 0, len(specs))
+	changes := map[string]*string{}
+	existedSpecs := map[string]*supervisor.Spec{}
+	names := make([]string, 0, len(specs))
+
+	for _, spec := range specs {
+		name := spec.Name()
+		names = append(names, name)
+
+		action := ApplyActionCreated
+		existedSpec := s._getObject(name)
+		if existedSpec != nil {
+			if existedSpec.Kind() != spec.Kind() {
+				HandleAPIError(w, r, http.StatusBadRequest,
+					fmt.Errorf("%s: different kinds: %s, %s",
+						name, existedSpec.Kind(), spec.Kind()))
+				return
+			}
+			existedSpecs[name] = existedSpec
+
+			action = ApplyActionConfigured
+			if existedSpec.Equals(spec) {
+				action = ApplyActionUnchanged
+			}
+		}
+
+		if action != ApplyActionUnchanged {
+			value := spec.JSONConfig()
+			changes[s.cluster.Layout().ConfigObjectKey(name)] = &value
+		}
+		results = append(results, &ApplyResult{Kind: spec.Kind(), Name: name, Action: action})
+	}
+
+	var set *ApplySet
+	if req.Source != "" {
+		set = s._getApplySet(req.Source)
+	}
+
+	// pruned objects are deleted in the reverse order.
+	var pruned []*supervisor.Spec
+	if set != nil {
+		applied := map[string]struct{}{}
+		for _, name := range names {
+			applied[name] = struct{}{}
+		}
+
+		for _, name := range set.Objects {
+			if _, ok := applied[name]; ok {
+				continue
+			}
+
+			spec := s._getObject(name)
+			if spec == nil {
+				continue
+			}
+
+			if !req.Prune {
+				// keep tracking it, so that it could be pruned later.
+				names = append(names, name)
+				continue
+			}
+
+			pruned = append(pruned, spec)
+			changes[s.cluster.Layout().ConfigObjectKey(name)] = nil
+		}
+	}
+	sort.SliceStable(pruned, func(i, j int) bool {
+		return supervisor.ObjectKindOrder(pruned[i].Kind()) > supervisor.ObjectKindOrder(pruned[j].Kind())
+	})
+	for _, spec := range pruned {
+		results = append(results, &ApplyResult{Kind: spec.Kind(), Name: spec.Name(), Action: ApplyActionPruned})
+	}
+
+	if len(changes) > maxApplyChanges {
+		HandleAPIError(w, r, http.StatusBadRequest,
+			fmt.Errorf("too many changes: %d objects to create, update or prune, "+
+				"exceeding the limit %d of a transaction, please apply them in multiple requests",
+				len(changes), maxApplyChanges))
+		return
+	}
+
+	if isDryRun(r) {
+		WriteBody(w, r, results)
+		return
+	}
+
+	if len(changes) > 0 {
+		err = s.cluster.PutAndDelete(changes)
+		if err != nil {
+			ClusterPanic(err)
+		}
+
+		for _, spec := range specs {
+			existedSpec := existedSpecs[spec.Name()]
+			if existedSpec == nil {
+				s._putObjectRevision(r, spec.Name(), nil, spec.RawSpec(), 0)
+				s.audit(r, AuditResourceObject, spec.Kind(), spec.Name(), nil, spec)
+			} else if !existedSpec.Equals(spec) {
+				s._putObjectRevision(r, spec.Name(), existedSpec.RawSpec(), spec.RawSpec(), 0)
+				s.audit(r, AuditResourceObject, spec.Kind(), spec.Name(), existedSpec, spec)
+			}
+		}
+		for _, spec := range pruned {
+			s._deleteObjectRevisions(spec.Name())
+			s.audit(r, AuditResourceObject, spec.Kind(), spec.Name(), spec, nil)
+		}
+
+		s.upgradeConfigVersion(w, r)
+	}
+
+	if req.Source != "" {
+		sort.Strings(names)
+		s._putApplySet(&ApplySet{Name: req.Source, Objects: names, UpdatedAt: time.Now()})
+	}
+
+	WriteBody(w, r, results)
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/option"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/codectool"
+	"github.com/megaease/easegress/pkg/v"
+)
+
+type (
+	applyTestSpec struct {
+		Value string `json:"value"`
+	}
+
+	applyTestObject struct {
+		kind     string
+		category supervisor.ObjectCategory
+	}
+
+	applyTestMutex struct{}
+)
+
+func (o *applyTestObject) Category() supervisor.ObjectCategory         { return o.category }
+func (o *applyTestObject) Kind() string                                { return o.kind }
+func (o *applyTestObject) DefaultSpec() interface{}                    { return &applyTestSpec{} }
+func (o *applyTestObject) Status() *supervisor.Status                  { return &supervisor.Status{} }
+func (o *applyTestObject) Close()                                      {}
+func (o *applyTestObject) Init(*supervisor.Spec)                       {}
+func (o *applyTestObject) Inherit(*supervisor.Spec, supervisor.Object) {}
+
+func (m *applyTestMutex) Lock() error   { return nil }
+func (m *applyTestMutex) Unlock() error { return nil }
+
+func init() {
+	supervisor.Register(&applyTestObject{"ApplyTestSystemController", supervisor.CategorySystemController})
+	supervisor.Register(&applyTestObject{"ApplyTestBusinessController", supervisor.CategoryBusinessController})
+}
+
+// newApplyTestServer creates a server which could apply objects to the
+// mocked cluster.
+func newApplyTestServer() (*Server, map[string]string) {
+	s, kvs := newTestServer()
+	s.super = supervisor.NewMock(option.New(), s.cluster, sync.Map{}, sync.Map{}, nil, nil, false, nil, nil)
+	s.mutex = &applyTestMutex{}
+	return s, kvs
+}
+
+func doApply(s *Server, req *ApplyRequest, dryRun bool) (*httptest.ResponseRecorder, []*ApplyResult) {
+	url := "/apis/v2/apply"
+	if dryRun {
+		url += "?dryRun=true"
+	}
+	r := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(codectool.MustMarshalJSON(req)))
+	w := httptest.NewRecorder()
+	s.apply(w, r)
+
+	var results []*ApplyResult
+	if w.Code == http.StatusOK {
+		codectool.MustUnmarshal(w.Body.Bytes(), &results)
+	}
+	return w, results
+}
+
+func applyTestObjects(objects ...string) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for i := 0; i+2 < len(objects); i += 3 {
+		result = append(result, map[string]interface{}{
+			"kind":  objects[i],
+			"name":  objects[i+1],
+			"value": objects[i+2],
+		})
+	}
+	return result
+}
+
+func TestApplyRequestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	objects := []map[string]interface{}{{"kind": "Pipeline", "name": "demo"}}
+
+	req := &ApplyRequest{Objects: objects}
+	assert.True(v.Validate(req).Valid())
+
+	req = &ApplyRequest{Source: "demo-app", Prune: true, Objects: objects}
+	assert.True(v.Validate(req).Valid())
+
+	req = &ApplyRequest{Prune: true, Objects: objects}
+	assert.False(v.Validate(req).Valid())
+
+	req = &ApplyRequest{Source: "demo/app", Objects: objects}
+	assert.False(v.Validate(req).Valid())
+}
+
+func TestApplySet(t *testing.T) {
+	assert := assert.New(t)
+
+	s, _ := newTestServer()
+	assert.Nil(s._getApplySet("demo-app"))
+
+	s._putApplySet(&ApplySet{Name: "demo-app", Objects: []string{"pipeline-demo", "server-demo"}})
+	set := s._getApplySet("demo-app")
+	assert.NotNil(set)
+	assert.Equal([]string{"pipeline-demo", "server-demo"}, set.Objects)
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	logger.InitNop()
+
+	s, kvs := newApplyTestServer()
+	objectKey := s.cluster.Layout().ConfigObjectKey
+
+	// objects are applied in the starting order of their kinds.
+	req := &ApplyRequest{
+		Source: "demo-app",
+		Objects: applyTestObjects(
+			"ApplyTestBusinessController", "business", "v1",
+			"ApplyTestSystemController", "system", "v1",
+		),
+	}
+	w, results := doApply(s, req, false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]*ApplyResult{
+		{Kind: "ApplyTestSystemController", Name: "system", Action: ApplyActionCreated},
+		{Kind: "ApplyTestBusinessController", Name: "business", Action: ApplyActionCreated},
+	}, results)
+	assert.Contains(kvs, objectKey("system"))
+	assert.Contains(kvs, objectKey("business"))
+	assert.Equal([]string{"business", "system"}, s._getApplySet("demo-app").Objects)
+
+	req.Objects = applyTestObjects(
+		"ApplyTestBusinessController", "business", "v2",
+		"ApplyTestSystemController", "system", "v1",
+	)
+	w, results = doApply(s, req, false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]*ApplyResult{
+		{Kind: "ApplyTestSystemController", Name: "system", Action: ApplyActionUnchanged},
+		{Kind: "ApplyTestBusinessController", Name: "business", Action: ApplyActionConfigured},
+	}, results)
+	assert.Equal("v2", s._getObject("business").RawSpec()["value"])
+
+	// dry run changes nothing.
+	req = &ApplyRequest{
+		Source:  "demo-app",
+		Prune:   true,
+		Objects: applyTestObjects("ApplyTestSystemController", "system", "v2"),
+	}
+	w, results = doApply(s, req, true)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]*ApplyResult{
+		{Kind: "ApplyTestSystemController", Name: "system", Action: ApplyActionConfigured},
+		{Kind: "ApplyTestBusinessController", Name: "business", Action: ApplyActionPruned},
+	}, results)
+	assert.Equal("v1", s._getObject("system").RawSpec()["value"])
+	assert.NotNil(s._getObject("business"))
+
+	// without prune, the missing objects are kept in the apply set.
+	req.Prune = false
+	w, results = doApply(s, req, false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]*ApplyResult{
+		{Kind: "ApplyTestSystemController", Name: "system", Action: ApplyActionConfigured},
+	}, results)
+	assert.NotNil(s._getObject("business"))
+	assert.Equal([]string{"business", "system"}, s._getApplySet("demo-app").Objects)
+
+	req.Prune = true
+	w, results = doApply(s, req, false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal([]*ApplyResult{
+		{Kind: "ApplyTestSystemController", Name: "system", Action: ApplyActionUnchanged},
+		{Kind: "ApplyTestBusinessController", Name: "business", Action: ApplyActionPruned},
+	}, results)
+	assert.NotContains(kvs, objectKey("business"))
+	assert.Equal([]string{"system"}, s._getApplySet("demo-app").Objects)
+
+	// the kind of an existing object can't be changed.
+	req = &ApplyRequest{Objects: applyTestObjects("ApplyTestBusinessController", "system", "v1")}
+	w, _ = doApply(s, req, false)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Contains(w.Body.String(), "different kinds")
+	assert.Equal("ApplyTestSystemController", s._getObject("system").Kind())
+}
+
+func TestApplyTooManyChanges(t *testing.T) {
+	assert := assert.New(t)
+	logger.InitNop()
+
+	s, kvs := newApplyTestServer()
+
+	limit := maxApplyChanges
+	maxApplyChanges = 1
+	defer func() {
+		maxApplyChanges = limit
+	}()
+
+	req := &ApplyRequest{
+		Objects: applyTestObjects(
+			"ApplyTestSystemController", "system1", "v1",
+			"ApplyTestSystemController", "system2", "v1",
+		),
+	}
+	w, _ := doApply(s, req, true)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Contains(w.Body.String(), "too many changes")
+
+	w, _ = doApply(s, req, false)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Empty(kvs)
+
+	req.Objects = req.Objects[:1]
+	w, _ = doApply(s, req, false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotEmpty(kvs)
+}
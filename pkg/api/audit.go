@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+const (
+	// AuditPrefix is the prefix of audit API.
+	AuditPrefix = "/audit"
+
+	// maxAuditRecords is the max number of audit records kept in the
+	// cluster, the oldest records are removed when it is exceeded.
+	maxAuditRecords = 1000
+
+	defaultAuditLimit = 100
+)
+
+// Resources of audit records.
+const (
+	AuditResourceObject         = "object"
+	AuditResourceCustomDataKind = "customdatakind"
+	AuditResourceCustomData     = "customdata"
+)
+
+// Actions of audit records.
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+type (
+	// AuditRecord is a record of a configuration change made through the
+	// admin API.
+	AuditRecord struct {
+		ID         string    `json:"id"`
+		Time       time.Time `json:"time"`
+		Member     string    `json:"member"`
+		ClientAddr string    `json:"clientAddr"`
+		Principal  string    `json:"principal"`
+		Method     string    `json:"method"`
+		Path       string    `json:"path"`
+		Action     string    `json:"action"`
+		Resource   string    `json:"resource"`
+		// Kind is the kind of the object, or the kind of the custom data.
+		Kind string `json:"kind,omitempty"`
+		Name string `json:"name"`
+		// Diff is the unified diff between the YAML of the resource before
+		// and after the change.
+		Diff string `json:"diff,omitempty"`
+	}
+
+	// AuditFilter filters audit records.
+	AuditFilter struct {
+		Resource  string
+		Kind      string
+		Name      string
+		Principal string
+		Member    string
+		Since     time.Time
+		Limit     int
+	}
+)
+
+// principal returns the authenticated principal of the request, it is
+// empty if authentication is disabled.
+func principal(r *http.Request) string {
+	name, _ := r.Context().Value(principalKey{}).(string)
+	return name
+}
+
+// isNil returns whether v is nil, including a typed nil such as a nil
+// pointer or map stored in the interface.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// auditDiff returns the unified diff between the YAML of before and after,
+// nil means the resource does not exist.
+func auditDiff(before, after interface{}) string {
+	toYAML := func(v interface{}) string {
+		if isNil(v) {
+			return ""
+		}
+		buff, err := codectool.MarshalJSON(v)
+		if err != nil {
+			return fmt.Sprintf("%v\n", v)
+		}
+		buff, err = codectool.JSONToYAML(buff)
+		if err != nil {
+			return fmt.Sprintf("%s\n", buff)
+		}
+		return string(buff)
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(toYAML(before)),
+		B:        difflib.SplitLines(toYAML(after)),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+func (f *AuditFilter) match(record *AuditRecord) bool {
+	if f.Resource != "" && f.Resource != record.Resource {
+		return false
+	}
+	if f.Kind != "" && f.Kind != record.Kind {
+		return false
+	}
+	if f.Name != "" && f.Name != record.Name {
+		return false
+	}
+	if f.Principal != "" && f.Principal != record.Principal {
+		return false
+	}
+	if f.Member != "" && f.Member != record.Member {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+func parseAuditFilter(r *http.Request) (*AuditFilter, error) {
+	q := r.URL.Query()
+	f := &AuditFilter{
+		Resource:  q.Get("resource"),
+		Kind:      q.Get("kind"),
+		Name:      q.Get("name"),
+		Principal: q.Get("principal"),
+		Member:    q.Get("member"),
+		Limit:     defaultAuditLimit,
+	}
+
+	if since := q.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			f.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			f.Since = t
+		} else {
+			return nil, fmt.Errorf("invalid since %s: must be a duration or RFC3339 time", since)
+		}
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit %s: must be a positive integer", limit)
+		}
+		f.Limit = n
+	}
+
+	return f, nil
+}
+
+func (s *Server) auditAPIEntries() []*Entry {
+	return []*Entry{
+		{
+			Path:    AuditPrefix,
+			Method:  http.MethodGet,
+			Handler: s.listAuditRecords,
+		},
+	}
+}
+
+// audit records a configuration change, before and after are the
+// resource before and after the change, nil means not exist.
+//
+// Failures are logged rather than returned, as the change has already
+// been made.
+func (s *Server) audit(r *http.Request, resource, kind, name string, before, after interface{}) {
+	action := AuditActionUpdate
+	if isNil(before) {
+		action = AuditActionCreate
+	} else if isNil(after) {
+		action = AuditActionDelete
+	}
+
+	now := time.Now()
+	record := &AuditRecord{
+		// the ID is sortable by time.
+		ID:         fmt.Sprintf("%020d-%s", now.UnixNano(), s.opt.Name),
+		Time:       now,
+		Member:     s.opt.Name,
+		ClientAddr: r.RemoteAddr,
+		Principal:  principal(r),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Action:     action,
+		Resource:   resource,
+		Kind:       kind,
+		Name:       name,
+		Diff:       auditDiff(before, after),
+	}
+
+	buff, err := codectool.MarshalJSON(record)
+	if err != nil {
+		logger.Errorf("BUG: marshal audit record %#v failed: %v", record, err)
+		return
+	}
+
+	logger.Audit(string(buff))
+
+	if s.cluster == nil {
+		return
+	}
+	if err = s.cluster.Put(s.cluster.Layout().AuditKey(record.ID), string(buff)); err != nil {
+		logger.Errorf("put audit record %s failed: %v", record.ID, err)
+		return
+	}
+	s.trimAuditRecords()
+}
+
+// trimAuditRecords removes the oldest audit records if there are more
+// than maxAuditRecords.
+func (s *Server) trimAuditRecords() {
+	kvs, err := s.cluster.GetWithOp(s.cluster.Layout().AuditPrefix(), cluster.OpPrefix, cluster.OpKeysOnly)
+	if err != nil {
+		logger.Errorf("get audit records failed: %v", err)
+		return
+	}
+	if len(kvs) <= maxAuditRecords {
+		return
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	deletes := map[string]*string{}
+	for _, k := range keys[:len(keys)-maxAuditRecords] {
+		deletes[k] = nil
+	}
+	if err = s.cluster.PutAndDelete(deletes); err != nil {
+		logger.Errorf("delete outdated audit records failed: %v", err)
+	}
+}
+
+func (s *Server) _listAuditRecords(filter *AuditFilter) []*AuditRecord {
+	kvs, err := s.cluster.GetPrefix(s.cluster.Layout().AuditPrefix())
+	if err != nil {
+		ClusterPanic(err)
+	}
+
+	records := make([]*AuditRecord, 0, len(kvs))
+	for k, v := range kvs {
+		record := &AuditRecord{}
+		if err = codectool.UnmarshalJSON([]byte(v), record); err != nil {
+			logger.Errorf("unmarshal audit record %s failed: %v", strings.TrimPrefix(k, s.cluster.Layout().AuditPrefix()), err)
+			continue
+		}
+		if filter.match(record) {
+			records = append(records, record)
+		}
+	}
+
+	// the newest first.
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID > records[j].ID
+	})
+	if len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records
+}
+
+func (s *Server) listAuditRecords(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteBody(w, r, s._listAuditRecords(filter))
+}
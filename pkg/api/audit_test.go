@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/cluster/clustertest"
+	"github.com/megaease/easegress/pkg/cluster/customdata"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/option"
+	"github.com/megaease/easegress/pkg/supervisor"
+)
+
+// newTestServer creates a server with a mocked cluster which stores
+// data in the returned map.
+func newTestServer() (*Server, map[string]string) {
+	var lock sync.Mutex
+	kvs := map[string]string{}
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedLayout = func() *cluster.Layout {
+		return &cluster.Layout{}
+	}
+	cls.MockedPut = func(key, value string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		kvs[key] = value
+		return nil
+	}
+	cls.MockedGet = func(key string) (*string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if v, ok := kvs[key]; ok {
+			return &v, nil
+		}
+		return nil, nil
+	}
+	cls.MockedGetPrefix = func(prefix string) (map[string]string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		result := map[string]string{}
+		for k, v := range kvs {
+			if strings.HasPrefix(k, prefix) {
+				result[k] = v
+			}
+		}
+		return result, nil
+	}
+	cls.MockedGetWithOp = func(key string, ops ...cluster.ClientOp) (map[string]string, error) {
+		return cls.MockedGetPrefix(key)
+	}
+	cls.MockedPutAndDelete = func(m map[string]*string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for k, v := range m {
+			if v == nil {
+				delete(kvs, k)
+			} else {
+				kvs[k] = *v
+			}
+		}
+		return nil
+	}
+	cls.MockedDeletePrefix = func(prefix string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for k := range kvs {
+			if strings.HasPrefix(k, prefix) {
+				delete(kvs, k)
+			}
+		}
+		return nil
+	}
+
+	cls.MockedSTM = func(apply func(concurrency.STM) error) error {
+		lock.Lock()
+		defer lock.Unlock()
+		return apply(&clustertest.MockedSTM{
+			MockedGet: func(key ...string) string {
+				return kvs[key[0]]
+			},
+			MockedPut: func(key, val string, opts ...clientv3.OpOption) {
+				kvs[key] = val
+			},
+		})
+	}
+
+	s := &Server{opt: &option.Options{Name: "member-1"}, cluster: cls}
+	return s, kvs
+}
+
+func TestAuditDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	before := map[string]interface{}{"name": "demo", "kind": "HTTPServer", "port": 80}
+	after := map[string]interface{}{"name": "demo", "kind": "HTTPServer", "port": 8080}
+
+	diff := auditDiff(before, after)
+	assert.Contains(diff, "--- before")
+	assert.Contains(diff, "+++ after")
+	assert.Contains(diff, "-port: 80\n")
+	assert.Contains(diff, "+port: 8080\n")
+	assert.NotContains(diff, "-name: demo")
+
+	diff = auditDiff(nil, after)
+	assert.Contains(diff, "+name: demo\n")
+	assert.NotContains(diff, "\n-")
+
+	assert.Equal("", auditDiff(before, before))
+
+	// typed nil means not exist too.
+	var none map[string]interface{}
+	diff = auditDiff(none, after)
+	assert.Contains(diff, "+name: demo\n")
+	assert.NotContains(diff, "\n-")
+}
+
+func TestIsNil(t *testing.T) {
+	assert := assert.New(t)
+
+	var data customdata.Data
+	var spec *supervisor.Spec
+	assert.True(isNil(nil))
+	assert.True(isNil(data))
+	assert.True(isNil(spec))
+	assert.False(isNil(customdata.Data{}))
+	assert.False(isNil(""))
+}
+
+func TestAudit(t *testing.T) {
+	assert := assert.New(t)
+	logger.InitNop()
+
+	s, kvs := newTestServer()
+
+	obj := map[string]interface{}{"name": "demo", "kind": "HTTPServer", "port": 80}
+	updated := map[string]interface{}{"name": "demo", "kind": "HTTPServer", "port": 8080}
+
+	req := httptest.NewRequest("POST", "/apis/v2/objects", nil)
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, "alice"))
+	s.audit(req, AuditResourceObject, "HTTPServer", "demo", nil, obj)
+
+	req = httptest.NewRequest("PUT", "/apis/v2/objects/demo", nil)
+	s.audit(req, AuditResourceObject, "HTTPServer", "demo", obj, updated)
+
+	req = httptest.NewRequest("DELETE", "/apis/v2/customdata/kind1/data1", nil)
+	s.audit(req, AuditResourceCustomData, "kind1", "data1", obj, nil)
+
+	assert.Len(kvs, 3)
+
+	records := s._listAuditRecords(&AuditFilter{Limit: 10})
+	assert.Len(records, 3)
+	// the newest first.
+	assert.Equal(AuditActionDelete, records[0].Action)
+	assert.Equal(AuditResourceCustomData, records[0].Resource)
+	assert.Equal(AuditActionUpdate, records[1].Action)
+	assert.Equal(AuditActionCreate, records[2].Action)
+	assert.Equal("alice", records[2].Principal)
+	assert.Equal("member-1", records[2].Member)
+	assert.Equal("POST", records[2].Method)
+	assert.Equal("/apis/v2/objects", records[2].Path)
+	assert.NotEmpty(records[2].ClientAddr)
+	assert.Contains(records[1].Diff, "+port: 8080")
+
+	records = s._listAuditRecords(&AuditFilter{Resource: AuditResourceObject, Limit: 10})
+	assert.Len(records, 2)
+	records = s._listAuditRecords(&AuditFilter{Principal: "alice", Limit: 10})
+	assert.Len(records, 1)
+	records = s._listAuditRecords(&AuditFilter{Limit: 1})
+	assert.Len(records, 1)
+	assert.Equal(AuditActionDelete, records[0].Action)
+
+	// outdated records are removed.
+	for i := 0; i < maxAuditRecords; i++ {
+		s.audit(req, AuditResourceCustomData, "kind1", fmt.Sprintf("data%d", i), obj, nil)
+	}
+	assert.Len(kvs, maxAuditRecords)
+	records = s._listAuditRecords(&AuditFilter{Resource: AuditResourceObject, Limit: 10})
+	assert.Len(records, 0)
+}
+
+func TestAuditCustomDataChanges(t *testing.T) {
+	assert := assert.New(t)
+	logger.InitNop()
+
+	s, _ := newTestServer()
+
+	before := map[string]customdata.Data{
+		"data1": {"name": "data1", "value": 1},
+		"data2": {"name": "data2", "value": 2},
+		"data3": {"name": "data3", "value": 3},
+	}
+	after := map[string]customdata.Data{
+		"data2": {"name": "data2", "value": 20},
+		"data3": {"name": "data3", "value": 3},
+		"data4": {"name": "data4", "value": 4},
+	}
+
+	req := httptest.NewRequest("POST", "/apis/v2/customdata/kind1/items", nil)
+	s.auditCustomDataChanges(req, "kind1", before, after)
+
+	records := s._listAuditRecords(&AuditFilter{Limit: 10})
+	// data3 is not changed.
+	assert.Len(records, 3)
+
+	actions := map[string]string{}
+	for _, record := range records {
+		assert.Equal(AuditResourceCustomData, record.Resource)
+		assert.Equal("kind1", record.Kind)
+		actions[record.Name] = record.Action
+	}
+	assert.Equal(map[string]string{
+		"data1": AuditActionDelete,
+		"data2": AuditActionUpdate,
+		"data4": AuditActionCreate,
+	}, actions)
+}
+
+func TestParseAuditFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/apis/v2/audit?kind=HTTPServer&name=demo&since=1h&limit=5", nil)
+	f, err := parseAuditFilter(req)
+	assert.NoError(err)
+	assert.Equal("HTTPServer", f.Kind)
+	assert.Equal("demo", f.Name)
+	assert.Equal(5, f.Limit)
+	assert.False(f.Since.IsZero())
+
+	req = httptest.NewRequest("GET", "/apis/v2/audit?since=2022-10-01T00:00:00Z", nil)
+	f, err = parseAuditFilter(req)
+	assert.NoError(err)
+	assert.Equal(defaultAuditLimit, f.Limit)
+	assert.Equal(2022, f.Since.Year())
+
+	req = httptest.NewRequest("GET", "/apis/v2/audit?since=yesterday", nil)
+	_, err = parseAuditFilter(req)
+	assert.Error(err)
+
+	req = httptest.NewRequest("GET", "/apis/v2/audit?limit=0", nil)
+	_, err = parseAuditFilter(req)
+	assert.Error(err)
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+type (
+	// AuthConfig is the configuration of the authentication and
+	// authorization of the admin API.
+	AuthConfig struct {
+		Users []*AuthUser `json:"users"`
+		Roles []*AuthRole `json:"roles"`
+
+		// AnonymousRoles is the roles of unauthenticated requests,
+		// unauthenticated requests are rejected if it is empty.
+		AnonymousRoles []string `json:"anonymousRoles"`
+	}
+
+	// AuthUser is a user of the admin API, a user could be authenticated
+	// by a bearer token, basic auth, or a client certificate whose common
+	// name is the user name.
+	AuthUser struct {
+		Name   string   `json:"name"`
+		Tokens []string `json:"tokens"`
+		// PasswordHash is the bcrypt hash of the password of basic auth.
+		PasswordHash string   `json:"passwordHash"`
+		Roles        []string `json:"roles"`
+	}
+
+	// AuthRole is a set of permissions.
+	AuthRole struct {
+		Name  string      `json:"name"`
+		Rules []*AuthRule `json:"rules"`
+	}
+
+	// AuthRule grants the permission of the verbs on the paths of the API
+	// groups, "*" matches all groups and verbs, a path ends with "*" is a
+	// prefix, and empty paths match all paths.
+	AuthRule struct {
+		Groups []string `json:"groups"`
+		Verbs  []string `json:"verbs"`
+		Paths  []string `json:"paths"`
+	}
+
+	// authorizer authenticates and authorizes requests of the admin API.
+	authorizer struct {
+		users          map[string]*AuthUser
+		tokens         map[[sha256.Size]byte]*AuthUser
+		roles          map[string]*AuthRole
+		anonymousRoles []string
+		memberToken    *MemberTokenLoader
+	}
+
+	principalKey struct{}
+)
+
+const anonymous = "anonymous"
+
+func newAuthorizer(configFile string) (*authorizer, error) {
+	if configFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &AuthConfig{}
+	if err = codectool.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	return newAuthorizerFromConfig(config)
+}
+
+func newAuthorizerFromConfig(config *AuthConfig) (*authorizer, error) {
+	a := &authorizer{
+		users:          map[string]*AuthUser{},
+		tokens:         map[[sha256.Size]byte]*AuthUser{},
+		roles:          map[string]*AuthRole{},
+		anonymousRoles: config.AnonymousRoles,
+	}
+
+	for _, role := range config.Roles {
+		if role.Name == "" {
+			return nil, fmt.Errorf("empty role name")
+		}
+		if a.roles[role.Name] != nil {
+			return nil, fmt.Errorf("role %s is duplicated", role.Name)
+		}
+		a.roles[role.Name] = role
+	}
+
+	checkRoles := func(roles []string) error {
+		for _, name := range roles {
+			if a.roles[name] == nil {
+				return fmt.Errorf("role %s not found", name)
+			}
+		}
+		return nil
+	}
+
+	for _, user := range config.Users {
+		if user.Name == "" || user.Name == anonymous || user.Name == memberPrincipal {
+			return nil, fmt.Errorf("invalid user name %q", user.Name)
+		}
+		if a.users[user.Name] != nil {
+			return nil, fmt.Errorf("user %s is duplicated", user.Name)
+		}
+		if err := checkRoles(user.Roles); err != nil {
+			return nil, fmt.Errorf("user %s: %v", user.Name, err)
+		}
+		a.users[user.Name] = user
+
+		for _, token := range user.Tokens {
+			key := sha256.Sum256([]byte(token))
+			if a.tokens[key] != nil {
+				return nil, fmt.Errorf("user %s: token is duplicated", user.Name)
+			}
+			a.tokens[key] = user
+		}
+	}
+
+	if err := checkRoles(config.AnonymousRoles); err != nil {
+		return nil, fmt.Errorf("anonymousRoles: %v", err)
+	}
+
+	return a, nil
+}
+
+// authenticate returns the name and roles of the principal of the
+// request, ok is false if the credential is invalid.
+func (a *authorizer) authenticate(r *http.Request) (name string, roles []string, ok bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, credential, _ := strings.Cut(auth, " ")
+		switch strings.ToLower(scheme) {
+		case "bearer":
+			user := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(credential)))]
+			if user == nil {
+				return "", nil, false
+			}
+			return user.Name, user.Roles, true
+		case "basic":
+			name, password, ok := r.BasicAuth()
+			if !ok {
+				return "", nil, false
+			}
+			user := a.users[name]
+			if user == nil || user.PasswordHash == "" {
+				return "", nil, false
+			}
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+				return "", nil, false
+			}
+			return user.Name, user.Roles, true
+		default:
+			return "", nil, false
+		}
+	}
+
+	// client certificates are verified by the TLS layer.
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		name := r.TLS.PeerCertificates[0].Subject.CommonName
+		if user := a.users[name]; user != nil {
+			return user.Name, user.Roles, true
+		}
+		return name, nil, true
+	}
+
+	return anonymous, a.anonymousRoles, true
+}
+
+// authorize reports whether the roles are permitted to access the path
+// of the group with the method.
+func (a *authorizer) authorize(roles []string, group, method, path string) bool {
+	verb := strings.ToLower(method)
+	for _, name := range roles {
+		for _, rule := range a.roles[name].Rules {
+			if rule.match(group, verb, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (rule *AuthRule) match(group, verb, path string) bool {
+	if !matchAny(rule.Groups, group) || !matchAny(rule.Verbs, verb) {
+		return false
+	}
+	if len(rule.Paths) == 0 {
+		return true
+	}
+
+	for _, p := range rule.Paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, p[:len(p)-1]) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == value {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap returns a handler which authenticates and authorizes the requests
+// before calling the handler of the API. Requests with the member token
+// are permitted to access internal APIs only, whatever the roles are.
+func (a *authorizer) wrap(group string, api *Entry) http.HandlerFunc {
+	handler := api.Handler
+	if a == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get(MemberTokenHeader); token != "" {
+			if a.memberToken == nil || !a.memberToken.verify(token) {
+				HandleAPIError(w, r, http.StatusUnauthorized, fmt.Errorf("invalid member token"))
+				return
+			}
+			if !api.Internal {
+				HandleAPIError(w, r, http.StatusForbidden, fmt.Errorf("%s is not permitted to %s %s", memberPrincipal, r.Method, r.URL.Path))
+				return
+			}
+			ctx := context.WithValue(r.Context(), principalKey{}, memberPrincipal)
+			handler(w, r.WithContext(ctx))
+			return
+		}
+
+		name, roles, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="easegress"`)
+			HandleAPIError(w, r, http.StatusUnauthorized, fmt.Errorf("invalid credential"))
+			return
+		}
+
+		if name == anonymous && len(roles) == 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="easegress"`)
+			HandleAPIError(w, r, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, APIPrefixV2)
+		if len(path) == len(r.URL.Path) {
+			path = strings.TrimPrefix(r.URL.Path, APIPrefixV1)
+		}
+		if !a.authorize(roles, group, r.Method, path) {
+			HandleAPIError(w, r, http.StatusForbidden, fmt.Errorf("%s is not permitted to %s %s", name, r.Method, r.URL.Path))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, name)
+		handler(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+const authConfigYAML = `
+users:
+- name: admin
+  tokens: [admin-token]
+  roles: [admin]
+- name: alice
+  passwordHash: %s
+  roles: [viewer]
+- name: bob
+  roles: [viewer]
+roles:
+- name: admin
+  rules:
+  - groups: ["*"]
+    verbs: ["*"]
+- name: viewer
+  rules:
+  - groups: [objects]
+    verbs: [get]
+    paths: ["/objects*"]
+- name: health
+  rules:
+  - groups: [system]
+    verbs: [get]
+    paths: [/healthz]
+anonymousRoles: [health]
+`
+
+func TestAuthorizer(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	config := &AuthConfig{}
+	codectool.MustUnmarshal([]byte(fmt.Sprintf(authConfigYAML, string(hash))), config)
+
+	a, err := newAuthorizerFromConfig(config)
+	assert.NoError(err)
+
+	var called bool
+	serve := func(group, method, path string, setup func(r *http.Request)) int {
+		called = false
+		handler := a.wrap(group, &Entry{Handler: func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}})
+		r := httptest.NewRequest(method, "http://localhost"+APIPrefixV2+path, nil)
+		if setup != nil {
+			setup(r)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code == http.StatusOK {
+			assert.True(called)
+		}
+		return w.Code
+	}
+
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	basic := func(name, password string) func(r *http.Request) {
+		return func(r *http.Request) {
+			r.SetBasicAuth(name, password)
+		}
+	}
+
+	// anonymous
+	assert.Equal(http.StatusOK, serve(APIGroupSystem, http.MethodGet, "/healthz", nil))
+	assert.Equal(http.StatusForbidden, serve(APIGroupObjects, http.MethodGet, "/objects", nil))
+
+	// bearer token
+	assert.Equal(http.StatusOK, serve(APIGroupObjects, http.MethodDelete, "/objects/foo", bearer("admin-token")))
+	assert.Equal(http.StatusUnauthorized, serve(APIGroupObjects, http.MethodGet, "/objects", bearer("bad-token")))
+
+	// basic auth
+	assert.Equal(http.StatusOK, serve(APIGroupObjects, http.MethodGet, "/objects/foo", basic("alice", "alice-password")))
+	assert.Equal(http.StatusForbidden, serve(APIGroupObjects, http.MethodDelete, "/objects/foo", basic("alice", "alice-password")))
+	assert.Equal(http.StatusForbidden, serve(APIGroupCustomData, http.MethodGet, "/customdata", basic("alice", "alice-password")))
+	assert.Equal(http.StatusUnauthorized, serve(APIGroupObjects, http.MethodGet, "/objects", basic("alice", "bad-password")))
+	assert.Equal(http.StatusUnauthorized, serve(APIGroupObjects, http.MethodGet, "/objects", basic("bob", "")))
+
+	// client certificate
+	cert := func(cn string) func(r *http.Request) {
+		return func(r *http.Request) {
+			c := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{c},
+				VerifiedChains:   [][]*x509.Certificate{{c}},
+			}
+		}
+	}
+	assert.Equal(http.StatusOK, serve(APIGroupObjects, http.MethodGet, "/objects", cert("bob")))
+	assert.Equal(http.StatusForbidden, serve(APIGroupObjects, http.MethodGet, "/objects", cert("unknown")))
+
+	// other groups
+	assert.Equal(http.StatusForbidden, serve("mesh", http.MethodGet, "/objects", basic("alice", "alice-password")))
+	assert.Equal(http.StatusOK, serve("mesh", http.MethodGet, "/objects", bearer("admin-token")))
+}
+
+func TestAuthorizerConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := newAuthorizer("")
+	assert.Nil(a)
+	assert.NoError(err)
+
+	_, err = newAuthorizerFromConfig(&AuthConfig{
+		Users: []*AuthUser{{Name: "admin", Roles: []string{"admin"}}},
+	})
+	assert.Error(err)
+
+	_, err = newAuthorizerFromConfig(&AuthConfig{
+		Users: []*AuthUser{{Name: "a", Tokens: []string{"t"}}, {Name: "b", Tokens: []string{"t"}}},
+	})
+	assert.Error(err)
+
+	_, err = newAuthorizerFromConfig(&AuthConfig{AnonymousRoles: []string{"admin"}})
+	assert.Error(err)
+
+	_, err = newAuthorizerFromConfig(&AuthConfig{Roles: []*AuthRole{{Name: "admin"}, {Name: "admin"}}})
+	assert.Error(err)
+}
@@ -20,6 +20,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/megaease/easegress/pkg/cluster/customdata"
@@ -129,6 +130,7 @@ func (s *Server) createCustomDataKind(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		ClusterPanic(err)
 	}
+	s.audit(r, AuditResourceCustomDataKind, "", k.Name, nil, &k)
 
 	w.WriteHeader(http.StatusCreated)
 	location := fmt.Sprintf("%s/%s", r.URL.Path, k.Name)
@@ -139,18 +141,30 @@ func (s *Server) updateCustomDataKind(w http.ResponseWriter, r *http.Request) {
 	k := customdata.Kind{}
 	codectool.MustDecode(r.Body, &k)
 
-	err := s.cds.PutKind(&k, true)
+	old, err := s.cds.GetKind(k.Name)
 	if err != nil {
 		ClusterPanic(err)
 	}
+
+	err = s.cds.PutKind(&k, true)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	s.audit(r, AuditResourceCustomDataKind, "", k.Name, old, &k)
 }
 
 func (s *Server) deleteCustomDataKind(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
-	err := s.cds.DeleteKind(name)
+	old, err := s.cds.GetKind(name)
 	if err != nil {
 		ClusterPanic(err)
 	}
+
+	err = s.cds.DeleteKind(name)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	s.audit(r, AuditResourceCustomDataKind, "", name, old, nil)
 }
 
 func (s *Server) listCustomData(w http.ResponseWriter, r *http.Request) {
@@ -186,6 +200,7 @@ func (s *Server) createCustomData(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		ClusterPanic(err)
 	}
+	s.audit(r, AuditResourceCustomData, kind, id, nil, data)
 
 	w.WriteHeader(http.StatusCreated)
 	location := fmt.Sprintf("%s/%s", r.URL.Path, id)
@@ -198,19 +213,38 @@ func (s *Server) updateCustomData(w http.ResponseWriter, r *http.Request) {
 	data := customdata.Data{}
 	codectool.MustDecode(r.Body, &data)
 
-	_, err := s.cds.PutData(kind, data, true)
+	var old customdata.Data
+	k, err := s.cds.GetKind(kind)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	if k != nil {
+		old, err = s.cds.GetData(kind, k.DataID(data))
+		if err != nil {
+			ClusterPanic(err)
+		}
+	}
+
+	id, err := s.cds.PutData(kind, data, true)
 	if err != nil {
 		ClusterPanic(err)
 	}
+	s.audit(r, AuditResourceCustomData, kind, id, old, data)
 }
 
 func (s *Server) deleteCustomData(w http.ResponseWriter, r *http.Request) {
 	kind := chi.URLParam(r, "kind")
 	id := chi.URLParam(r, "id")
-	err := s.cds.DeleteData(kind, id)
+	old, err := s.cds.GetData(kind, id)
 	if err != nil {
 		ClusterPanic(err)
 	}
+
+	err = s.cds.DeleteData(kind, id)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	s.audit(r, AuditResourceCustomData, kind, id, old, nil)
 }
 
 func (s *Server) batchUpdateCustomData(w http.ResponseWriter, r *http.Request) {
@@ -219,6 +253,43 @@ func (s *Server) batchUpdateCustomData(w http.ResponseWriter, r *http.Request) {
 	var cr ChangeRequest
 	codectool.MustDecode(r.Body, &cr)
 
+	k, err := s.cds.GetKind(kind)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	if k == nil {
+		ClusterPanic(fmt.Errorf("kind %s not found", kind))
+	}
+
+	// before and after are the data changed by the request, indexed by ID.
+	before, after := map[string]customdata.Data{}, map[string]customdata.Data{}
+	if cr.Rebuild {
+		all, err := s.cds.ListData(kind)
+		if err != nil {
+			ClusterPanic(err)
+		}
+		for _, data := range all {
+			before[k.DataID(data)] = data
+		}
+	} else {
+		ids := append([]string{}, cr.Delete...)
+		for _, data := range cr.List {
+			ids = append(ids, k.DataID(data))
+		}
+		for _, id := range ids {
+			data, err := s.cds.GetData(kind, id)
+			if err != nil {
+				ClusterPanic(err)
+			}
+			if data != nil {
+				before[id] = data
+			}
+		}
+	}
+	for _, data := range cr.List {
+		after[k.DataID(data)] = data
+	}
+
 	if cr.Rebuild {
 		err := s.cds.DeleteAllData(kind)
 		if err != nil {
@@ -226,8 +297,33 @@ func (s *Server) batchUpdateCustomData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err := s.cds.BatchUpdateData(kind, cr.Delete, cr.List)
+	err = s.cds.BatchUpdateData(kind, cr.Delete, cr.List)
 	if err != nil {
 		ClusterPanic(err)
 	}
+	s.auditCustomDataChanges(r, kind, before, after)
+}
+
+// auditCustomDataChanges records an audit entry for each custom data
+// changed by a batch update, before and after are indexed by ID.
+func (s *Server) auditCustomDataChanges(r *http.Request, kind string, before, after map[string]customdata.Data) {
+	ids := make([]string, 0, len(before)+len(after))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	for id := range after {
+		if _, ok := before[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		old, data := before[id], after[id]
+		// data kept as is by a rebuild is not a change.
+		if old != nil && data != nil && auditDiff(old, data) == "" {
+			continue
+		}
+		s.audit(r, AuditResourceCustomData, kind, id, old, data)
+	}
 }
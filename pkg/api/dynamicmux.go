@@ -85,35 +85,36 @@ func (m *dynamicMux) reloadAPIs() {
 		for _, api := range apiGroup.Entries {
 			pathV1 := APIPrefixV1 + api.Path
 			pathV2 := APIPrefixV2 + api.Path
+			handler := m.server.auth.wrap(apiGroup.Group, api)
 
 			switch api.Method {
 			case "GET":
-				router.Get(pathV1, api.Handler)
-				router.Get(pathV2, api.Handler)
+				router.Get(pathV1, handler)
+				router.Get(pathV2, handler)
 			case "HEAD":
-				router.Head(pathV1, api.Handler)
-				router.Head(pathV2, api.Handler)
+				router.Head(pathV1, handler)
+				router.Head(pathV2, handler)
 			case "PUT":
-				router.Put(pathV1, api.Handler)
-				router.Put(pathV2, api.Handler)
+				router.Put(pathV1, handler)
+				router.Put(pathV2, handler)
 			case "POST":
-				router.Post(pathV1, api.Handler)
-				router.Post(pathV2, api.Handler)
+				router.Post(pathV1, handler)
+				router.Post(pathV2, handler)
 			case "PATCH":
-				router.Patch(pathV1, api.Handler)
-				router.Patch(pathV2, api.Handler)
+				router.Patch(pathV1, handler)
+				router.Patch(pathV2, handler)
 			case "DELETE":
-				router.Delete(pathV1, api.Handler)
-				router.Delete(pathV2, api.Handler)
+				router.Delete(pathV1, handler)
+				router.Delete(pathV2, handler)
 			case "CONNECT":
-				router.Connect(pathV1, api.Handler)
-				router.Connect(pathV2, api.Handler)
+				router.Connect(pathV1, handler)
+				router.Connect(pathV2, handler)
 			case "OPTIONS":
-				router.Options(pathV1, api.Handler)
-				router.Options(pathV2, api.Handler)
+				router.Options(pathV1, handler)
+				router.Options(pathV2, handler)
 			case "TRACE":
-				router.Trace(pathV1, api.Handler)
-				router.Trace(pathV2, api.Handler)
+				router.Trace(pathV1, handler)
+				router.Trace(pathV2, handler)
 			default:
 				logger.Errorf("BUG: group %s unsupported method: %s",
 					apiGroup.Group, api.Method)
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+// MemberTokenHeader is the header carrying the member token, which
+// authenticates admin API requests sent by other members of the cluster.
+const MemberTokenHeader = "X-Easegress-Member-Token"
+
+// memberPrincipal is the principal of requests authenticated by the
+// member token.
+const memberPrincipal = "cluster-member"
+
+// MemberTokenLoader loads the token shared by all members of the cluster,
+// the token is created by the first member which loads it.
+type MemberTokenLoader struct {
+	cls   cluster.Cluster
+	mutex sync.Mutex
+	token string
+}
+
+// NewMemberTokenLoader creates a MemberTokenLoader.
+func NewMemberTokenLoader(cls cluster.Cluster) *MemberTokenLoader {
+	return &MemberTokenLoader{cls: cls}
+}
+
+// Token returns the member token, it is cached once loaded.
+func (l *MemberTokenLoader) Token() (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.token != "" {
+		return l.token, nil
+	}
+
+	key := l.cls.Layout().MemberTokenKey()
+	var token string
+	err := l.cls.STM(func(stm concurrency.STM) error {
+		token = stm.Get(key)
+		if token != "" {
+			return nil
+		}
+
+		buff := make([]byte, 32)
+		if _, err := rand.Read(buff); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(buff)
+		stm.Put(key, token)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	l.token = token
+	return token, nil
+}
+
+// verify reports whether token is the member token.
+func (l *MemberTokenLoader) verify(token string) bool {
+	expected, err := l.Token()
+	if err != nil {
+		logger.Errorf("load member token failed: %v", err)
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
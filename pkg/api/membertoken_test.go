@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberTokenLoader(t *testing.T) {
+	assert := assert.New(t)
+
+	s, kvs := newTestServer()
+
+	token, err := NewMemberTokenLoader(s.cluster).Token()
+	assert.NoError(err)
+	assert.Len(token, 64)
+	assert.Equal(token, kvs[s.cluster.Layout().MemberTokenKey()])
+
+	// other members load the same token.
+	l := NewMemberTokenLoader(s.cluster)
+	other, err := l.Token()
+	assert.NoError(err)
+	assert.Equal(token, other)
+	assert.True(l.verify(token))
+	assert.False(l.verify("bad-token"))
+}
+
+func TestAuthorizerMemberToken(t *testing.T) {
+	assert := assert.New(t)
+
+	s, _ := newTestServer()
+	a, err := newAuthorizerFromConfig(&AuthConfig{
+		Users: []*AuthUser{{Name: "admin", Tokens: []string{"admin-token"}}},
+	})
+	assert.NoError(err)
+	a.memberToken = NewMemberTokenLoader(s.cluster)
+	token, err := a.memberToken.Token()
+	assert.NoError(err)
+
+	var principal string
+	entry := &Entry{Handler: func(w http.ResponseWriter, r *http.Request) {
+		principal = r.Context().Value(principalKey{}).(string)
+	}}
+	serve := func(entry *Entry, setup func(r *http.Request)) int {
+		r := httptest.NewRequest(http.MethodPost, "http://localhost"+APIPrefixV2+"/mqttproxy/demo/topics/publish", nil)
+		setup(r)
+		w := httptest.NewRecorder()
+		a.wrap("demo", entry)(w, r)
+		return w.Code
+	}
+	memberToken := func(token string) func(r *http.Request) {
+		return func(r *http.Request) {
+			r.Header.Set(MemberTokenHeader, token)
+		}
+	}
+
+	// members can't access APIs other than internal ones.
+	assert.Equal(http.StatusForbidden, serve(entry, memberToken(token)))
+	// users without the permission can't access internal APIs.
+	internal := &Entry{Handler: entry.Handler, Internal: true}
+	assert.Equal(http.StatusForbidden, serve(internal, func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer admin-token")
+	}))
+
+	assert.Equal(http.StatusOK, serve(internal, memberToken(token)))
+	assert.Equal(memberPrincipal, principal)
+	assert.Equal(http.StatusUnauthorized, serve(internal, memberToken("bad-token")))
+
+	_, err = newAuthorizerFromConfig(&AuthConfig{Users: []*AuthUser{{Name: memberPrincipal}}})
+	assert.Error(err)
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
@@ -104,6 +105,13 @@ func (s *Server) readObjectSpec(w http.ResponseWriter, r *http.Request) (*superv
 	return spec, err
 }
 
+// isDryRun returns whether the request is a dry run, which validates the
+// request and returns the result without persisting anything.
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	return dryRun
+}
+
 func (s *Server) upgradeConfigVersion(w http.ResponseWriter, r *http.Request) {
 	version := s._plusOneVersion()
 	w.Header().Set(ConfigVersionKey, fmt.Sprintf("%d", version))
@@ -127,8 +135,15 @@ func (s *Server) createObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		WriteBody(w, r, spec)
+		return
+	}
+
 	s._putObject(spec)
+	s._putObjectRevision(r, name, nil, spec.RawSpec(), 0)
 	s.upgradeConfigVersion(w, r)
+	s.audit(r, AuditResourceObject, spec.Kind(), name, nil, spec)
 
 	w.WriteHeader(http.StatusCreated)
 	location := fmt.Sprintf("%s/%s", r.URL.Path, name)
@@ -148,7 +163,9 @@ func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s._deleteObject(name)
+	s._deleteObjectRevisions(name)
 	s.upgradeConfigVersion(w, r)
+	s.audit(r, AuditResourceObject, spec.Kind(), name, spec, nil)
 }
 
 func (s *Server) getObject(w http.ResponseWriter, r *http.Request) {
@@ -190,8 +207,15 @@ func (s *Server) updateObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		WriteBody(w, r, spec)
+		return
+	}
+
 	s._putObject(spec)
+	s._putObjectRevision(r, name, existedSpec.RawSpec(), spec.RawSpec(), 0)
 	s.upgradeConfigVersion(w, r)
+	s.audit(r, AuditResourceObject, spec.Kind(), name, existedSpec, spec)
 }
 
 func (s *Server) listObjects(w http.ResponseWriter, r *http.Request) {
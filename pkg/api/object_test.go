@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	for url, expected := range map[string]bool{
+		"/apis/v2/objects":                 false,
+		"/apis/v2/objects?dryRun=true":     true,
+		"/apis/v2/objects?dryRun=1":        true,
+		"/apis/v2/objects?dryRun=false":    false,
+		"/apis/v2/objects/demo?dryRun=abc": false,
+	} {
+		req := httptest.NewRequest("POST", url, nil)
+		assert.Equal(expected, isDryRun(req), url)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+// maxObjectRevisions is the max number of revisions kept for an object,
+// the oldest revisions are removed when it is exceeded.
+const maxObjectRevisions = 10
+
+// ObjectRevision is a revision of the spec of an object.
+type ObjectRevision struct {
+	Revision  int64     `json:"revision"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal,omitempty"`
+	// RollbackOf is the revision rolled back to, it is zero if the
+	// revision is not created by a rollback.
+	RollbackOf int64                  `json:"rollbackOf,omitempty"`
+	Spec       map[string]interface{} `json:"spec"`
+}
+
+func (s *Server) revisionAPIEntries() []*Entry {
+	return []*Entry{
+		{
+			Path:    ObjectPrefix + "/{name}/revisions",
+			Method:  http.MethodGet,
+			Handler: s.listObjectRevisions,
+		},
+		{
+			Path:    ObjectPrefix + "/{name}/revisions/{revision}",
+			Method:  http.MethodGet,
+			Handler: s.getObjectRevision,
+		},
+		{
+			Path:    ObjectPrefix + "/{name}/rollback",
+			Method:  http.MethodPost,
+			Handler: s.rollbackObject,
+		},
+	}
+}
+
+func parseRevision(s string) (int64, error) {
+	revision, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, fmt.Errorf("invalid revision %s: must be a positive integer", s)
+	}
+	return revision, nil
+}
+
+// _listObjectRevisions returns the revisions of the object, sorted by
+// revision number.
+func (s *Server) _listObjectRevisions(name string) []*ObjectRevision {
+	kvs, err := s.cluster.GetPrefix(s.cluster.Layout().ConfigRevisionPrefix(name))
+	if err != nil {
+		ClusterPanic(err)
+	}
+
+	revisions := make([]*ObjectRevision, 0, len(kvs))
+	for _, v := range kvs {
+		revision := &ObjectRevision{}
+		err = codectool.UnmarshalJSON([]byte(v), revision)
+		if err != nil {
+			panic(fmt.Errorf("unmarshal %s to json failed: %v", v, err))
+		}
+		revisions = append(revisions, revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+
+	return revisions
+}
+
+func (s *Server) _getObjectRevision(name string, revision int64) *ObjectRevision {
+	value, err := s.cluster.Get(s.cluster.Layout().ConfigRevisionKey(name, revision))
+	if err != nil {
+		ClusterPanic(err)
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	rev := &ObjectRevision{}
+	err = codectool.UnmarshalJSON([]byte(*value), rev)
+	if err != nil {
+		panic(fmt.Errorf("unmarshal %s to json failed: %v", *value, err))
+	}
+
+	return rev
+}
+
+// _putObjectRevision saves the spec as a new revision of the object, and
+// removes the oldest revisions if there are more than maxObjectRevisions.
+// The previous spec is saved first if the object has no revisions, which
+// happens to objects created before revisions are introduced.
+func (s *Server) _putObjectRevision(r *http.Request, name string, previous, spec map[string]interface{}, rollbackOf int64) {
+	prefix := s.cluster.Layout().ConfigRevisionPrefix(name)
+	kvs, err := s.cluster.GetWithOp(prefix, cluster.OpPrefix, cluster.OpKeysOnly)
+	if err != nil {
+		ClusterPanic(err)
+	}
+
+	keys := make([]string, 0, len(kvs)+1)
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var latest int64
+	if len(keys) > 0 {
+		latest, err = strconv.ParseInt(strings.TrimPrefix(keys[len(keys)-1], prefix), 10, 64)
+		if err != nil {
+			panic(fmt.Errorf("bad revision key %s: %v", keys[len(keys)-1], err))
+		}
+	}
+
+	changes := map[string]*string{}
+	add := func(revision *ObjectRevision) {
+		value := string(codectool.MustMarshalJSON(revision))
+		key := s.cluster.Layout().ConfigRevisionKey(name, revision.Revision)
+		changes[key] = &value
+		keys = append(keys, key)
+	}
+
+	now := time.Now()
+	if latest == 0 && previous != nil {
+		latest++
+		add(&ObjectRevision{Revision: latest, Time: now, Spec: previous})
+	}
+	add(&ObjectRevision{
+		Revision:   latest + 1,
+		Time:       now,
+		Principal:  principal(r),
+		RollbackOf: rollbackOf,
+		Spec:       spec,
+	})
+
+	if len(keys) > maxObjectRevisions {
+		for _, k := range keys[:len(keys)-maxObjectRevisions] {
+			changes[k] = nil
+		}
+	}
+
+	err = s.cluster.PutAndDelete(changes)
+	if err != nil {
+		ClusterPanic(err)
+	}
+}
+
+func (s *Server) _deleteObjectRevisions(name string) {
+	err := s.cluster.DeletePrefix(s.cluster.Layout().ConfigRevisionPrefix(name))
+	if err != nil {
+		ClusterPanic(err)
+	}
+}
+
+func (s *Server) listObjectRevisions(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// No need to lock.
+
+	if s._getObject(name) == nil {
+		HandleAPIError(w, r, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	WriteBody(w, r, s._listObjectRevisions(name))
+}
+
+func (s *Server) getObjectRevision(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	revision, err := parseRevision(chi.URLParam(r, "revision"))
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// No need to lock.
+
+	rev := s._getObjectRevision(name, revision)
+	if rev == nil {
+		HandleAPIError(w, r, http.StatusNotFound, fmt.Errorf("revision %d of %s not found", revision, name))
+		return
+	}
+
+	WriteBody(w, r, rev)
+}
+
+func (s *Server) rollbackObject(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	revision, err := parseRevision(r.URL.Query().Get("revision"))
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	existedSpec := s._getObject(name)
+	if existedSpec == nil {
+		HandleAPIError(w, r, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	rev := s._getObjectRevision(name, revision)
+	if rev == nil {
+		HandleAPIError(w, r, http.StatusNotFound, fmt.Errorf("revision %d of %s not found", revision, name))
+		return
+	}
+
+	spec, err := s.super.NewSpec(string(codectool.MustMarshalJSON(rev.Spec)))
+	if err != nil {
+		HandleAPIError(w, r, http.StatusBadRequest, fmt.Errorf("invalid spec of revision %d: %v", revision, err))
+		return
+	}
+
+	if existedSpec.Kind() != spec.Kind() {
+		HandleAPIError(w, r, http.StatusBadRequest,
+			fmt.Errorf("different kinds: %s, %s",
+				existedSpec.Kind(), spec.Kind()))
+		return
+	}
+
+	s._putObject(spec)
+	s._putObjectRevision(r, name, existedSpec.RawSpec(), spec.RawSpec(), revision)
+	s.upgradeConfigVersion(w, r)
+	s.audit(r, AuditResourceObject, spec.Kind(), name, existedSpec, spec)
+
+	WriteBody(w, r, spec)
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectRevisions(t *testing.T) {
+	assert := assert.New(t)
+
+	s, kvs := newTestServer()
+
+	spec := func(port int) map[string]interface{} {
+		return map[string]interface{}{"name": "demo", "kind": "HTTPServer", "port": port}
+	}
+
+	req := httptest.NewRequest("PUT", "/apis/v2/objects/demo", nil)
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, "alice"))
+
+	// an object created before revisions are introduced.
+	s._putObjectRevision(req, "demo", spec(80), spec(81), 0)
+	revisions := s._listObjectRevisions("demo")
+	assert.Len(revisions, 2)
+	assert.Equal(int64(1), revisions[0].Revision)
+	assert.Equal("", revisions[0].Principal)
+	assert.EqualValues(80, revisions[0].Spec["port"])
+	assert.Equal(int64(2), revisions[1].Revision)
+	assert.Equal("alice", revisions[1].Principal)
+	assert.EqualValues(81, revisions[1].Spec["port"])
+
+	// the previous spec is ignored if there are revisions.
+	s._putObjectRevision(req, "demo", spec(81), spec(82), 1)
+	revisions = s._listObjectRevisions("demo")
+	assert.Len(revisions, 3)
+	assert.Equal(int64(1), revisions[2].RollbackOf)
+
+	rev := s._getObjectRevision("demo", 3)
+	assert.NotNil(rev)
+	assert.EqualValues(82, rev.Spec["port"])
+	assert.Nil(s._getObjectRevision("demo", 4))
+
+	// the oldest revisions are removed.
+	for i := 0; i < maxObjectRevisions; i++ {
+		s._putObjectRevision(req, "demo", nil, spec(90+i), 0)
+	}
+	revisions = s._listObjectRevisions("demo")
+	assert.Len(revisions, maxObjectRevisions)
+	assert.Equal(int64(4), revisions[0].Revision)
+	assert.Equal(int64(3+maxObjectRevisions), revisions[maxObjectRevisions-1].Revision)
+	assert.Nil(s._getObjectRevision("demo", 3))
+
+	// revisions of other objects are not affected.
+	s._putObjectRevision(req, "demo2", nil, spec(80), 0)
+	assert.Len(s._listObjectRevisions("demo2"), 1)
+
+	s._deleteObjectRevisions("demo")
+	assert.Len(s._listObjectRevisions("demo"), 0)
+	assert.Len(kvs, 1)
+}
+
+func TestParseRevision(t *testing.T) {
+	assert := assert.New(t)
+
+	revision, err := parseRevision("12")
+	assert.NoError(err)
+	assert.Equal(int64(12), revision)
+
+	for _, s := range []string{"", "0", "-1", "abc"} {
+		_, err = parseRevision(s)
+		assert.Error(err, s)
+	}
+}
@@ -19,6 +19,7 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -41,6 +42,12 @@ type (
 		super   *supervisor.Supervisor
 		cds     *customdata.Store
 		profile pprof.Profile
+		auth    *authorizer
+		tls     *tlsLoader
+
+		// done is closed when the server is closing, to stop the
+		// long-running requests, e.g. the watch requests.
+		done chan struct{}
 
 		mutex      cluster.Mutex
 		mutexMutex sync.Mutex
@@ -57,6 +64,9 @@ type (
 		Path    string           `json:"path"`
 		Method  string           `json:"method"`
 		Handler http.HandlerFunc `json:"-"`
+		// Internal means the API is also called by other members of the
+		// cluster, which are authenticated by the member token.
+		Internal bool `json:"internal,omitempty"`
 	}
 )
 
@@ -67,11 +77,21 @@ func MustNewServer(opt *option.Options, cls cluster.Cluster, super *supervisor.S
 		cluster: cls,
 		super:   super,
 		profile: profile,
+		done:    make(chan struct{}),
+	}
+	auth, err := newAuthorizer(opt.APIAuthConfigFile)
+	if err != nil {
+		panic(fmt.Errorf("load api auth config file %s failed: %v", opt.APIAuthConfigFile, err))
+	}
+	if auth != nil {
+		auth.memberToken = NewMemberTokenLoader(cls)
 	}
+	s.auth = auth
+
 	s.router = newDynamicMux(s)
 	s.server = http.Server{Addr: opt.APIAddr, Handler: s.router}
 
-	_, err := s.getMutex()
+	_, err = s.getMutex()
 	if err != nil {
 		logger.Errorf("get cluster mutex %s failed: %v", lockKey, err)
 	}
@@ -82,9 +102,22 @@ func MustNewServer(opt *option.Options, cls cluster.Cluster, super *supervisor.S
 
 	s.registerAPIs()
 
+	if opt.APICertFile != "" {
+		s.tls, err = newTLSLoader(opt.APICertFile, opt.APIKeyFile, opt.APIClientCAFile,
+			opt.APIMinTLSVersion, opt.APIAuthConfigFile != "")
+		if err != nil {
+			panic(fmt.Errorf("load tls config of api server failed: %v", err))
+		}
+		s.server.TLSConfig = s.tls.tlsConfig()
+	}
+
 	go func() {
 		logger.Infof("api server running in %s", opt.APIAddr)
-		s.server.ListenAndServe()
+		if s.tls != nil {
+			s.server.ListenAndServeTLS("", "")
+		} else {
+			s.server.ListenAndServe()
+		}
 	}()
 
 	return s
@@ -97,11 +130,15 @@ func (s *Server) Close(wg *sync.WaitGroup) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	close(s.done)
 	if err := s.server.Shutdown(ctx); err != nil {
 		logger.Errorf("gracefully shutdown the server failed: %v", err)
 	}
 
 	s.router.close()
+	if s.tls != nil {
+		s.tls.close()
+	}
 
 	logger.Infof("server stopped")
 }
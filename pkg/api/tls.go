@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/option"
+)
+
+// TLSVersions is the supported minimum TLS versions of the admin API.
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type (
+	// tlsLoader loads the certificate and the client CA of the admin API
+	// server, and reloads them when the files change, so that the
+	// certificates could be rotated without restarting the server.
+	tlsLoader struct {
+		certFile     string
+		keyFile      string
+		clientCAFile string
+		minVersion   uint16
+		clientAuth   tls.ClientAuthType
+
+		mutex  sync.RWMutex
+		config *tls.Config
+
+		watcher *fsnotify.Watcher
+		done    chan struct{}
+	}
+)
+
+func newTLSLoader(certFile, keyFile, clientCAFile, minVersion string, optionalClientCert bool) (*tlsLoader, error) {
+	l := &tlsLoader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		minVersion:   TLSVersions[minVersion],
+		done:         make(chan struct{}),
+	}
+
+	if l.minVersion == 0 {
+		l.minVersion = tls.VersionTLS12
+	}
+
+	// client certificates are optional if they are one of the methods of
+	// authentication, otherwise, they are the only way to protect the
+	// admin API.
+	if clientCAFile != "" {
+		if optionalClientCert {
+			l.clientAuth = tls.VerifyClientCertIfGiven
+		} else {
+			l.clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	if err := l.watch(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *tlsLoader) load() error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate failed: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   l.minVersion,
+		ClientAuth:   l.clientAuth,
+	}
+
+	if l.clientCAFile != "" {
+		pem, err := os.ReadFile(l.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client CA failed: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificate in client CA file %s", l.clientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	l.mutex.Lock()
+	l.config = config
+	l.mutex.Unlock()
+
+	return nil
+}
+
+// watch watches the directories of the files instead of the files, as
+// the files may be replaced by renaming, e.g. Kubernetes secrets.
+func (l *tlsLoader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]struct{}{}
+	for _, f := range []string{l.certFile, l.keyFile, l.clientCAFile} {
+		if f != "" {
+			dirs[filepath.Dir(f)] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	l.watcher = watcher
+	go l.run()
+	return nil
+}
+
+func (l *tlsLoader) run() {
+	for {
+		select {
+		case <-l.done:
+			return
+		case _, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			// the previous certificates are kept if failed, the files
+			// may be partially written and another event follows.
+			if err := l.load(); err != nil {
+				logger.Errorf("reload tls config of api server failed: %v", err)
+			}
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("watch tls files of api server failed: %v", err)
+		}
+	}
+}
+
+func (l *tlsLoader) current() *tls.Config {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.config
+}
+
+// tlsConfig returns the TLS config of the server, the config of each
+// connection is the latest loaded one. GetCertificate is required by
+// http.Server, though it is not used as GetConfigForClient always
+// returns a config with certificates.
+func (l *tlsLoader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: l.minVersion,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &l.current().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return l.current(), nil
+		},
+	}
+}
+
+func (l *tlsLoader) close() {
+	close(l.done)
+	l.watcher.Close()
+}
+
+// ClientTLSConfig returns the TLS config for a member to access the admin
+// API of other members, it is nil if the admin API is not served over TLS.
+//
+// Members are expected to share the same CA: the certificates of other
+// members are verified with the system CAs and the client CA of the admin
+// API, and the certificate of the admin API is presented as the client
+// certificate, which is loaded on each handshake to pick up rotations.
+func ClientTLSConfig(opt *option.Options) (*tls.Config, error) {
+	if opt.APICertFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion: TLSVersions[opt.APIMinTLSVersion],
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(opt.APICertFile, opt.APIKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate failed: %v", err)
+			}
+			return &cert, nil
+		},
+	}
+
+	if opt.APIClientCAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opt.APIClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA failed: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate in client CA file %s", opt.APIClientCAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
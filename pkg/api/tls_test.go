@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/option"
+)
+
+func writeCert(t *testing.T, dir, cn string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	os.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0o600)
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0o600)
+	os.WriteFile(filepath.Join(dir, "ca.pem"), certPEM, 0o600)
+}
+
+func TestTLSLoader(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeCert(t, dir, "first")
+
+	l, err := newTLSLoader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"),
+		filepath.Join(dir, "ca.pem"), "1.3", true)
+	assert.NoError(err)
+	defer l.close()
+
+	config, err := l.tlsConfig().GetConfigForClient(nil)
+	assert.NoError(err)
+	assert.Equal(uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Equal(tls.VerifyClientCertIfGiven, config.ClientAuth)
+	assert.NotNil(config.ClientCAs)
+
+	commonName := func() string {
+		cert, _ := l.tlsConfig().GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+	assert.Equal("first", commonName())
+
+	writeCert(t, dir, "second")
+	assert.Eventually(func() bool {
+		return commonName() == "second"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = newTLSLoader(filepath.Join(dir, "none.pem"), filepath.Join(dir, "key.pem"), "", "", false)
+	assert.Error(err)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	opt := option.New()
+	config, err := ClientTLSConfig(opt)
+	assert.NoError(err)
+	assert.Nil(config)
+
+	dir := t.TempDir()
+	writeCert(t, dir, "member")
+	opt.APICertFile = filepath.Join(dir, "cert.pem")
+	opt.APIKeyFile = filepath.Join(dir, "key.pem")
+	opt.APIClientCAFile = filepath.Join(dir, "none.pem")
+	_, err = ClientTLSConfig(opt)
+	assert.Error(err)
+
+	opt.APIClientCAFile = filepath.Join(dir, "ca.pem")
+	config, err = ClientTLSConfig(opt)
+	assert.NoError(err)
+
+	// the server requires client certificates, like the admin API of
+	// another member.
+	l, err := newTLSLoader(opt.APICertFile, opt.APIKeyFile, opt.APIClientCAFile, "1.2", false)
+	assert.NoError(err)
+	defer l.close()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = l.tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, err := client.Get(server.URL)
+	assert.NoError(err)
+	if err == nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal("member", string(body))
+	}
+
+	// the server is not trusted without the CA.
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}
+	_, err = client.Get(server.URL)
+	assert.Error(err)
+}
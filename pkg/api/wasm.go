@@ -140,7 +140,9 @@ func (s *Server) wasmDeleteData(w http.ResponseWriter, r *http.Request) {
 	c.DeletePrefix(prefix)
 }
 
-func appendWasmAPI(s *Server, group *Group) {
+func wasmAPIGroup(s *Server) *Group {
+	group := &Group{Group: "wasm"}
+
 	entry := &Entry{
 		Path:    "/wasm/code",
 		Method:  http.MethodPost,
@@ -168,8 +170,10 @@ func appendWasmAPI(s *Server, group *Group) {
 		Handler: s.wasmDeleteData,
 	}
 	group.Entries = append(group.Entries, entry)
+
+	return group
 }
 
 func init() {
-	appendAddonAPIs = append(appendAddonAPIs, appendWasmAPI)
+	addonAPIs = append(addonAPIs, wasmAPIGroup)
 }
@@ -0,0 +1,341 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+const (
+	// WatchPrefix is the URL of the watch API.
+	WatchPrefix = "/watch"
+
+	// watchKeepAliveInterval is the interval to send comments to keep the
+	// connection alive, and to detect closed connections.
+	watchKeepAliveInterval = 15 * time.Second
+)
+
+// Names of the server-sent events of the watch API.
+const (
+	WatchEventObject = "object"
+	WatchEventStatus = "status"
+)
+
+// Types of watch events.
+const (
+	WatchEventTypeCreate = "create"
+	WatchEventTypeUpdate = "update"
+	WatchEventTypeDelete = "delete"
+)
+
+type (
+	// WatchEvent is the data of a server-sent event of the watch API.
+	WatchEvent struct {
+		Type string `json:"type"`
+		Kind string `json:"kind,omitempty"`
+		Name string `json:"name"`
+
+		// Namespace and Member are only for status events.
+		Namespace string `json:"namespace,omitempty"`
+		Member    string `json:"member,omitempty"`
+
+		Spec   map[string]interface{} `json:"spec,omitempty"`
+		Status map[string]interface{} `json:"status,omitempty"`
+	}
+
+	// watchStream converts the changes of the cluster to watch events.
+	watchStream struct {
+		kind   string
+		name   string
+		status bool
+
+		objectPrefix string
+		statusPrefix string
+
+		// objects and statuses are the latest values, used to
+		// distinguish creation from update and to drop duplicated
+		// events, kinds are the kinds of the objects.
+		objects  map[string]string
+		statuses map[string]string
+		kinds    map[string]string
+	}
+)
+
+func (s *Server) watchAPIEntries() []*Entry {
+	return []*Entry{
+		{
+			Path:    WatchPrefix,
+			Method:  http.MethodGet,
+			Handler: s.watch,
+		},
+	}
+}
+
+func (ws *watchStream) match(name string) bool {
+	if ws.name != "" && ws.name != name {
+		return false
+	}
+	if ws.kind != "" && ws.kind != ws.kinds[name] {
+		return false
+	}
+	return true
+}
+
+// objectEvent returns the event of the change of an object, value is nil
+// if the object is deleted. It returns nil if the change should not be
+// sent.
+func (ws *watchStream) objectEvent(key string, value *string) *WatchEvent {
+	name := strings.TrimPrefix(key, ws.objectPrefix)
+
+	if value == nil {
+		if _, exists := ws.objects[name]; !exists {
+			return nil
+		}
+
+		matched := ws.match(name)
+		kind := ws.kinds[name]
+		delete(ws.objects, name)
+		delete(ws.kinds, name)
+		if !matched {
+			return nil
+		}
+		return &WatchEvent{Type: WatchEventTypeDelete, Kind: kind, Name: name}
+	}
+
+	old, exists := ws.objects[name]
+	if exists && old == *value {
+		return nil
+	}
+
+	spec := map[string]interface{}{}
+	if err := codectool.UnmarshalJSON([]byte(*value), &spec); err != nil {
+		return nil
+	}
+	kind, _ := spec["kind"].(string)
+
+	ws.objects[name] = *value
+	ws.kinds[name] = kind
+	if !ws.match(name) {
+		return nil
+	}
+
+	event := &WatchEvent{Type: WatchEventTypeCreate, Kind: kind, Name: name, Spec: spec}
+	if exists {
+		event.Type = WatchEventTypeUpdate
+	}
+	return event
+}
+
+// statusEvent returns the event of the change of the status of an object
+// on a member, value is nil if the status is deleted. It returns nil if
+// the change should not be sent.
+func (ws *watchStream) statusEvent(key string, value *string) *WatchEvent {
+	// the key is in the format of namespace/name/member.
+	parts := strings.SplitN(strings.TrimPrefix(key, ws.statusPrefix), "/", 3)
+	if len(parts) != 3 {
+		return nil
+	}
+	namespace, name, member := parts[0], parts[1], parts[2]
+
+	old, exists := ws.statuses[key]
+	if value == nil {
+		if !exists {
+			return nil
+		}
+		delete(ws.statuses, key)
+	} else {
+		if exists && old == *value {
+			return nil
+		}
+		ws.statuses[key] = *value
+	}
+
+	if !ws.match(name) {
+		return nil
+	}
+
+	event := &WatchEvent{
+		Type:      WatchEventTypeUpdate,
+		Kind:      ws.kinds[name],
+		Name:      name,
+		Namespace: namespace,
+		Member:    member,
+	}
+	switch {
+	case value == nil:
+		event.Type = WatchEventTypeDelete
+	case !exists:
+		event.Type = WatchEventTypeCreate
+	}
+
+	if value != nil {
+		status := map[string]interface{}{}
+		if err := codectool.UnmarshalJSON([]byte(*value), &status); err != nil {
+			return nil
+		}
+		event.Status = status
+	}
+	return event
+}
+
+func writeWatchEvent(w io.Writer, name string, event *WatchEvent) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, codectool.MustMarshalJSON(event))
+	return err
+}
+
+// watch streams the changes of objects, and optionally their status, as
+// server-sent events. The current objects are sent as creation events
+// first.
+//
+// It watches the cluster rather than the object registry of the
+// supervisor, so slow clients never block the supervisor.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		HandleAPIError(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	q := r.URL.Query()
+	layout := s.cluster.Layout()
+	ws := &watchStream{
+		kind:         q.Get("kind"),
+		name:         q.Get("name"),
+		objectPrefix: layout.ConfigObjectPrefix(),
+		statusPrefix: layout.StatusObjectsPrefix(),
+		objects:      map[string]string{},
+		statuses:     map[string]string{},
+		kinds:        map[string]string{},
+	}
+	if v := q.Get("status"); v != "" {
+		status, err := strconv.ParseBool(v)
+		if err != nil {
+			HandleAPIError(w, r, http.StatusBadRequest, fmt.Errorf("invalid status %s: %v", v, err))
+			return
+		}
+		ws.status = status
+	}
+
+	// start watching before reading the current values, so that no
+	// change is missed, the duplicated ones are dropped by the stream.
+	watcher, err := s.cluster.Watcher()
+	if err != nil {
+		ClusterPanic(err)
+	}
+	defer watcher.Close()
+
+	objectChan, err := watcher.WatchPrefix(ws.objectPrefix)
+	if err != nil {
+		ClusterPanic(err)
+	}
+	var statusChan <-chan map[string]*string
+	if ws.status {
+		statusChan, err = watcher.WatchPrefix(ws.statusPrefix)
+		if err != nil {
+			ClusterPanic(err)
+		}
+	}
+
+	var events []*WatchEvent
+	var statusEvents []*WatchEvent
+	snapshot := func(prefix string, fn func(string, *string) *WatchEvent, events *[]*WatchEvent) {
+		kvs, err := s.cluster.GetPrefix(prefix)
+		if err != nil {
+			ClusterPanic(err)
+		}
+		keys := make([]string, 0, len(kvs))
+		for k := range kvs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := kvs[k]
+			if e := fn(k, &v); e != nil {
+				*events = append(*events, e)
+			}
+		}
+	}
+	snapshot(ws.objectPrefix, ws.objectEvent, &events)
+	if ws.status {
+		snapshot(ws.statusPrefix, ws.statusEvent, &statusEvents)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range events {
+		if writeWatchEvent(w, WatchEventObject, e) != nil {
+			return
+		}
+	}
+	for _, e := range statusEvents {
+		if writeWatchEvent(w, WatchEventStatus, e) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(watchKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if _, err = io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case kvs, ok := <-objectChan:
+			if !ok {
+				return
+			}
+			for k, v := range kvs {
+				if e := ws.objectEvent(k, v); e != nil {
+					if err = writeWatchEvent(w, WatchEventObject, e); err != nil {
+						return
+					}
+				}
+			}
+		case kvs, ok := <-statusChan:
+			if !ok {
+				return
+			}
+			for k, v := range kvs {
+				if e := ws.statusEvent(k, v); e != nil {
+					if err = writeWatchEvent(w, WatchEventStatus, e); err != nil {
+						return
+					}
+				}
+			}
+		}
+		flusher.Flush()
+	}
+}
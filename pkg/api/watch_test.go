@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/cluster/clustertest"
+)
+
+func newTestWatchStream(kind, name string) *watchStream {
+	layout := &cluster.Layout{}
+	return &watchStream{
+		kind:         kind,
+		name:         name,
+		status:       true,
+		objectPrefix: layout.ConfigObjectPrefix(),
+		statusPrefix: layout.StatusObjectsPrefix(),
+		objects:      map[string]string{},
+		statuses:     map[string]string{},
+		kinds:        map[string]string{},
+	}
+}
+
+func TestWatchStreamObjectEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := newTestWatchStream("HTTPServer", "")
+	layout := &cluster.Layout{}
+	key := layout.ConfigObjectKey("demo")
+	value := `{"name":"demo","kind":"HTTPServer","port":80}`
+
+	e := ws.objectEvent(key, &value)
+	assert.Equal(WatchEventTypeCreate, e.Type)
+	assert.Equal("HTTPServer", e.Kind)
+	assert.Equal("demo", e.Name)
+	assert.Equal(float64(80), e.Spec["port"])
+
+	// duplicated changes are dropped.
+	assert.Nil(ws.objectEvent(key, &value))
+
+	value = `{"name":"demo","kind":"HTTPServer","port":8080}`
+	e = ws.objectEvent(key, &value)
+	assert.Equal(WatchEventTypeUpdate, e.Type)
+
+	// objects of other kinds are filtered.
+	other := `{"name":"pipeline","kind":"Pipeline"}`
+	assert.Nil(ws.objectEvent(layout.ConfigObjectKey("pipeline"), &other))
+	assert.Nil(ws.objectEvent(layout.ConfigObjectKey("pipeline"), nil))
+
+	e = ws.objectEvent(key, nil)
+	assert.Equal(WatchEventTypeDelete, e.Type)
+	assert.Equal("HTTPServer", e.Kind)
+	assert.Nil(e.Spec)
+	assert.Nil(ws.objectEvent(key, nil))
+}
+
+func TestWatchStreamStatusEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := newTestWatchStream("", "demo")
+	layout := &cluster.Layout{}
+	spec := `{"name":"demo","kind":"HTTPServer"}`
+	ws.objectEvent(layout.ConfigObjectKey("demo"), &spec)
+
+	key := layout.StatusObjectsPrefix() + "default/demo/member-1"
+	status := `{"health":"ok"}`
+	e := ws.statusEvent(key, &status)
+	assert.Equal(WatchEventTypeCreate, e.Type)
+	assert.Equal("HTTPServer", e.Kind)
+	assert.Equal("default", e.Namespace)
+	assert.Equal("member-1", e.Member)
+	assert.Equal("ok", e.Status["health"])
+	assert.Nil(ws.statusEvent(key, &status))
+
+	status = `{"health":"bad"}`
+	e = ws.statusEvent(key, &status)
+	assert.Equal(WatchEventTypeUpdate, e.Type)
+
+	e = ws.statusEvent(key, nil)
+	assert.Equal(WatchEventTypeDelete, e.Type)
+
+	// status of other objects are filtered.
+	assert.Nil(ws.statusEvent(layout.StatusObjectsPrefix()+"default/other/member-1", &status))
+	// invalid keys are ignored.
+	assert.Nil(ws.statusEvent(layout.StatusObjectsPrefix()+"demo", &status))
+}
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	s, kvs := newTestServer()
+	s.done = make(chan struct{})
+	layout := &cluster.Layout{}
+	kvs[layout.ConfigObjectKey("demo")] = `{"name":"demo","kind":"HTTPServer"}`
+
+	objectChan := make(chan map[string]*string)
+	watcher := clustertest.NewMockedWatcher()
+	watcher.MockedWatchPrefix = func(prefix string) (<-chan map[string]*string, error) {
+		return objectChan, nil
+	}
+	s.cluster.(*clustertest.MockedCluster).MockedWatcher = func() (cluster.Watcher, error) {
+		return watcher, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/apis/v2/watch", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		s.watch(w, req)
+		close(done)
+	}()
+
+	value := `{"name":"pipeline","kind":"Pipeline"}`
+	objectChan <- map[string]*string{layout.ConfigObjectKey("pipeline"): &value}
+	objectChan <- map[string]*string{layout.ConfigObjectKey("demo"): nil}
+	cancel()
+	<-done
+
+	assert.Equal("text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(body, `event: object
+data: {"type":"create","kind":"HTTPServer","name":"demo","spec":{"kind":"HTTPServer","name":"demo"}}
+
+`)
+	assert.Contains(body, `data: {"type":"create","kind":"Pipeline","name":"pipeline"`)
+	assert.Contains(body, `data: {"type":"delete","kind":"HTTPServer","name":"demo"}`)
+}
@@ -37,7 +37,6 @@ const (
 	// 8GB
 	quotaBackendBytes = 8 * 1024 * 1024 * 1024
 
-	maxTxnOps       = 10240
 	maxRequestBytes = 10 * 1024 * 1024 // 10MB
 
 	// Threshold for number of changes etcd stores in memory before creating a new snapshot.
@@ -45,6 +44,10 @@ const (
 	snapshotCount = 5000
 )
 
+// MaxTxnOps is the max number of operations in a transaction, a larger
+// transaction is rejected by the cluster.
+const MaxTxnOps = 10240
+
 var (
 	autoCompactionRetention = "10"
 	autoCompactionMode      = embed.CompactorModeRevision
@@ -91,7 +94,7 @@ func CreateStaticClusterEtcdConfig(opt *option.Options) (*embed.Config, error) {
 	ec.AutoCompactionMode = autoCompactionMode
 	ec.AutoCompactionRetention = autoCompactionRetention
 	ec.QuotaBackendBytes = quotaBackendBytes
-	ec.MaxTxnOps = maxTxnOps
+	ec.MaxTxnOps = MaxTxnOps
 	ec.MaxRequestBytes = maxRequestBytes
 	ec.SnapshotCount = snapshotCount
 	ec.Logger = "zap"
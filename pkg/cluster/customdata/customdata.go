@@ -43,7 +43,8 @@ type Kind struct {
 	JSONSchema dynamicobject.DynamicObject `json:"jsonSchema" jsonschema:"omitempty"`
 }
 
-func (k *Kind) dataID(data Data) string {
+// DataID returns the ID of the data.
+func (k *Kind) DataID(data Data) string {
 	var id string
 	if k.IDField == "" {
 		id, _ = data["name"].(string)
@@ -225,7 +226,7 @@ func (s *Store) PutData(kind string, data Data, update bool) (string, error) {
 		return "", fmt.Errorf("kind %s not found", kind)
 	}
 
-	id := k.dataID(data)
+	id := k.DataID(data)
 	if id == "" {
 		return "", fmt.Errorf("data id is empty")
 	}
@@ -292,7 +293,7 @@ func (s *Store) BatchUpdateData(kind string, del []string, update []Data) error
 	}
 
 	for _, data := range update {
-		if k.dataID(data) == "" {
+		if k.DataID(data) == "" {
 			return fmt.Errorf("data id is empty")
 		}
 	}
@@ -304,7 +305,7 @@ func (s *Store) BatchUpdateData(kind string, del []string, update []Data) error
 		}
 
 		for _, data := range update {
-			id := k.dataID(data)
+			id := k.DataID(data)
 			buf, err := codectool.MarshalJSON(data)
 			if err != nil {
 				return fmt.Errorf("BUG: marshal %#v to json failed: %v", data, err)
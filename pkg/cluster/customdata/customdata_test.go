@@ -38,12 +38,12 @@ func TestDataID(t *testing.T) {
 	data["name"] = "abc"
 	data["key"] = "key"
 
-	if id := k.dataID(data); id != "abc" {
+	if id := k.DataID(data); id != "abc" {
 		t.Errorf("data ID should be 'abc` instead of %q", id)
 	}
 
 	k.IDField = "key"
-	if id := k.dataID(data); id != "key" {
+	if id := k.DataID(data); id != "key" {
 		t.Errorf("data ID should be 'key` instead of %q", id)
 	}
 }
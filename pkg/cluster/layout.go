@@ -39,14 +39,24 @@ const (
 	configObjectPrefix   = "/config/objects/"
 	configObjectFormat   = "/config/objects/%s" // +objectName
 	configVersion        = "/config/version"
+	revisionPrefixFormat = "/config/revisions/%s/"      // +objectName
+	revisionFormat       = "/config/revisions/%s/%010d" // +objectName +revision
+	applySetFormat       = "/config/applysets/%s"       // +applySetName
 	wasmCodeEvent        = "/wasm/code"
 	wasmDataPrefixFormat = "/wasm/data/%s/%s/" // + pipelineName + filterName
 	customDataKindPrefix = "/custom-data-kinds/"
 	customDataPrefix     = "/custom-data/"
+	rateLimiterFormat    = "/ratelimiter/%s/%s/" // +pipelineName +filterName
+	auditPrefix          = "/audit/"
+	auditFormat          = "/audit/%s" // +recordID
 
 	// the cluster name of this eg group will be registered under this path in etcd
 	// any new member(primary or secondary ) will be rejected if it is configured a different cluster name
 	clusterNameKey = "/eg/cluster/name"
+
+	// the token shared by members to authenticate the admin API requests
+	// between them.
+	memberTokenKey = "/eg/cluster/member-token"
 )
 
 type (
@@ -82,6 +92,11 @@ func (l *Layout) ClusterNameKey() string {
 	return clusterNameKey
 }
 
+// MemberTokenKey returns the key of the member token.
+func (l *Layout) MemberTokenKey() string {
+	return memberTokenKey
+}
+
 // Lease returns the key of own member lease.
 func (l *Layout) Lease() string {
 	return fmt.Sprintf(leaseFormat, l.memberName)
@@ -132,6 +147,21 @@ func (l *Layout) ConfigObjectKey(name string) string {
 	return fmt.Sprintf(configObjectFormat, name)
 }
 
+// ConfigRevisionPrefix returns the prefix of the revisions of an object.
+func (l *Layout) ConfigRevisionPrefix(name string) string {
+	return fmt.Sprintf(revisionPrefixFormat, name)
+}
+
+// ConfigRevisionKey returns the key of a revision of an object.
+func (l *Layout) ConfigRevisionKey(name string, revision int64) string {
+	return fmt.Sprintf(revisionFormat, name, revision)
+}
+
+// ConfigApplySetKey returns the key of an apply set.
+func (l *Layout) ConfigApplySetKey(name string) string {
+	return fmt.Sprintf(applySetFormat, name)
+}
+
 // ConfigVersion returns the key of config version.
 func (l *Layout) ConfigVersion() string {
 	return configVersion
@@ -156,3 +186,18 @@ func (l *Layout) CustomDataPrefix() string {
 func (l *Layout) CustomDataKindPrefix() string {
 	return customDataKindPrefix
 }
+
+// RateLimiterPrefix returns the prefix of the shared data of a rate limiter
+func (l *Layout) RateLimiterPrefix(pipeline, name string) string {
+	return fmt.Sprintf(rateLimiterFormat, pipeline, name)
+}
+
+// AuditPrefix returns the prefix of audit records.
+func (l *Layout) AuditPrefix() string {
+	return auditPrefix
+}
+
+// AuditKey returns the key of an audit record.
+func (l *Layout) AuditKey(id string) string {
+	return fmt.Sprintf(auditFormat, id)
+}
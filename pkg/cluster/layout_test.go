@@ -69,4 +69,9 @@ func TestLayout(t *testing.T) {
 
 	assert.Equal(customDataPrefix, l.CustomDataPrefix())
 	assert.Equal(customDataKindPrefix, l.CustomDataKindPrefix())
+	assert.Equal("/config/revisions/demo/", l.ConfigRevisionPrefix("demo"))
+	assert.Equal("/config/revisions/demo/0000000012", l.ConfigRevisionKey("demo", 12))
+	assert.Equal("/config/applysets/demo", l.ConfigApplySetKey("demo"))
+	assert.Equal(auditPrefix, l.AuditPrefix())
+	assert.Equal("/audit/0001-member-1", l.AuditKey("0001-member-1"))
 }
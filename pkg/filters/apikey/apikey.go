@@ -0,0 +1,392 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apikey implements the APIKey filter, which authenticates
+// requests by API keys stored in custom data.
+package apikey
+
+import (
+	stdcontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/megaease/easegress/pkg/cluster/customdata"
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/filters"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/util/stringtool"
+)
+
+const (
+	// Kind is the kind of APIKey.
+	Kind = "APIKey"
+
+	resultInvalid = "invalid"
+
+	defaultHeader          = "X-API-Key"
+	defaultConsumerHeader  = "X-Consumer"
+	defaultConsumerDataKey = "APIKEY_CONSUMER"
+)
+
+var kind = &filters.Kind{
+	Name:        Kind,
+	Description: "APIKey authenticates requests by API keys stored in custom data.",
+	Results:     []string{resultInvalid},
+	DefaultSpec: func() filters.Spec {
+		return &Spec{
+			Header:          defaultHeader,
+			ConsumerHeader:  defaultConsumerHeader,
+			ConsumerDataKey: defaultConsumerDataKey,
+		}
+	},
+	CreateInstance: func(spec filters.Spec) filters.Filter {
+		return &APIKey{spec: spec.(*Spec)}
+	},
+}
+
+func init() {
+	filters.Register(kind)
+}
+
+type (
+	// APIKey is filter APIKey.
+	APIKey struct {
+		spec *Spec
+
+		// keys is a map[string]*apiKey, the key of the map is the hash
+		// of the API key.
+		keys    atomic.Value
+		stopCtx stdcontext.Context
+		cancel  stdcontext.CancelFunc
+	}
+
+	// Spec describes the APIKey.
+	Spec struct {
+		filters.BaseSpec `json:",inline"`
+
+		// CustomDataKind is the kind of custom data storing the API keys.
+		CustomDataKind string `json:"customDataKind" jsonschema:"required"`
+		// Header and Query are the request header and query parameter to
+		// read the API key from, the header takes precedence.
+		Header string `json:"header" jsonschema:"omitempty"`
+		Query  string `json:"query" jsonschema:"omitempty"`
+		// ConsumerHeader and ConsumerDataKey are the request header and the
+		// context data key to put the consumer name of a valid API key to.
+		ConsumerHeader  string `json:"consumerHeader" jsonschema:"omitempty"`
+		ConsumerDataKey string `json:"consumerDataKey" jsonschema:"omitempty"`
+		// KeepKey keeps the API key in the request after it is validated,
+		// by default the key is removed so that it is not sent upstream.
+		KeepKey bool `json:"keepKey" jsonschema:"omitempty"`
+	}
+
+	// Status is the status of APIKey.
+	Status struct {
+		Keys int `json:"keys"`
+	}
+
+	// apiKey is an API key loaded from custom data.
+	apiKey struct {
+		id           string
+		consumer     string
+		enabled      bool
+		expiresAt    time.Time
+		allowedPaths []string
+	}
+)
+
+// Validate validates the Spec.
+func (spec *Spec) Validate() error {
+	if spec.Header == "" && spec.Query == "" {
+		return fmt.Errorf("one of header and query must be specified")
+	}
+	return nil
+}
+
+// Name returns the name of the APIKey filter instance.
+func (a *APIKey) Name() string {
+	return a.spec.Name()
+}
+
+// Kind returns the kind of APIKey.
+func (a *APIKey) Kind() *filters.Kind {
+	return kind
+}
+
+// Spec returns the spec used by the APIKey
+func (a *APIKey) Spec() filters.Spec {
+	return a.spec
+}
+
+// Init initializes APIKey.
+func (a *APIKey) Init() {
+	a.reload()
+}
+
+// Inherit inherits previous generation of APIKey.
+func (a *APIKey) Inherit(previousGeneration filters.Filter) {
+	a.reload()
+}
+
+func (a *APIKey) reload() {
+	a.keys.Store(map[string]*apiKey{})
+	a.stopCtx, a.cancel = stdcontext.WithCancel(stdcontext.Background())
+
+	super := a.spec.Super()
+	if super == nil || super.Cluster() == nil {
+		logger.Errorf("%s: cluster is not available, no API keys are loaded", a.spec.Name())
+		return
+	}
+
+	cls := super.Cluster()
+	store := customdata.NewStore(cls, cls.Layout().CustomDataKindPrefix(), cls.Layout().CustomDataPrefix())
+
+	data, err := store.ListData(a.spec.CustomDataKind)
+	if err != nil {
+		logger.Errorf("%s: failed to load API keys: %v", a.spec.Name(), err)
+	} else {
+		a.update(a.getKind(store), data)
+	}
+
+	go a.watch(store)
+}
+
+// getKind gets the custom data kind of API keys, it returns a kind with
+// the default idField if failed. The kind is loaded on every update, as
+// its idField may change.
+func (a *APIKey) getKind(store *customdata.Store) *customdata.Kind {
+	kind, err := store.GetKind(a.spec.CustomDataKind)
+	if err != nil {
+		logger.Errorf("%s: failed to get custom data kind %s: %v", a.spec.Name(), a.spec.CustomDataKind, err)
+	}
+	if kind == nil {
+		kind = &customdata.Kind{Name: a.spec.CustomDataKind}
+	}
+	return kind
+}
+
+// watch watches the changes of API keys until the filter is closed.
+func (a *APIKey) watch(store *customdata.Store) {
+	update := func(data []customdata.Data) {
+		a.update(a.getKind(store), data)
+	}
+	for {
+		err := store.Watch(a.stopCtx, a.spec.CustomDataKind, update)
+		if err == nil {
+			return
+		}
+		logger.Errorf("%s: failed to watch API keys: %v", a.spec.Name(), err)
+
+		select {
+		case <-time.After(10 * time.Second):
+		case <-a.stopCtx.Done():
+			return
+		}
+	}
+}
+
+// update replaces the API keys with the custom data, the ID of an API key
+// is the ID of its custom data.
+func (a *APIKey) update(kind *customdata.Kind, data []customdata.Data) {
+	keys := make(map[string]*apiKey, len(data))
+	for _, d := range data {
+		id := kind.DataID(d)
+		hash, k, err := parseAPIKey(id, d)
+		if err != nil {
+			logger.Errorf("%s: invalid API key %s: %v", a.spec.Name(), id, err)
+			continue
+		}
+		keys[hash] = k
+	}
+	a.keys.Store(keys)
+	logger.Infof("%s: %d API keys loaded", a.spec.Name(), len(keys))
+}
+
+// parseAPIKey parses an API key with the given ID from custom data, it
+// returns the key hash and the key.
+func parseAPIKey(id string, d customdata.Data) (string, *apiKey, error) {
+	hash := strings.ToLower(d.GetString("keyHash"))
+	if len(hash) != sha256.Size*2 {
+		return "", nil, fmt.Errorf("keyHash must be a hex encoded SHA256 hash")
+	}
+
+	k := &apiKey{
+		id:       id,
+		consumer: d.GetString("consumer"),
+		enabled:  true,
+	}
+	if k.consumer == "" {
+		return "", nil, fmt.Errorf("consumer is required")
+	}
+
+	if v, ok := d["enabled"]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return "", nil, fmt.Errorf("enabled must be a boolean")
+		}
+		k.enabled = enabled
+	}
+
+	switch v := d["expiresAt"].(type) {
+	case nil:
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid expiresAt: %v", err)
+		}
+		k.expiresAt = t
+	case time.Time:
+		k.expiresAt = v
+	default:
+		return "", nil, fmt.Errorf("expiresAt must be a RFC3339 time")
+	}
+
+	if v, ok := d["allowedPaths"]; ok {
+		paths, ok := v.([]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("allowedPaths must be an array")
+		}
+		for _, p := range paths {
+			s, ok := p.(string)
+			if !ok {
+				return "", nil, fmt.Errorf("allowedPaths must be an array of strings")
+			}
+			k.allowedPaths = append(k.allowedPaths, s)
+		}
+	}
+
+	return hash, k, nil
+}
+
+// allowPath returns whether the key is allowed to access the path.
+func (k *apiKey) allowPath(path string) bool {
+	if len(k.allowedPaths) == 0 {
+		return true
+	}
+	// match on segment boundaries, so that "/orders" allows "/orders" and
+	// "/orders/1", but not "/orders-admin".
+	for _, p := range k.allowedPaths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey returns the hash of an API key, which is the hex encoded SHA256
+// hash of the key.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *APIKey) extractKey(req *httpprot.Request) string {
+	if a.spec.Header != "" {
+		if key := req.HTTPHeader().Get(a.spec.Header); key != "" {
+			return key
+		}
+	}
+	if a.spec.Query != "" {
+		return req.URL().Query().Get(a.spec.Query)
+	}
+	return ""
+}
+
+// stripKey removes the API key from the request header and query.
+func (a *APIKey) stripKey(req *httpprot.Request) {
+	if a.spec.Header != "" {
+		req.HTTPHeader().Del(a.spec.Header)
+	}
+	if a.spec.Query != "" {
+		u := req.URL()
+		q := u.Query()
+		if _, ok := q[a.spec.Query]; ok {
+			q.Del(a.spec.Query)
+			u.RawQuery = q.Encode()
+		}
+	}
+}
+
+// Handle authenticates the request by its API key.
+func (a *APIKey) Handle(ctx *context.Context) string {
+	req := ctx.GetInputRequest().(*httpprot.Request)
+
+	reject := func(status int, reason string) string {
+		resp, _ := ctx.GetOutputResponse().(*httpprot.Response)
+		if resp == nil {
+			resp, _ = httpprot.NewResponse(nil)
+		}
+		resp.SetStatusCode(status)
+		ctx.SetOutputResponse(resp)
+		ctx.AddTag(stringtool.Cat("apikey: ", reason))
+		return resultInvalid
+	}
+
+	// the consumer header is always removed to prevent it from being
+	// forged by clients.
+	if a.spec.ConsumerHeader != "" {
+		req.HTTPHeader().Del(a.spec.ConsumerHeader)
+	}
+
+	key := a.extractKey(req)
+	if key == "" {
+		return reject(http.StatusUnauthorized, "missing API key")
+	}
+
+	keys := a.keys.Load().(map[string]*apiKey)
+	k := keys[HashKey(key)]
+	switch {
+	case k == nil:
+		return reject(http.StatusUnauthorized, "invalid API key")
+	case !k.enabled:
+		return reject(http.StatusUnauthorized, stringtool.Cat("API key ", k.id, " is disabled"))
+	case !k.expiresAt.IsZero() && time.Now().After(k.expiresAt):
+		return reject(http.StatusUnauthorized, stringtool.Cat("API key ", k.id, " is expired"))
+	case !k.allowPath(req.Path()):
+		return reject(http.StatusForbidden, stringtool.Cat("API key ", k.id, " is not allowed to access ", req.Path()))
+	}
+
+	if !a.spec.KeepKey {
+		a.stripKey(req)
+	}
+	if a.spec.ConsumerHeader != "" {
+		req.HTTPHeader().Set(a.spec.ConsumerHeader, k.consumer)
+	}
+	if a.spec.ConsumerDataKey != "" {
+		ctx.SetData(a.spec.ConsumerDataKey, k.consumer)
+	}
+	ctx.AddTag(stringtool.Cat("consumer: ", k.consumer))
+
+	return ""
+}
+
+// Status returns Status.
+func (a *APIKey) Status() interface{} {
+	return &Status{Keys: len(a.keys.Load().(map[string]*apiKey))}
+}
+
+// Close closes APIKey.
+func (a *APIKey) Close() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
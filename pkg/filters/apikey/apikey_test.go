@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/cluster/clustertest"
+	"github.com/megaease/easegress/pkg/cluster/customdata"
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/filters"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/tracing"
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitNop()
+	code := m.Run()
+	os.Exit(code)
+}
+
+func createAPIKey(yamlConfig string, super *supervisor.Supervisor) *APIKey {
+	rawSpec := make(map[string]interface{})
+	codectool.MustUnmarshal([]byte(yamlConfig), &rawSpec)
+	spec, err := filters.NewSpec(super, "", rawSpec)
+	if err != nil {
+		panic(err.Error())
+	}
+	a := kind.CreateInstance(spec).(*APIKey)
+	a.Init()
+	return a
+}
+
+func keyData(name, key, extra string) *mvccpb.KeyValue {
+	value := fmt.Sprintf("name: %s\nkeyHash: %s\n%s", name, HashKey(key), extra)
+	return &mvccpb.KeyValue{Value: []byte(value)}
+}
+
+func TestSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Spec{CustomDataKind: "apikeys"}
+	assert.Error(spec.Validate())
+
+	spec.Query = "apikey"
+	assert.NoError(spec.Validate())
+}
+
+func TestParseAPIKey(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := parseAPIKey("key1", map[string]interface{}{"keyHash": "abc", "consumer": "alice"})
+	assert.Error(err)
+
+	hash := HashKey("key")
+	_, _, err = parseAPIKey("key1", map[string]interface{}{"keyHash": hash})
+	assert.Error(err)
+
+	_, _, err = parseAPIKey("key1", map[string]interface{}{"keyHash": hash, "consumer": "alice", "enabled": "yes"})
+	assert.Error(err)
+
+	_, _, err = parseAPIKey("key1", map[string]interface{}{"keyHash": hash, "consumer": "alice", "expiresAt": "tomorrow"})
+	assert.Error(err)
+
+	_, _, err = parseAPIKey("key1", map[string]interface{}{"keyHash": hash, "consumer": "alice", "allowedPaths": "/a"})
+	assert.Error(err)
+
+	h, k, err := parseAPIKey("key1", map[string]interface{}{
+		"name":         "key1",
+		"keyHash":      hash,
+		"consumer":     "alice",
+		"expiresAt":    "2030-01-01T00:00:00Z",
+		"allowedPaths": []interface{}{"/orders"},
+	})
+	assert.NoError(err)
+	assert.Equal(hash, h)
+	assert.True(k.enabled)
+	assert.Equal(2030, k.expiresAt.Year())
+	assert.True(k.allowPath("/orders"))
+	assert.True(k.allowPath("/orders/1"))
+	assert.False(k.allowPath("/orders-admin"))
+	assert.False(k.allowPath("/users"))
+
+	k.allowedPaths = []string{"/orders/"}
+	assert.True(k.allowPath("/orders/1"))
+	assert.False(k.allowPath("/orders-admin"))
+
+	k.allowedPaths = []string{"/"}
+	assert.True(k.allowPath("/users"))
+}
+
+func TestUpdateWithIDField(t *testing.T) {
+	assert := assert.New(t)
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedGetRaw = func(key string) (*mvccpb.KeyValue, error) {
+		assert.Equal("/custom-data-kinds/apikeys", key)
+		return &mvccpb.KeyValue{Value: []byte("name: apikeys\nidField: keyId")}, nil
+	}
+	store := customdata.NewStore(cls, "/custom-data-kinds/", "/custom-data/")
+
+	a := &APIKey{spec: &Spec{CustomDataKind: "apikeys"}}
+	a.update(a.getKind(store), []customdata.Data{
+		{"keyId": "key1", "keyHash": HashKey("alice-key"), "consumer": "alice"},
+	})
+	k := a.keys.Load().(map[string]*apiKey)[HashKey("alice-key")]
+	assert.NotNil(k)
+	assert.Equal("key1", k.id)
+
+	// the default idField is used if the kind is not found.
+	cls.MockedGetRaw = nil
+	a.update(a.getKind(store), []customdata.Data{
+		{"name": "key2", "keyHash": HashKey("bob-key"), "consumer": "bob"},
+	})
+	k = a.keys.Load().(map[string]*apiKey)[HashKey("bob-key")]
+	assert.NotNil(k)
+	assert.Equal("key2", k.id)
+}
+
+func TestAPIKey(t *testing.T) {
+	assert := assert.New(t)
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedGetRawPrefix = func(prefix string) (map[string]*mvccpb.KeyValue, error) {
+		assert.Equal("/custom-data/apikeys/", prefix)
+		return map[string]*mvccpb.KeyValue{
+			"key1": keyData("key1", "alice-key", "consumer: alice\nallowedPaths: [/orders]"),
+			"key2": keyData("key2", "bob-key", "consumer: bob\nenabled: false"),
+			"key3": keyData("key3", "carol-key", "consumer: carol\nexpiresAt: 2020-01-01T00:00:00Z"),
+			"key4": keyData("key4", "dave-key", "consumer: dave"),
+			"key5": keyData("key5", "invalid-key", ""),
+		}, nil
+	}
+
+	syncer := clustertest.NewMockedSyncer()
+	ch := make(chan map[string]*mvccpb.KeyValue)
+	syncer.MockedSyncRawPrefix = func(prefix string) (<-chan map[string]*mvccpb.KeyValue, error) {
+		return ch, nil
+	}
+	syncer.MockedClose = func() {}
+	cls.MockedSyncer = func(t time.Duration) (cluster.Syncer, error) {
+		return syncer, nil
+	}
+
+	var mockMap sync.Map
+	super := supervisor.NewMock(nil, cls, mockMap, mockMap, nil, nil, false, nil, nil)
+
+	a := createAPIKey(`
+kind: APIKey
+name: apikey
+customDataKind: apikeys
+query: apikey
+`, super)
+	defer a.Close()
+	assert.Equal(4, a.Status().(*Status).Keys)
+
+	check := func(path string, header map[string]string, result string, status int) *context.Context {
+		ctx := context.New(tracing.NoopSpan)
+		stdr, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1"+path, nil)
+		for k, v := range header {
+			stdr.Header.Set(k, v)
+		}
+		req, _ := httpprot.NewRequest(stdr)
+		ctx.SetInputRequest(req)
+
+		assert.Equal(result, a.Handle(ctx), path)
+		if result != "" {
+			resp := ctx.GetOutputResponse().(*httpprot.Response)
+			assert.Equal(status, resp.StatusCode(), path)
+		}
+		return ctx
+	}
+
+	check("/orders", nil, resultInvalid, http.StatusUnauthorized)
+	check("/orders", map[string]string{"X-API-Key": "unknown"}, resultInvalid, http.StatusUnauthorized)
+	check("/orders", map[string]string{"X-API-Key": "bob-key"}, resultInvalid, http.StatusUnauthorized)
+	check("/orders", map[string]string{"X-API-Key": "carol-key"}, resultInvalid, http.StatusUnauthorized)
+	check("/users", map[string]string{"X-API-Key": "alice-key"}, resultInvalid, http.StatusForbidden)
+	check("/orders-admin", map[string]string{"X-API-Key": "alice-key"}, resultInvalid, http.StatusForbidden)
+
+	ctx := check("/orders/1", map[string]string{"X-API-Key": "alice-key", "X-Consumer": "bob"}, "", 0)
+	req := ctx.GetInputRequest().(*httpprot.Request)
+	assert.Equal("alice", req.HTTPHeader().Get("X-Consumer"))
+	assert.Equal("alice", ctx.GetData(defaultConsumerDataKey))
+	assert.Empty(req.HTTPHeader().Get("X-API-Key"))
+
+	ctx = check("/users?apikey=dave-key&page=2", nil, "", 0)
+	assert.Equal("dave", ctx.GetData(defaultConsumerDataKey))
+	req = ctx.GetInputRequest().(*httpprot.Request)
+	assert.Equal("page=2", req.URL().RawQuery)
+
+	// the key is kept if keepKey is true.
+	a.spec.KeepKey = true
+	ctx = check("/users", map[string]string{"X-API-Key": "dave-key"}, "", 0)
+	req = ctx.GetInputRequest().(*httpprot.Request)
+	assert.Equal("dave-key", req.HTTPHeader().Get("X-API-Key"))
+	a.spec.KeepKey = false
+
+	// keys are updated live.
+	ch <- map[string]*mvccpb.KeyValue{
+		"key2": keyData("key2", "bob-key", "consumer: bob\nenabled: true"),
+	}
+	assert.Eventually(func() bool {
+		return a.Status().(*Status).Keys == 1
+	}, time.Second, 10*time.Millisecond)
+
+	check("/orders", map[string]string{"X-API-Key": "bob-key"}, "", 0)
+	check("/orders", map[string]string{"X-API-Key": "alice-key"}, resultInvalid, http.StatusUnauthorized)
+}
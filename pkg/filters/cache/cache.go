@@ -0,0 +1,562 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cache implements the Cache filter, which caches responses
+// following the HTTP caching semantics.
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/filters"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/util/stringtool"
+)
+
+const (
+	// Kind is the kind of Cache.
+	Kind = "Cache"
+
+	resultCacheHit = "cacheHit"
+
+	// StorageMemory stores responses in memory.
+	StorageMemory = "memory"
+	// StorageDisk stores responses in a local directory.
+	StorageDisk = "disk"
+
+	defaultMaxEntries    = 10000
+	defaultMaxEntryBytes = 1024 * 1024
+
+	// revalidateTimeout is the max duration of a revalidation, other
+	// requests are served with the stale response during the period if
+	// stale-while-revalidate is allowed.
+	revalidateTimeout = 30 * time.Second
+	// validatorRetention is the extra duration to keep a stale response
+	// with validators, so that it could be revalidated.
+	validatorRetention = time.Hour
+)
+
+var kind = &filters.Kind{
+	Name:        Kind,
+	Description: "Cache caches responses following the HTTP caching semantics.",
+	Results:     []string{resultCacheHit},
+	DefaultSpec: func() filters.Spec {
+		return &Spec{
+			Methods:       []string{http.MethodGet, http.MethodHead},
+			Codes:         []int{http.StatusOK, http.StatusMovedPermanently, http.StatusNotFound},
+			MaxEntryBytes: defaultMaxEntryBytes,
+			Storage:       StorageMemory,
+			MaxEntries:    defaultMaxEntries,
+		}
+	},
+	CreateInstance: func(spec filters.Spec) filters.Filter {
+		return &Cache{spec: spec.(*Spec)}
+	},
+}
+
+func init() {
+	filters.Register(kind)
+}
+
+type (
+	// Cache is filter Cache. It must be referenced twice in the flow of
+	// a pipeline, once before the filter that sends the request to the
+	// backend (usually a Proxy) to serve responses from the cache, and
+	// once after it (with an alias) to store the response. The two
+	// invocations are distinguished by the context data set by the first
+	// one.
+	Cache struct {
+		spec *Spec
+
+		store         Store
+		methods       map[string]struct{}
+		codes         map[int]struct{}
+		defaultTTL    time.Duration
+		maxEntryBytes int
+		dataKey       string
+
+		// revalidating records the keys being revalidated, the value is
+		// the start time of the revalidation.
+		revalidating sync.Map
+
+		hits   uint64
+		misses uint64
+	}
+
+	// Spec describes the Cache.
+	Spec struct {
+		filters.BaseSpec `json:",inline"`
+
+		Methods       []string `json:"methods" jsonschema:"omitempty,uniqueItems=true,format=httpmethod-array"`
+		Codes         []int    `json:"codes" jsonschema:"omitempty,uniqueItems=true,format=httpcode-array"`
+		DefaultTTL    string   `json:"defaultTTL" jsonschema:"omitempty,format=duration"`
+		MaxEntryBytes uint32   `json:"maxEntryBytes" jsonschema:"omitempty,minimum=1"`
+		Storage       string   `json:"storage" jsonschema:"omitempty,enum=,enum=memory,enum=disk"`
+		MaxEntries    int      `json:"maxEntries" jsonschema:"omitempty,minimum=1"`
+		Dir           string   `json:"dir" jsonschema:"omitempty"`
+	}
+
+	// Status is the status of Cache.
+	Status struct {
+		Hits   uint64 `json:"hits"`
+		Misses uint64 `json:"misses"`
+	}
+
+	// lookupState is the state passed from the lookup invocation to the
+	// store invocation of the filter.
+	lookupState struct {
+		key string
+		// entryKey is the key of stale, which is different from key
+		// if the response varies on request headers.
+		entryKey     string
+		stale        *Entry
+		revalidating bool
+		invalidate   bool
+	}
+)
+
+// Validate validates the Spec.
+func (spec *Spec) Validate() error {
+	if spec.Storage == StorageDisk && spec.Dir == "" {
+		return fmt.Errorf("dir is required when storage is disk")
+	}
+	if spec.DefaultTTL != "" {
+		if _, err := time.ParseDuration(spec.DefaultTTL); err != nil {
+			return fmt.Errorf("invalid defaultTTL: %v", err)
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the Cache filter instance.
+func (c *Cache) Name() string {
+	return c.spec.Name()
+}
+
+// Kind returns the kind of Cache.
+func (c *Cache) Kind() *filters.Kind {
+	return kind
+}
+
+// Spec returns the spec used by the Cache
+func (c *Cache) Spec() filters.Spec {
+	return c.spec
+}
+
+// Init initializes Cache.
+func (c *Cache) Init() {
+	c.reload(nil)
+}
+
+// Inherit inherits previous generation of Cache.
+func (c *Cache) Inherit(previousGeneration filters.Filter) {
+	c.reload(previousGeneration.(*Cache))
+}
+
+func (c *Cache) sameStorage(prev *Cache) bool {
+	s1, s2 := c.spec, prev.spec
+	return s1.Storage == s2.Storage && s1.MaxEntries == s2.MaxEntries && s1.Dir == s2.Dir
+}
+
+func (c *Cache) reload(prev *Cache) {
+	c.dataKey = Kind + "/" + c.spec.Name()
+
+	c.methods = map[string]struct{}{}
+	for _, m := range c.spec.Methods {
+		c.methods[m] = struct{}{}
+	}
+
+	c.codes = map[int]struct{}{}
+	for _, code := range c.spec.Codes {
+		c.codes[code] = struct{}{}
+	}
+
+	if c.spec.DefaultTTL != "" {
+		c.defaultTTL, _ = time.ParseDuration(c.spec.DefaultTTL)
+	}
+
+	c.maxEntryBytes = int(c.spec.MaxEntryBytes)
+	if c.maxEntryBytes == 0 {
+		c.maxEntryBytes = defaultMaxEntryBytes
+	}
+
+	// responses in the store are still valid if the storage is not
+	// changed, so keep them.
+	if prev != nil && c.sameStorage(prev) {
+		c.store = prev.store
+		prev.store = nil
+		return
+	}
+
+	maxEntries := c.spec.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	if c.spec.Storage == StorageDisk {
+		s, err := newDiskStore(c.spec.Dir, maxEntries)
+		if err == nil {
+			c.store = s
+			return
+		}
+		logger.Errorf("%s: failed to create disk store, fallback to memory store: %v", c.spec.Name(), err)
+	}
+
+	c.store = newMemoryStore(maxEntries)
+}
+
+// Handle handles the context.
+func (c *Cache) Handle(ctx *context.Context) string {
+	req := ctx.GetInputRequest().(*httpprot.Request)
+
+	if state, ok := ctx.GetData(c.dataKey).(*lookupState); ok {
+		ctx.SetData(c.dataKey, nil)
+		return c.handleStore(ctx, req, state)
+	}
+
+	return c.handleLookup(ctx, req)
+}
+
+func urlKey(req *httpprot.Request) string {
+	return stringtool.Cat(req.Scheme(), "://", req.Host(), req.URL().RequestURI())
+}
+
+func variantKey(key string, vary []string, req *httpprot.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(req.HTTPHeader().Values(name), ","))
+	}
+	return sb.String()
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// load loads the entry of the request, and returns the key of the
+// entry.
+func (c *Cache) load(key string, req *httpprot.Request) (*Entry, string) {
+	entry := c.store.Get(key)
+	if entry == nil || len(entry.Vary) == 0 {
+		return entry, key
+	}
+
+	key = variantKey(key, entry.Vary, req)
+	return c.store.Get(key), key
+}
+
+// startRevalidation returns true if the caller should revalidate the
+// entry of the key, and false if another request is revalidating it.
+func (c *Cache) startRevalidation(key string, now time.Time) bool {
+	v, loaded := c.revalidating.LoadOrStore(key, now)
+	if !loaded {
+		return true
+	}
+
+	// the previous revalidation may have failed without storing a
+	// response, take it over.
+	if now.Sub(v.(time.Time)) > revalidateTimeout {
+		c.revalidating.Store(key, now)
+		return true
+	}
+	return false
+}
+
+func (c *Cache) handleLookup(ctx *context.Context, req *httpprot.Request) string {
+	if _, ok := c.methods[req.Method()]; !ok {
+		// a successful response to an unsafe request invalidates the
+		// cached responses of the same URL.
+		if isUnsafeMethod(req.Method()) {
+			ctx.SetData(c.dataKey, &lookupState{key: urlKey(req), invalidate: true})
+		}
+		return ""
+	}
+
+	key := req.Method() + " " + urlKey(req)
+
+	state := &lookupState{key: key}
+	ctx.SetData(c.dataKey, state)
+
+	reqCC := parseCacheControl(req.HTTPHeader())
+	if reqCC.has("no-cache") {
+		atomic.AddUint64(&c.misses, 1)
+		return ""
+	}
+
+	entry, entryKey := c.load(key, req)
+	if entry == nil {
+		atomic.AddUint64(&c.misses, 1)
+		return ""
+	}
+
+	now := time.Now()
+	age := entry.age(now)
+	maxAge := entry.Lifetime
+	if d, ok := reqCC.duration("max-age"); ok && d < maxAge {
+		maxAge = d
+	}
+
+	if age < maxAge {
+		atomic.AddUint64(&c.hits, 1)
+		ctx.SetData(c.dataKey, nil)
+		c.serve(ctx, req, entry, age)
+		return resultCacheHit
+	}
+
+	// the response is stale, only one request revalidates it if
+	// stale-while-revalidate is allowed, others are served with the stale
+	// response. But if the client requires a fresher response by
+	// max-age, the stale response is not acceptable.
+	swr := entry.StaleWhileRevalidate > 0 && !entry.MustRevalidate && maxAge == entry.Lifetime
+	if swr && age < entry.Lifetime+entry.StaleWhileRevalidate {
+		if !c.startRevalidation(key, now) {
+			atomic.AddUint64(&c.hits, 1)
+			ctx.SetData(c.dataKey, nil)
+			c.serve(ctx, req, entry, age)
+			return resultCacheHit
+		}
+		state.revalidating = true
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	// if the client sends its own validators, the 304 response is for
+	// the client, so the request is not changed.
+	header := req.HTTPHeader()
+	if !entry.hasValidators() || header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != "" {
+		return ""
+	}
+
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lm := entry.Header.Get("Last-Modified"); lm != "" {
+		header.Set("If-Modified-Since", lm)
+	}
+	state.stale = entry
+	state.entryKey = entryKey
+	return ""
+}
+
+// notModified reports whether the conditional request of the client
+// could be responded with 304 Not Modified.
+func notModified(req *httpprot.Request, entry *Entry) bool {
+	if entry.StatusCode != http.StatusOK {
+		return false
+	}
+
+	if inm := req.HTTPHeader().Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, entry.Header.Get("ETag"))
+	}
+
+	ims, err := http.ParseTime(req.HTTPHeader().Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(entry.Header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+func (c *Cache) serve(ctx *context.Context, req *httpprot.Request, entry *Entry, age time.Duration) {
+	resp, _ := ctx.GetOutputResponse().(*httpprot.Response)
+	if resp == nil {
+		resp, _ = httpprot.NewResponse(nil)
+	}
+
+	header := resp.HTTPHeader()
+	for k, v := range entry.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	if notModified(req, entry) {
+		resp.SetStatusCode(http.StatusNotModified)
+		resp.SetPayload(nil)
+	} else {
+		resp.SetStatusCode(entry.StatusCode)
+		resp.SetPayload(entry.Body)
+	}
+
+	ctx.SetOutputResponse(resp)
+}
+
+func (c *Cache) handleStore(ctx *context.Context, req *httpprot.Request, state *lookupState) string {
+	if state.revalidating {
+		c.revalidating.Delete(state.key)
+	}
+
+	resp, _ := ctx.GetOutputResponse().(*httpprot.Response)
+	if resp == nil {
+		return ""
+	}
+
+	if state.invalidate {
+		if resp.StatusCode() < 400 {
+			for m := range c.methods {
+				c.store.Delete(m + " " + state.key)
+			}
+		}
+		return ""
+	}
+
+	now := time.Now()
+	if state.stale != nil && resp.StatusCode() == http.StatusNotModified {
+		entry := c.refresh(state.stale, resp, now)
+		c.store.Put(state.entryKey, entry)
+		c.serve(ctx, req, entry, entry.age(now))
+		return ""
+	}
+
+	c.tryStore(state.key, req, resp, now)
+	return ""
+}
+
+// refresh creates a new entry from a stale one and the 304 response of
+// its revalidation.
+func (c *Cache) refresh(stale *Entry, resp *httpprot.Response, now time.Time) *Entry {
+	header := stale.Header.Clone()
+	for k, v := range resp.HTTPHeader() {
+		// the 304 response has no body.
+		if k == "Content-Length" {
+			continue
+		}
+		header[k] = v
+	}
+	header.Del("Age")
+
+	entry := &Entry{
+		StatusCode: stale.StatusCode,
+		Header:     header,
+		Body:       stale.Body,
+	}
+	c.setFreshness(entry, parseCacheControl(header), now, parseAge(resp.HTTPHeader()))
+	return entry
+}
+
+// setFreshness sets the freshness info of the entry, it returns false if
+// the entry should not be stored.
+func (c *Cache) setFreshness(entry *Entry, cc cacheControl, now time.Time, initialAge time.Duration) bool {
+	lifetime, ok := freshnessLifetime(entry.Header, cc, now)
+	if !ok {
+		lifetime = c.defaultTTL
+	}
+	if cc.has("no-cache") || lifetime < 0 {
+		lifetime = 0
+	}
+	if lifetime == 0 && !entry.hasValidators() {
+		return false
+	}
+
+	entry.StoredAt = now
+	entry.InitialAge = initialAge
+	entry.Lifetime = lifetime
+	entry.MustRevalidate = cc.has("must-revalidate") || cc.has("proxy-revalidate")
+	entry.StaleWhileRevalidate, _ = cc.duration("stale-while-revalidate")
+
+	entry.Deadline = now.Add(lifetime - initialAge + entry.StaleWhileRevalidate)
+	if entry.hasValidators() {
+		entry.Deadline = entry.Deadline.Add(validatorRetention)
+	}
+	return true
+}
+
+func (c *Cache) tryStore(key string, req *httpprot.Request, resp *httpprot.Response, now time.Time) {
+	if _, ok := c.codes[resp.StatusCode()]; !ok {
+		return
+	}
+	if resp.IsStream() || len(resp.RawPayload()) > c.maxEntryBytes {
+		return
+	}
+
+	reqCC := parseCacheControl(req.HTTPHeader())
+	if reqCC.has("no-store") {
+		return
+	}
+
+	header := resp.HTTPHeader()
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || cc.has("private") {
+		return
+	}
+
+	// responses to authorized requests are private unless explicitly
+	// allowed. Responses setting cookies are private too.
+	if req.HTTPHeader().Get("Authorization") != "" &&
+		!cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return
+	}
+	if header.Get("Set-Cookie") != "" {
+		return
+	}
+
+	vary := parseVary(header)
+	if len(vary) == 1 && vary[0] == "*" {
+		return
+	}
+
+	entry := &Entry{
+		StatusCode: resp.StatusCode(),
+		Header:     header.Clone(),
+		Body:       resp.RawPayload(),
+	}
+	entry.Header.Del("Age")
+	if !c.setFreshness(entry, cc, now, parseAge(header)) {
+		return
+	}
+
+	if len(vary) == 0 {
+		c.store.Put(key, entry)
+		return
+	}
+
+	c.store.Put(key, &Entry{Vary: vary, Deadline: entry.Deadline})
+	c.store.Put(variantKey(key, vary, req), entry)
+}
+
+// Status returns Status.
+func (c *Cache) Status() interface{} {
+	return &Status{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+// Close closes Cache.
+func (c *Cache) Close() {
+	if c.store != nil {
+		c.store.Close()
+	}
+}
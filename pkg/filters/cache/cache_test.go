@@ -0,0 +1,422 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/filters"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/util/codectool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitNop()
+	code := m.Run()
+	os.Exit(code)
+}
+
+func newCache(t *testing.T, yamlConfig string) *Cache {
+	rawSpec := make(map[string]interface{})
+	codectool.MustUnmarshal([]byte(yamlConfig), &rawSpec)
+
+	spec, err := filters.NewSpec(nil, "", rawSpec)
+	assert.NoError(t, err)
+
+	c := kind.CreateInstance(spec).(*Cache)
+	c.Init()
+	return c
+}
+
+type backend struct {
+	calls   int
+	code    int
+	header  http.Header
+	body    string
+	lastReq *httpprot.Request
+}
+
+// do simulates a pipeline with flow: cache -> backend -> cache.
+func (b *backend) do(c *Cache, stdr *http.Request) (string, *httpprot.Response) {
+	ctx := context.New(nil)
+	req, _ := httpprot.NewRequest(stdr)
+	ctx.SetInputRequest(req)
+
+	if result := c.Handle(ctx); result != "" {
+		return result, ctx.GetOutputResponse().(*httpprot.Response)
+	}
+
+	b.calls++
+	b.lastReq = req
+	resp, _ := httpprot.NewResponse(nil)
+	resp.SetStatusCode(b.code)
+	for k, v := range b.header {
+		resp.HTTPHeader()[k] = v
+	}
+	resp.SetPayload([]byte(b.body))
+	ctx.SetOutputResponse(resp)
+
+	c.Handle(ctx)
+	return "", ctx.GetOutputResponse().(*httpprot.Response)
+}
+
+func newRequest(method, url string) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	return req
+}
+
+func TestSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Spec{Storage: StorageDisk}
+	assert.Error(spec.Validate())
+
+	spec.Dir = "/tmp"
+	assert.NoError(spec.Validate())
+
+	spec.DefaultTTL = "1x"
+	assert.Error(spec.Validate())
+}
+
+func TestCacheControl(t *testing.T) {
+	assert := assert.New(t)
+
+	header := http.Header{}
+	header.Add("Cache-Control", `public, max-age=60, s-maxage="120"`)
+	header.Add("Cache-Control", "stale-while-revalidate=30")
+	cc := parseCacheControl(header)
+	assert.True(cc.has("public"))
+	d, ok := cc.duration("stale-while-revalidate")
+	assert.True(ok)
+	assert.Equal(30*time.Second, d)
+
+	now := time.Now()
+	lifetime, ok := freshnessLifetime(header, cc, now)
+	assert.True(ok)
+	assert.Equal(120*time.Second, lifetime)
+
+	header = http.Header{}
+	header.Set("Date", now.UTC().Format(http.TimeFormat))
+	header.Set("Expires", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+	lifetime, ok = freshnessLifetime(header, parseCacheControl(header), now)
+	assert.True(ok)
+	assert.Equal(time.Hour, lifetime)
+
+	header.Set("Expires", "0")
+	lifetime, ok = freshnessLifetime(header, parseCacheControl(header), now)
+	assert.True(ok)
+	assert.Equal(time.Duration(0), lifetime)
+
+	header = http.Header{}
+	header.Set("Pragma", "no-cache")
+	assert.True(parseCacheControl(header).has("no-cache"))
+
+	header = http.Header{}
+	header.Set("Vary", "accept-encoding, Accept")
+	assert.Equal([]string{"Accept", "Accept-Encoding"}, parseVary(header))
+	header.Set("Vary", "Accept, *")
+	assert.Equal([]string{"*"}, parseVary(header))
+
+	assert.True(etagMatch(`"a", W/"b"`, `"b"`))
+	assert.True(etagMatch(`*`, `"b"`))
+	assert.False(etagMatch(`"a"`, `"b"`))
+	assert.False(etagMatch(`"a"`, ``))
+}
+
+func TestCacheFreshness(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCache(t, `
+kind: Cache
+name: cache
+`)
+	defer c.Close()
+
+	b := &backend{
+		code:   http.StatusOK,
+		header: http.Header{"Cache-Control": []string{"max-age=60"}},
+		body:   "hello",
+	}
+
+	result, resp := b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal("", result)
+	assert.Equal(1, b.calls)
+
+	result, resp = b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal(resultCacheHit, result)
+	assert.Equal(1, b.calls)
+	assert.Equal(http.StatusOK, resp.StatusCode())
+	assert.Equal("hello", string(resp.RawPayload()))
+	assert.Equal("0", resp.HTTPHeader().Get("Age"))
+
+	// different query, different entry.
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/a?x=1"))
+	assert.Equal(2, b.calls)
+
+	// the client requires an end-to-end reload.
+	req := newRequest(http.MethodGet, "http://megaease.com/a")
+	req.Header.Set("Cache-Control", "no-cache")
+	b.do(c, req)
+	assert.Equal(3, b.calls)
+
+	// the client accepts responses no older than 0 seconds.
+	req = newRequest(http.MethodGet, "http://megaease.com/a")
+	req.Header.Set("Cache-Control", "max-age=0")
+	b.do(c, req)
+	assert.Equal(4, b.calls)
+
+	// unsafe requests invalidate the cached response.
+	b.do(c, newRequest(http.MethodPost, "http://megaease.com/a"))
+	assert.Equal(5, b.calls)
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal(6, b.calls)
+
+	// not storable responses.
+	for _, cc := range []string{"no-store", "private", "max-age=0"} {
+		b.header = http.Header{"Cache-Control": []string{cc}}
+		b.do(c, newRequest(http.MethodGet, "http://megaease.com/b"))
+		calls := b.calls
+		b.do(c, newRequest(http.MethodGet, "http://megaease.com/b"))
+		assert.Equal(calls+1, b.calls, cc)
+	}
+
+	// responses to authorized requests are not stored unless allowed.
+	b.header = http.Header{"Cache-Control": []string{"max-age=60"}}
+	req = newRequest(http.MethodGet, "http://megaease.com/c")
+	req.Header.Set("Authorization", "Bearer token")
+	b.do(c, req)
+	calls := b.calls
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/c"))
+	assert.Equal(calls+1, b.calls)
+
+	status := c.Status().(*Status)
+	assert.Equal(uint64(1), status.Hits)
+}
+
+func TestCacheVary(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCache(t, `
+kind: Cache
+name: cache
+`)
+	defer c.Close()
+
+	b := &backend{
+		code: http.StatusOK,
+		header: http.Header{
+			"Cache-Control": []string{"max-age=60"},
+			"Vary":          []string{"Accept-Language"},
+		},
+		body: "hello",
+	}
+
+	newReq := func(lang string) *http.Request {
+		req := newRequest(http.MethodGet, "http://megaease.com/a")
+		req.Header.Set("Accept-Language", lang)
+		return req
+	}
+
+	b.do(c, newReq("en"))
+	b.body = "bonjour"
+	b.do(c, newReq("fr"))
+	assert.Equal(2, b.calls)
+
+	result, resp := b.do(c, newReq("en"))
+	assert.Equal(resultCacheHit, result)
+	assert.Equal("hello", string(resp.RawPayload()))
+
+	result, resp = b.do(c, newReq("fr"))
+	assert.Equal(resultCacheHit, result)
+	assert.Equal("bonjour", string(resp.RawPayload()))
+	assert.Equal(2, b.calls)
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCache(t, `
+kind: Cache
+name: cache
+`)
+	defer c.Close()
+
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	b := &backend{
+		code: http.StatusOK,
+		header: http.Header{
+			"Cache-Control": []string{"no-cache"},
+			"Etag":          []string{`"v1"`},
+			"Last-Modified": []string{lastModified},
+		},
+		body: "hello",
+	}
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+
+	// the response must be revalidated, and the backend responds 304.
+	b.code = http.StatusNotModified
+	b.body = ""
+	result, resp := b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal("", result)
+	assert.Equal(2, b.calls)
+	assert.Equal(`"v1"`, b.lastReq.HTTPHeader().Get("If-None-Match"))
+	assert.Equal(lastModified, b.lastReq.HTTPHeader().Get("If-Modified-Since"))
+	assert.Equal(http.StatusOK, resp.StatusCode())
+	assert.Equal("hello", string(resp.RawPayload()))
+
+	// the validators of the client are sent to the backend as is.
+	req := newRequest(http.MethodGet, "http://megaease.com/a")
+	req.Header.Set("If-None-Match", `"v0"`)
+	b.do(c, req)
+	assert.Equal(`"v0"`, b.lastReq.HTTPHeader().Get("If-None-Match"))
+
+	// conditional requests of the client are answered from the cache.
+	b.code = http.StatusOK
+	b.header.Set("Cache-Control", "max-age=60")
+	b.body = "hello"
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/b"))
+
+	req = newRequest(http.MethodGet, "http://megaease.com/b")
+	req.Header.Set("If-None-Match", `"v1"`)
+	result, resp = b.do(c, req)
+	assert.Equal(resultCacheHit, result)
+	assert.Equal(http.StatusNotModified, resp.StatusCode())
+	assert.Empty(resp.RawPayload())
+
+	req = newRequest(http.MethodGet, "http://megaease.com/b")
+	req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	result, resp = b.do(c, req)
+	assert.Equal(resultCacheHit, result)
+	assert.Equal(http.StatusNotModified, resp.StatusCode())
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCache(t, `
+kind: Cache
+name: cache
+`)
+	defer c.Close()
+
+	b := &backend{
+		code: http.StatusOK,
+		header: http.Header{
+			"Cache-Control": []string{"max-age=1, stale-while-revalidate=60"},
+			"Age":           []string{"1"},
+		},
+		body: "hello",
+	}
+	b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+
+	// the response is stale, the first request revalidates it.
+	ctx := context.New(nil)
+	req, _ := httpprot.NewRequest(newRequest(http.MethodGet, "http://megaease.com/a"))
+	ctx.SetInputRequest(req)
+	assert.Equal("", c.Handle(ctx))
+
+	// other requests are served with the stale response.
+	result, resp := b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal(resultCacheHit, result)
+	assert.Equal("hello", string(resp.RawPayload()))
+	assert.Equal(1, b.calls)
+
+	// the revalidation completes.
+	resp, _ = httpprot.NewResponse(nil)
+	resp.SetStatusCode(http.StatusOK)
+	resp.HTTPHeader().Set("Cache-Control", "max-age=60")
+	resp.SetPayload([]byte("world"))
+	ctx.SetOutputResponse(resp)
+	c.Handle(ctx)
+
+	result, resp = b.do(c, newRequest(http.MethodGet, "http://megaease.com/a"))
+	assert.Equal(resultCacheHit, result)
+	assert.Equal("world", string(resp.RawPayload()))
+}
+
+func TestDiskStore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, 10)
+	assert.NoError(err)
+	defer s.Close()
+
+	entry := &Entry{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Test": []string{"test"}},
+		Body:       []byte("hello"),
+		Deadline:   time.Now().Add(time.Hour),
+	}
+	s.Put("key", entry)
+
+	e := s.Get("key")
+	assert.NotNil(e)
+	assert.Equal("hello", string(e.Body))
+	assert.Equal("test", e.Header.Get("X-Test"))
+	assert.Nil(s.Get("other"))
+
+	s.Delete("key")
+	assert.Nil(s.Get("key"))
+
+	entry.Deadline = time.Now().Add(-time.Second)
+	s.Put("key", entry)
+	s.cleanup()
+	files, _ := os.ReadDir(dir)
+	assert.Empty(files)
+}
+
+func TestDiskStoreMaxEntries(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, 2)
+	assert.NoError(err)
+	defer s.Close()
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		s.Put(fmt.Sprintf("key%d", i), &Entry{
+			StatusCode: http.StatusOK,
+			Deadline:   now.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	// the entry closest to its deadline is evicted.
+	assert.Eventually(func() bool {
+		files, _ := os.ReadDir(dir)
+		return len(files) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(s.Get("key1"))
+	assert.NotNil(s.Get("key2"))
+	assert.NotNil(s.Get("key3"))
+
+	// entries of a previous run are evicted when the store is created.
+	s2, err := newDiskStore(dir, 1)
+	assert.NoError(err)
+	defer s2.Close()
+	assert.Nil(s2.Get("key2"))
+	assert.NotNil(s2.Get("key3"))
+}
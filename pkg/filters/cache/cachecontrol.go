@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reference: https://www.rfc-editor.org/rfc/rfc7234
+
+// cacheControl is the parsed directives of the Cache-Control header, the
+// keys are lower cased directive names.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(directive, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+
+	// Pragma: no-cache is the same as Cache-Control: no-cache if there's
+	// no Cache-Control header.
+	if len(cc) == 0 && strings.Contains(header.Get("Pragma"), "no-cache") {
+		cc["no-cache"] = ""
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// duration returns the value of a delta-seconds directive.
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// freshnessLifetime returns the freshness lifetime of a response in a
+// shared cache, ok is false if the response has no explicit expiration.
+func freshnessLifetime(header http.Header, cc cacheControl, now time.Time) (lifetime time.Duration, ok bool) {
+	if d, ok := cc.duration("s-maxage"); ok {
+		return d, true
+	}
+	if d, ok := cc.duration("max-age"); ok {
+		return d, true
+	}
+
+	v := header.Get("Expires")
+	if v == "" {
+		return 0, false
+	}
+
+	// an invalid Expires means the response is already expired.
+	expires, err := http.ParseTime(v)
+	if err != nil {
+		return 0, true
+	}
+
+	date := now
+	if d, err := http.ParseTime(header.Get("Date")); err == nil {
+		date = d
+	}
+	return expires.Sub(date), true
+}
+
+// parseAge returns the value of the Age header.
+func parseAge(header http.Header) time.Duration {
+	seconds, err := strconv.ParseInt(header.Get("Age"), 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// parseVary returns the sorted canonical header names in the Vary header.
+func parseVary(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return []string{"*"}
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// etagMatch reports whether etag matches one of the entity tags in
+// the If-None-Match header, weak comparison is used.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const diskCleanupInterval = time.Minute
+
+type (
+	// Entry is a cached response.
+	Entry struct {
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+
+		// Vary is the names of the headers the response varies on, an
+		// entry with Vary is a placeholder, the responses are stored as
+		// variants under keys generated from the request headers.
+		Vary []string
+
+		// StoredAt is the time the response is stored, and InitialAge
+		// is the age of the response at that time.
+		StoredAt   time.Time
+		InitialAge time.Duration
+
+		Lifetime             time.Duration
+		StaleWhileRevalidate time.Duration
+		MustRevalidate       bool
+
+		// Deadline is the time the entry should be removed from the store.
+		Deadline time.Time
+	}
+
+	// Store is the storage of cached responses.
+	Store interface {
+		// Get returns the entry of the key, or nil if not found or expired.
+		Get(key string) *Entry
+		Put(key string, entry *Entry)
+		Delete(key string)
+		Close()
+	}
+
+	// memoryStore stores entries in memory, the least recently used
+	// entries are evicted when the number of entries exceeds the limit.
+	memoryStore struct {
+		cache *lru.Cache
+	}
+
+	// diskStore stores entries as files in a directory, the file name is
+	// the hash of the key, and the modification time of the file is the
+	// deadline of the entry, so that expired entries could be cleaned up
+	// without reading the files. When the number of entries exceeds the
+	// limit, the entries closest to their deadlines are evicted.
+	diskStore struct {
+		dir        string
+		maxEntries int
+		// entries is the approximate number of entries, it is increased
+		// by Put and corrected by cleanup.
+		entries int64
+		full    chan struct{}
+		done    chan struct{}
+		wg      sync.WaitGroup
+	}
+
+	// diskEntry is the content of a file in the disk store, key is
+	// saved to detect hash collisions.
+	diskEntry struct {
+		Key   string
+		Entry *Entry
+	}
+)
+
+func (e *Entry) age(now time.Time) time.Duration {
+	return e.InitialAge + now.Sub(e.StoredAt)
+}
+
+func (e *Entry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+func newMemoryStore(maxEntries int) *memoryStore {
+	// error is impossible as maxEntries is positive.
+	cache, _ := lru.New(maxEntries)
+	return &memoryStore{cache: cache}
+}
+
+// Get implements Store.
+func (s *memoryStore) Get(key string) *Entry {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil
+	}
+
+	entry := v.(*Entry)
+	if time.Now().After(entry.Deadline) {
+		s.cache.Remove(key)
+		return nil
+	}
+	return entry
+}
+
+// Put implements Store.
+func (s *memoryStore) Put(key string, entry *Entry) {
+	s.cache.Add(key, entry)
+}
+
+// Delete implements Store.
+func (s *memoryStore) Delete(key string) {
+	s.cache.Remove(key)
+}
+
+// Close implements Store.
+func (s *memoryStore) Close() {
+	s.cache.Purge()
+}
+
+func newDiskStore(dir string, maxEntries int) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	s := &diskStore{
+		dir:        dir,
+		maxEntries: maxEntries,
+		full:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	// entries of a previous run are counted and evicted if needed.
+	s.cleanup()
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+func (s *diskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Store.
+func (s *diskStore) Get(key string) *Entry {
+	path := s.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("failed to open cache file %s: %v", path, err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	de := &diskEntry{}
+	if err = gob.NewDecoder(f).Decode(de); err != nil {
+		logger.Errorf("failed to decode cache file %s: %v", path, err)
+		return nil
+	}
+
+	if de.Key != key || de.Entry == nil {
+		return nil
+	}
+	if time.Now().After(de.Entry.Deadline) {
+		os.Remove(path)
+		return nil
+	}
+	return de.Entry
+}
+
+// Put implements Store.
+func (s *diskStore) Put(key string, entry *Entry) {
+	path := s.path(key)
+
+	// write to a temporary file and rename it, so that readers never
+	// see a partially written file.
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		logger.Errorf("failed to create cache file: %v", err)
+		return
+	}
+
+	err = gob.NewEncoder(f).Encode(&diskEntry{Key: key, Entry: entry})
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chtimes(f.Name(), time.Now(), entry.Deadline)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		logger.Errorf("failed to write cache file %s: %v", path, err)
+		os.Remove(f.Name())
+		return
+	}
+
+	// trigger a cleanup to evict entries if the store could be full.
+	if atomic.AddInt64(&s.entries, 1) > int64(s.maxEntries) {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Delete implements Store.
+func (s *diskStore) Delete(key string) {
+	path := s.path(key)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("failed to remove cache file %s: %v", path, err)
+	}
+}
+
+func (s *diskStore) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(diskCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.cleanup()
+		case <-s.full:
+			s.cleanup()
+		}
+	}
+}
+
+// cleanup removes expired entries and temporary files left by failed
+// writes, and evicts the entries closest to their deadlines if the number
+// of entries exceeds the limit.
+func (s *diskStore) cleanup() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		logger.Errorf("failed to read cache directory %s: %v", s.dir, err)
+		return
+	}
+
+	now := time.Now()
+	alive := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// temporary files may be being written.
+		if strings.HasPrefix(e.Name(), ".tmp-") {
+			if info.ModTime().Add(diskCleanupInterval).Before(now) {
+				os.Remove(filepath.Join(s.dir, e.Name()))
+			}
+			continue
+		}
+
+		if info.ModTime().Before(now) {
+			os.Remove(filepath.Join(s.dir, e.Name()))
+			continue
+		}
+		alive = append(alive, info)
+	}
+
+	if excess := len(alive) - s.maxEntries; excess > 0 {
+		sort.Slice(alive, func(i, j int) bool {
+			return alive[i].ModTime().Before(alive[j].ModTime())
+		})
+		for _, info := range alive[:excess] {
+			os.Remove(filepath.Join(s.dir, info.Name()))
+		}
+		alive = alive[excess:]
+	}
+
+	atomic.StoreInt64(&s.entries, int64(len(alive)))
+}
+
+// Close implements Store.
+func (s *diskStore) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
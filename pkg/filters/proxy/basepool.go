@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/megaease/easegress/pkg/logger"
 	"github.com/megaease/easegress/pkg/object/serviceregistry"
@@ -35,6 +36,15 @@ type BaseServerPool struct {
 	wg           sync.WaitGroup
 	filter       RequestMatcher
 	loadBalancer atomic.Value
+
+	// mu protects lbSpec and servers, which are used to rebuild the load
+	// balancer when the servers or their health change.
+	mu      sync.Mutex
+	lbSpec  *LoadBalanceSpec
+	servers []*Server
+
+	healthChecker   *healthChecker
+	outlierDetector *outlierDetector
 }
 
 // BaseServerPoolSpec is the spec for a base server pool.
@@ -45,6 +55,7 @@ type BaseServerPoolSpec struct {
 	ServiceRegistry string              `json:"serviceRegistry" jsonschema:"omitempty"`
 	ServiceName     string              `json:"serviceName" jsonschema:"omitempty"`
 	LoadBalance     *LoadBalanceSpec    `json:"loadBalance" jsonschema:"omitempty"`
+	HealthCheck     *HealthCheckSpec    `json:"healthCheck,omitempty" jsonschema:"omitempty"`
 }
 
 // Validate validates ServerPoolSpec.
@@ -64,6 +75,18 @@ func (sps *BaseServerPoolSpec) Validate() error {
 		return fmt.Errorf(msgFmt, serversGotWeight, len(sps.Servers))
 	}
 
+	if sps.LoadBalance != nil {
+		if err := sps.LoadBalance.Validate(); err != nil {
+			return fmt.Errorf("loadBalance: %v", err)
+		}
+	}
+
+	if sps.HealthCheck != nil {
+		if err := sps.HealthCheck.Validate(); err != nil {
+			return fmt.Errorf("healthCheck: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -76,6 +99,11 @@ func (bsp *BaseServerPool) Init(super *supervisor.Supervisor, name string, spec
 		bsp.filter = NewRequestMatcher(spec.Filter)
 	}
 
+	if spec.HealthCheck != nil {
+		bsp.healthChecker = newHealthChecker(spec.HealthCheck)
+		defer bsp.runHealthCheck()
+	}
+
 	if spec.ServiceRegistry == "" || spec.ServiceName == "" {
 		bsp.createLoadBalancer(spec.LoadBalance, spec.Servers)
 		return
@@ -124,10 +152,90 @@ func (bsp *BaseServerPool) createLoadBalancer(spec *LoadBalanceSpec, servers []*
 		spec = &LoadBalanceSpec{}
 	}
 
-	lb := NewLoadBalancer(spec, servers)
+	bsp.mu.Lock()
+	bsp.lbSpec = spec
+	bsp.servers = servers
+	bsp.mu.Unlock()
+
+	bsp.refreshLoadBalancer()
+}
+
+// refreshLoadBalancer rebuilds the load balancer with the available
+// servers, that's, servers which are neither unhealthy nor ejected.
+func (bsp *BaseServerPool) refreshLoadBalancer() {
+	bsp.mu.Lock()
+	defer bsp.mu.Unlock()
+
+	if bsp.outlierDetector != nil {
+		bsp.outlierDetector.prune(bsp.servers)
+	}
+
+	servers := bsp.servers
+	if bsp.healthChecker != nil || bsp.outlierDetector != nil {
+		servers = make([]*Server, 0, len(bsp.servers))
+		for _, svr := range bsp.servers {
+			if bsp.isServerAvailable(svr) {
+				servers = append(servers, svr)
+			}
+		}
+	}
+
+	lb := NewLoadBalancer(bsp.lbSpec, servers)
 	bsp.loadBalancer.Store(lb)
 }
 
+func (bsp *BaseServerPool) isServerAvailable(svr *Server) bool {
+	if bsp.healthChecker != nil && !bsp.healthChecker.isHealthy(svr) {
+		return false
+	}
+	if bsp.outlierDetector != nil && bsp.outlierDetector.isEjected(svr) {
+		return false
+	}
+	return true
+}
+
+// allServers returns all servers of the pool, including unavailable ones.
+func (bsp *BaseServerPool) allServers() []*Server {
+	bsp.mu.Lock()
+	defer bsp.mu.Unlock()
+	return bsp.servers
+}
+
+func (bsp *BaseServerPool) runHealthCheck() {
+	check := func() {
+		if bsp.healthChecker.check(bsp.name, bsp.allServers()) {
+			bsp.refreshLoadBalancer()
+		}
+	}
+
+	bsp.wg.Add(1)
+	go func() {
+		defer bsp.wg.Done()
+
+		ticker := time.NewTicker(bsp.healthChecker.interval)
+		defer ticker.Stop()
+
+		check()
+		for {
+			select {
+			case <-bsp.done:
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}()
+}
+
+// healthStatus returns the health status of the servers, it returns nil
+// if health check is not enabled.
+func (bsp *BaseServerPool) healthStatus() []*ServerHealthStatus {
+	if bsp.healthChecker == nil {
+		return nil
+	}
+	return bsp.healthChecker.status()
+}
+
 func (bsp *BaseServerPool) useService(spec *BaseServerPoolSpec, instances map[string]*serviceregistry.ServiceInstanceSpec) {
 	servers := make([]*Server, 0)
 
@@ -159,7 +267,21 @@ func (bsp *BaseServerPool) useService(spec *BaseServerPoolSpec, instances map[st
 	bsp.createLoadBalancer(spec.LoadBalance, servers)
 }
 
-func (bsp *BaseServerPool) close() {
+// ejectionStatus returns the ejection status of the servers, it returns
+// nil if outlier detection is not enabled.
+func (bsp *BaseServerPool) ejectionStatus() []*ServerEjectionStatus {
+	if bsp.outlierDetector == nil {
+		return nil
+	}
+	return bsp.outlierDetector.status()
+}
+
+// Close closes the server pool.
+func (bsp *BaseServerPool) Close() {
 	close(bsp.done)
 	bsp.wg.Wait()
+
+	if bsp.outlierDetector != nil {
+		bsp.outlierDetector.close()
+	}
 }
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+
+	"github.com/megaease/easegress/pkg/protocols"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+)
+
+const (
+	protocolHTTP = "http"
+	protocolGRPC = "grpc"
+)
+
+// defaultGRPCFailureCodes are the gRPC status codes treated as failures
+// if failureCodes is not specified, they are the codes generated by the
+// server side errors.
+var defaultGRPCFailureCodes = map[int]struct{}{
+	int(codes.Unknown):          {},
+	int(codes.DeadlineExceeded): {},
+	int(codes.Unimplemented):    {},
+	int(codes.Internal):         {},
+	int(codes.Unavailable):      {},
+	int(codes.DataLoss):         {},
+}
+
+// grpcTransport sends requests to servers over HTTP/2, with TLS for https
+// servers and cleartext (h2c) for http servers.
+type grpcTransport struct {
+	h2  *http2.Transport
+	h2c *http2.Transport
+}
+
+func newGRPCTransport(tlsCfg *tls.Config) *grpcTransport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 60 * time.Second,
+	}
+
+	return &grpcTransport{
+		h2: &http2.Transport{
+			TLSClientConfig: tlsCfg,
+		},
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" {
+		return t.h2.RoundTrip(req)
+	}
+	return t.h2c.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the transport.
+func (t *grpcTransport) CloseIdleConnections() {
+	t.h2.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
+
+// validateGRPCSpec validates the spec in gRPC mode.
+func validateGRPCSpec(s *Spec) error {
+	if s.Compression != nil {
+		return fmt.Errorf("compression is not supported in gRPC mode")
+	}
+	if s.MirrorPool != nil {
+		return fmt.Errorf("mirrorPool is not supported in gRPC mode")
+	}
+
+	for i, pool := range s.Pools {
+		if pool.MemoryCache != nil {
+			return fmt.Errorf("pool %d: memoryCache is not supported in gRPC mode", i)
+		}
+		for _, code := range pool.FailureCodes {
+			if code < 0 || code > int(codes.Unauthenticated) {
+				return fmt.Errorf("pool %d: invalid gRPC status code %d in failureCodes", i, code)
+			}
+		}
+	}
+
+	return nil
+}
+
+// toHTTPRequest returns the HTTP request of a request, the request could
+// be created by the HTTP or gRPC protocol.
+func toHTTPRequest(req protocols.Request) *httpprot.Request {
+	if r, ok := req.(*grpcprot.Request); ok {
+		return r.Request
+	}
+	return req.(*httpprot.Request)
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc/codes"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/resilience"
+	"github.com/megaease/easegress/pkg/tracing"
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+func TestGRPCSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	validate := func(yamlConfig string) error {
+		spec := &Spec{}
+		err := codectool.Unmarshal([]byte(yamlConfig), spec)
+		assert.NoError(err)
+		return spec.Validate()
+	}
+
+	assert.NoError(validate(`
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+  failureCodes: [14]
+`))
+
+	// invalid gRPC status code
+	assert.Error(validate(`
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+  failureCodes: [503]
+`))
+
+	// memory cache is not supported
+	assert.Error(validate(`
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+  memoryCache:
+    methods: [POST]
+`))
+
+	// compression is not supported
+	assert.Error(validate(`
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+compression:
+  minLength: 1024
+`))
+
+	// mirror pool is not supported
+	assert.Error(validate(`
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+mirrorPool:
+  filter:
+    headers:
+      "X-Mirror":
+        exact: mirror
+  servers:
+  - url: http://127.0.0.3:9095
+`))
+}
+
+func newGRPCRequest(fullMethod string) *http.Request {
+	stdr, _ := http.NewRequest(http.MethodPost, "http://www.megaease.com"+fullMethod, strings.NewReader("message"))
+	stdr.ProtoMajor = 2
+	stdr.Header.Set("Content-Type", grpcprot.ContentType)
+	stdr.Header.Set("Te", "trailers")
+	return stdr
+}
+
+func TestGRPCProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	const yamlConfig = `
+name: proxy
+kind: Proxy
+protocol: grpc
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+- filter:
+    grpcMethods:
+    - service:
+        exact: helloworld.Greeter
+      method:
+        exact: SayHello
+  servers:
+  - url: http://127.0.0.2:9095
+`
+	proxy := newTestProxy(yamlConfig, assert)
+	proxy.InjectResiliencePolicy(make(map[string]resilience.Policy))
+	defer proxy.Close()
+
+	var host, te string
+	var grpcStatus string
+	var sendErr error
+	fnSendRequest = func(r *http.Request, client *http.Client) (*http.Response, error) {
+		host, te = r.URL.Host, r.Header.Get("Te")
+		if sendErr != nil {
+			return nil, sendErr
+		}
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("reply")),
+		}
+		resp.Header.Set("Content-Type", grpcprot.ContentType)
+		if grpcStatus != "" {
+			resp.Header.Set(grpcprot.HeaderStatus, grpcStatus)
+		}
+		return resp, nil
+	}
+
+	// routed by service and method.
+	ctx := getCtx(newGRPCRequest("/helloworld.Greeter/SayHello"))
+	assert.Equal("", proxy.Handle(ctx))
+	assert.Equal("127.0.0.2:9095", host)
+	assert.Equal("trailers", te)
+	resp := ctx.GetOutputResponse().(*httpprot.Response)
+	assert.True(resp.IsStream())
+	data, _ := io.ReadAll(resp.GetPayload())
+	assert.Equal("reply", string(data))
+	ctx.Finish()
+
+	ctx = getCtx(newGRPCRequest("/helloworld.Greeter/SayGoodbye"))
+	assert.Equal("", proxy.Handle(ctx))
+	assert.Equal("127.0.0.1:9095", host)
+	ctx.Finish()
+
+	// status codes of server side errors are failures by default.
+	grpcStatus = "14"
+	ctx = getCtx(newGRPCRequest("/helloworld.Greeter/SayHello"))
+	assert.Equal(resultFailureCode, proxy.Handle(ctx))
+	ctx.Finish()
+
+	grpcStatus = "5"
+	ctx = getCtx(newGRPCRequest("/helloworld.Greeter/SayHello"))
+	assert.Equal("", proxy.Handle(ctx))
+	ctx.Finish()
+
+	// failure responses are gRPC ones.
+	sendErr = fmt.Errorf("mocked error")
+	ctx = getCtx(newGRPCRequest("/helloworld.Greeter/SayHello"))
+	assert.Equal(resultServerError, proxy.Handle(ctx))
+	resp = ctx.GetOutputResponse().(*httpprot.Response)
+	assert.Equal(http.StatusOK, resp.StatusCode())
+	assert.Equal(codes.Unavailable, grpcprot.FromHTTPResponse(resp).GRPCStatus().Code())
+	ctx.Finish()
+
+	// requests created by the gRPC protocol are accepted.
+	sendErr = nil
+	req, _ := grpcprot.NewRequest(newGRPCRequest("/helloworld.Greeter/SayHello"))
+	ctx = context.New(tracing.NoopSpan)
+	ctx.SetRequest(context.DefaultNamespace, req)
+	assert.Equal("", proxy.Handle(ctx))
+	assert.Equal("127.0.0.2:9095", host)
+	ctx.Finish()
+}
+
+func TestGRPCTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(2, r.ProtoMajor)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", grpcprot.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		w.Header().Set(http.TrailerPrefix+grpcprot.HeaderStatus, "0")
+	})
+	svr := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer svr.Close()
+
+	client := &http.Client{Transport: newGRPCTransport(nil)}
+	defer client.CloseIdleConnections()
+
+	stdr, _ := http.NewRequest(http.MethodPost, svr.URL+"/helloworld.Greeter/SayHello", strings.NewReader("message"))
+	stdr.Header.Set("Content-Type", grpcprot.ContentType)
+	resp, err := client.Do(stdr)
+	assert.NoError(err)
+	defer resp.Body.Close()
+
+	assert.Equal(2, resp.ProtoMajor)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal("message", string(body))
+	assert.Equal("0", resp.Trailer.Get(grpcprot.HeaderStatus))
+}
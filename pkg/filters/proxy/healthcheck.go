@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	stdcontext "context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 3 * time.Second
+)
+
+type (
+	// HealthCheckSpec is the spec of the active health check of a server pool.
+	HealthCheckSpec struct {
+		Path               string `json:"path" jsonschema:"omitempty"`
+		Interval           string `json:"interval" jsonschema:"omitempty,format=duration"`
+		Timeout            string `json:"timeout" jsonschema:"omitempty,format=duration"`
+		HealthyThreshold   int    `json:"healthyThreshold" jsonschema:"omitempty,minimum=1"`
+		UnhealthyThreshold int    `json:"unhealthyThreshold" jsonschema:"omitempty,minimum=1"`
+		// ExpectedCodes would be 2xx and 3xx if it isn't assigned any value.
+		ExpectedCodes []int `json:"expectedCodes" jsonschema:"omitempty,uniqueItems=true,format=httpcode-array"`
+	}
+
+	// ServerHealthStatus is the health status of a server.
+	ServerHealthStatus struct {
+		URL                  string `json:"url"`
+		Healthy              bool   `json:"healthy"`
+		ConsecutiveSuccesses int    `json:"consecutiveSuccesses"`
+		ConsecutiveFailures  int    `json:"consecutiveFailures"`
+		LastCheckTime        string `json:"lastCheckTime,omitempty"`
+		LastError            string `json:"lastError,omitempty"`
+	}
+
+	// healthChecker probes servers periodically and records their health.
+	healthChecker struct {
+		spec          *HealthCheckSpec
+		interval      time.Duration
+		timeout       time.Duration
+		expectedCodes map[int]struct{}
+		client        *http.Client
+
+		mu     sync.RWMutex
+		states map[string]*serverHealth
+	}
+
+	// serverHealth is the health state of a server, the key is the URL of
+	// the server, so that the state is kept when the server list is
+	// refreshed by the service registry.
+	serverHealth struct {
+		healthy   bool
+		successes int
+		failures  int
+		lastCheck time.Time
+		lastError string
+	}
+)
+
+// Validate validates HealthCheckSpec.
+func (spec *HealthCheckSpec) Validate() error {
+	if spec.Path != "" && !strings.HasPrefix(spec.Path, "/") {
+		return fmt.Errorf("path must start with '/'")
+	}
+
+	interval, timeout := spec.durations()
+	if timeout >= interval {
+		return fmt.Errorf("timeout must be less than interval")
+	}
+
+	return nil
+}
+
+func (spec *HealthCheckSpec) durations() (interval, timeout time.Duration) {
+	interval = defaultHealthCheckInterval
+	if spec.Interval != "" {
+		if d, err := time.ParseDuration(spec.Interval); err == nil && d > 0 {
+			interval = d
+		}
+	}
+
+	timeout = defaultHealthCheckTimeout
+	if spec.Timeout != "" {
+		if d, err := time.ParseDuration(spec.Timeout); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+
+	return
+}
+
+func newHealthChecker(spec *HealthCheckSpec) *healthChecker {
+	hc := &healthChecker{
+		spec:          spec,
+		expectedCodes: map[int]struct{}{},
+		states:        map[string]*serverHealth{},
+	}
+
+	hc.interval, hc.timeout = spec.durations()
+	for _, code := range spec.ExpectedCodes {
+		hc.expectedCodes[code] = struct{}{}
+	}
+
+	hc.client = &http.Client{
+		Timeout: hc.timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return hc
+}
+
+func (hc *healthChecker) healthyThreshold() int {
+	if hc.spec.HealthyThreshold > 0 {
+		return hc.spec.HealthyThreshold
+	}
+	return 1
+}
+
+func (hc *healthChecker) unhealthyThreshold() int {
+	if hc.spec.UnhealthyThreshold > 0 {
+		return hc.spec.UnhealthyThreshold
+	}
+	return 1
+}
+
+func (hc *healthChecker) isExpectedCode(code int) bool {
+	if len(hc.expectedCodes) == 0 {
+		return code >= 200 && code < 400
+	}
+	_, exists := hc.expectedCodes[code]
+	return exists
+}
+
+// isHealthy returns whether the server is healthy, servers which have not
+// been checked yet are regarded as healthy.
+func (hc *healthChecker) isHealthy(svr *Server) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	state := hc.states[svr.URL]
+	return state == nil || state.healthy
+}
+
+// probeURL returns the URL used to probe the server.
+func (hc *healthChecker) probeURL(svr *Server) string {
+	u := strings.TrimSuffix(svr.URL, "/")
+	if strings.HasPrefix(u, "ws://") {
+		u = "http://" + u[len("ws://"):]
+	} else if strings.HasPrefix(u, "wss://") {
+		u = "https://" + u[len("wss://"):]
+	}
+
+	path := hc.spec.Path
+	if path == "" {
+		path = "/"
+	}
+	return u + path
+}
+
+// probe sends a probe request to the server, it returns nil if the server
+// responds with one of the expected status codes.
+func (hc *healthChecker) probe(svr *Server) error {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), hc.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.probeURL(svr), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if !hc.isExpectedCode(resp.StatusCode) {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// check probes all the servers once, and returns whether the health of
+// any server has changed.
+func (hc *healthChecker) check(name string, servers []*Server) bool {
+	errs := make([]error, len(servers))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(servers))
+	for i, svr := range servers {
+		go func(i int, svr *Server) {
+			defer wg.Done()
+			errs[i] = hc.probe(svr)
+		}(i, svr)
+	}
+	wg.Wait()
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	states := make(map[string]*serverHealth, len(servers))
+
+	for i, svr := range servers {
+		state := hc.states[svr.URL]
+		if state == nil {
+			state = &serverHealth{healthy: true}
+		}
+		states[svr.URL] = state
+		state.lastCheck = now
+
+		if err := errs[i]; err != nil {
+			state.lastError = err.Error()
+			state.successes = 0
+			state.failures++
+			if state.healthy && state.failures >= hc.unhealthyThreshold() {
+				logger.Warnf("%s: server %s becomes unhealthy: %v", name, svr.URL, err)
+				state.healthy = false
+				changed = true
+			}
+			continue
+		}
+
+		state.lastError = ""
+		state.failures = 0
+		state.successes++
+		if !state.healthy && state.successes >= hc.healthyThreshold() {
+			logger.Infof("%s: server %s becomes healthy", name, svr.URL)
+			state.healthy = true
+			changed = true
+		}
+	}
+
+	// servers not in the list anymore are removed.
+	hc.states = states
+	return changed
+}
+
+func (hc *healthChecker) status() []*ServerHealthStatus {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	result := make([]*ServerHealthStatus, 0, len(hc.states))
+	for url, state := range hc.states {
+		s := &ServerHealthStatus{
+			URL:                  url,
+			Healthy:              state.healthy,
+			ConsecutiveSuccesses: state.successes,
+			ConsecutiveFailures:  state.failures,
+			LastError:            state.lastError,
+		}
+		if !state.lastCheck.IsZero() {
+			s.LastCheckTime = state.lastCheck.Format(time.RFC3339)
+		}
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
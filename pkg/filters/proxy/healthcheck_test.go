@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &HealthCheckSpec{}
+	assert.NoError(spec.Validate())
+
+	spec.Path = "health"
+	assert.Error(spec.Validate())
+
+	spec.Path = "/health"
+	spec.Interval = "1s"
+	spec.Timeout = "2s"
+	assert.Error(spec.Validate())
+
+	spec.Timeout = "500ms"
+	assert.NoError(spec.Validate())
+}
+
+func TestHealthChecker(t *testing.T) {
+	assert := assert.New(t)
+
+	var code int32 = http.StatusOK
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/healthz", r.URL.Path)
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer svr.Close()
+
+	hc := newHealthChecker(&HealthCheckSpec{
+		Path:               "/healthz",
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 2,
+	})
+
+	good := &Server{URL: svr.URL}
+	bad := &Server{URL: "http://127.0.0.1:0"}
+	servers := []*Server{good, bad}
+
+	// servers are healthy before the first check.
+	assert.True(hc.isHealthy(good))
+	assert.True(hc.isHealthy(bad))
+
+	assert.False(hc.check("test", servers))
+	assert.True(hc.check("test", servers))
+	assert.True(hc.isHealthy(good))
+	assert.False(hc.isHealthy(bad))
+
+	atomic.StoreInt32(&code, http.StatusInternalServerError)
+	hc.check("test", servers)
+	assert.True(hc.isHealthy(good))
+	hc.check("test", servers)
+	assert.False(hc.isHealthy(good))
+
+	atomic.StoreInt32(&code, http.StatusOK)
+	hc.check("test", servers)
+	assert.False(hc.isHealthy(good))
+	hc.check("test", servers)
+	assert.True(hc.isHealthy(good))
+
+	status := hc.status()
+	assert.Len(status, 2)
+	assert.Equal("http://127.0.0.1:0", status[0].URL)
+	assert.False(status[0].Healthy)
+	assert.NotEmpty(status[0].LastError)
+
+	// states of removed servers are dropped.
+	hc.check("test", []*Server{good})
+	assert.Len(hc.status(), 1)
+}
+
+func TestBaseServerPoolHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &BaseServerPoolSpec{
+		Servers: []*Server{
+			{URL: "http://192.168.1.1"},
+			{URL: "http://192.168.1.2"},
+		},
+		HealthCheck: &HealthCheckSpec{},
+	}
+
+	bsp := &BaseServerPool{name: "test"}
+	bsp.healthChecker = newHealthChecker(spec.HealthCheck)
+	bsp.createLoadBalancer(spec.LoadBalance, spec.Servers)
+
+	bsp.healthChecker.states["http://192.168.1.1"] = &serverHealth{healthy: false}
+	bsp.refreshLoadBalancer()
+
+	for i := 0; i < 10; i++ {
+		svr := bsp.LoadBalancer().ChooseServer(nil)
+		assert.Equal("http://192.168.1.2", svr.URL)
+	}
+	assert.Len(bsp.allServers(), 2)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/supervisor"
+)
+
+// Layer4ServerPool is a server pool of the layer 4 proxies, e.g. TCPServer,
+// it chooses servers for client connections rather than HTTP requests.
+type Layer4ServerPool struct {
+	BaseServerPool
+}
+
+// Layer4ServerPoolSpec is the spec of a layer 4 server pool. The URLs of
+// the servers are in the format of "tcp://host:port" or "udp://host:port",
+// only the host and port are used.
+type Layer4ServerPoolSpec struct {
+	ServerTags      []string         `json:"serverTags" jsonschema:"omitempty,uniqueItems=true"`
+	Servers         []*Server        `json:"servers" jsonschema:"omitempty"`
+	ServiceRegistry string           `json:"serviceRegistry" jsonschema:"omitempty"`
+	ServiceName     string           `json:"serviceName" jsonschema:"omitempty"`
+	LoadBalance     *LoadBalanceSpec `json:"loadBalance" jsonschema:"omitempty"`
+}
+
+// Validate validates Layer4ServerPoolSpec.
+func (spec *Layer4ServerPoolSpec) Validate() error {
+	if err := spec.baseSpec().Validate(); err != nil {
+		return err
+	}
+
+	for _, svr := range spec.Servers {
+		if _, err := layer4Addr(svr.URL); err != nil {
+			return err
+		}
+	}
+
+	lb := spec.LoadBalance
+	if lb == nil {
+		return nil
+	}
+
+	// policies depending on HTTP requests, or on latencies of requests,
+	// are meaningless to connections.
+	switch lb.Policy {
+	case "", LoadBalancePolicyRoundRobin, LoadBalancePolicyRandom,
+		LoadBalancePolicyWeightedRandom, LoadBalancePolicyIPHash,
+		LoadBalancePolicyLeastConnections:
+	case LoadBalancePolicyRingHash:
+		if lb.HashKeySource != "" && lb.HashKeySource != HashKeySourceIP {
+			return fmt.Errorf("loadBalance: hashKeySource %s is not supported", lb.HashKeySource)
+		}
+	default:
+		return fmt.Errorf("loadBalance: policy %s is not supported", lb.Policy)
+	}
+
+	if lb.StickySession != nil {
+		return fmt.Errorf("loadBalance: stickySession is not supported")
+	}
+
+	return nil
+}
+
+func (spec *Layer4ServerPoolSpec) baseSpec() *BaseServerPoolSpec {
+	return &BaseServerPoolSpec{
+		ServerTags:      spec.ServerTags,
+		Servers:         spec.Servers,
+		ServiceRegistry: spec.ServiceRegistry,
+		ServiceName:     spec.ServiceName,
+		LoadBalance:     spec.LoadBalance,
+	}
+}
+
+// layer4Addr returns the address of the server in the format of host:port.
+func layer4Addr(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server url %s: %v", serverURL, err)
+	}
+	if u.Port() == "" {
+		return "", fmt.Errorf("invalid server url %s: port is required", serverURL)
+	}
+	return u.Host, nil
+}
+
+// NewLayer4ServerPool creates a layer 4 server pool according to spec.
+func NewLayer4ServerPool(super *supervisor.Supervisor, spec *Layer4ServerPoolSpec, name string) *Layer4ServerPool {
+	sp := &Layer4ServerPool{}
+	sp.Init(super, name, spec.baseSpec())
+	return sp
+}
+
+// ChooseServer chooses a server for the client, and returns the address
+// of the server and a function which must be called after the connection
+// or session to the server is closed. It returns an error if there's no
+// available server.
+func (sp *Layer4ServerPool) ChooseServer(clientAddr string) (string, func(), error) {
+	// the load balancers choose servers for HTTP requests, a request
+	// carrying the address of the client is created for them.
+	req, _ := httpprot.NewRequest(&http.Request{
+		RemoteAddr: clientAddr,
+		Header:     http.Header{},
+		URL:        &url.URL{},
+	})
+
+	lb := sp.LoadBalancer()
+	svr := lb.ChooseServer(req)
+	if svr == nil {
+		return "", nil, fmt.Errorf("no available server")
+	}
+
+	done := func() {
+		lb.ReturnServer(svr, req, nil)
+	}
+
+	addr, err := layer4Addr(svr.URL)
+	if err != nil {
+		done()
+		return "", nil, err
+	}
+	return addr, done, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayer4ServerPoolSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Layer4ServerPoolSpec{}
+	assert.Error(spec.Validate())
+
+	spec.Servers = []*Server{{URL: "tcp://192.168.1.1:3306"}}
+	assert.NoError(spec.Validate())
+
+	spec.Servers = []*Server{{URL: "tcp://192.168.1.1"}}
+	assert.Error(spec.Validate())
+
+	spec.Servers = []*Server{{URL: "tcp://192.168.1.1:3306"}}
+	spec.LoadBalance = &LoadBalanceSpec{Policy: LoadBalancePolicyLeastConnections}
+	assert.NoError(spec.Validate())
+
+	spec.LoadBalance = &LoadBalanceSpec{Policy: LoadBalancePolicyHeaderHash}
+	assert.Error(spec.Validate())
+
+	spec.LoadBalance = &LoadBalanceSpec{Policy: LoadBalancePolicyRingHash, HashKeySource: HashKeySourceCookie, HashKey: "id"}
+	assert.Error(spec.Validate())
+
+	spec.LoadBalance = &LoadBalanceSpec{StickySession: &StickySessionSpec{}}
+	assert.Error(spec.Validate())
+}
+
+func TestLayer4ServerPool(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Layer4ServerPoolSpec{
+		Servers: []*Server{
+			{URL: "tcp://192.168.1.1:3306"},
+			{URL: "tcp://192.168.1.2:3306"},
+		},
+		LoadBalance: &LoadBalanceSpec{Policy: LoadBalancePolicyIPHash},
+	}
+	assert.NoError(spec.Validate())
+
+	sp := NewLayer4ServerPool(nil, spec, "test")
+	defer sp.Close()
+
+	addr, done, err := sp.ChooseServer("10.0.0.1:12345")
+	assert.NoError(err)
+	done()
+	for i := 0; i < 10; i++ {
+		addr1, done, err := sp.ChooseServer("10.0.0.1:23456")
+		assert.NoError(err)
+		assert.Equal(addr, addr1)
+		done()
+	}
+
+	spec = &Layer4ServerPoolSpec{
+		Servers:     []*Server{{URL: "tcp://192.168.1.1:3306"}},
+		LoadBalance: &LoadBalanceSpec{Policy: LoadBalancePolicyLeastConnections},
+	}
+	sp = NewLayer4ServerPool(nil, spec, "test")
+	defer sp.Close()
+
+	addr, done, err = sp.ChooseServer("10.0.0.1:12345")
+	assert.NoError(err)
+	assert.Equal("192.168.1.1:3306", addr)
+	assert.Equal(int64(1), spec.Servers[0].inFlightRequests())
+	done()
+	assert.Equal(int64(0), spec.Servers[0].inFlightRequests())
+}
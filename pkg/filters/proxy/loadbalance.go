@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"hash/fnv"
 	"math/rand"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/megaease/easegress/pkg/logger"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
@@ -38,21 +40,92 @@ const (
 	LoadBalancePolicyIPHash = "ipHash"
 	// LoadBalancePolicyHeaderHash is the load balance policy of HTTP header hash.
 	LoadBalancePolicyHeaderHash = "headerHash"
+	// LoadBalancePolicyLeastConnections is the load balance policy of least connections.
+	LoadBalancePolicyLeastConnections = "leastConnections"
+	// LoadBalancePolicyPeakEWMA is the load balance policy of peak EWMA
+	// latency with power of two choices.
+	LoadBalancePolicyPeakEWMA = "peakEWMA"
+	// LoadBalancePolicyRingHash is the load balance policy of consistent
+	// hashing based on a hash ring.
+	LoadBalancePolicyRingHash = "ringHash"
+)
+
+const (
+	// HashKeySourceIP uses the real IP of the client as the hash key.
+	HashKeySourceIP = "ip"
+	// HashKeySourceHeader uses the value of an HTTP header as the hash key.
+	HashKeySourceHeader = "header"
+	// HashKeySourceCookie uses the value of a cookie as the hash key.
+	HashKeySourceCookie = "cookie"
+	// HashKeySourceQuery uses the value of a URL query parameter as the
+	// hash key.
+	HashKeySourceQuery = "query"
 )
 
 // LoadBalancer is the interface of an HTTP load balancer.
 type LoadBalancer interface {
+	// ChooseServer chooses a server for the request.
 	ChooseServer(req *httpprot.Request) *Server
+	// ReturnServer returns the server chosen by ChooseServer after the
+	// request is processed, resp is nil if the request failed without
+	// a response.
+	ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response)
 }
 
 // LoadBalanceSpec is the spec to create a load balancer.
 type LoadBalanceSpec struct {
-	Policy        string `json:"policy" jsonschema:"omitempty,enum=,enum=roundRobin,enum=random,enum=weightedRandom,enum=ipHash,enum=headerHash"`
+	Policy        string `json:"policy" jsonschema:"omitempty,enum=,enum=roundRobin,enum=random,enum=weightedRandom,enum=ipHash,enum=headerHash,enum=leastConnections,enum=peakEWMA,enum=ringHash"`
 	HeaderHashKey string `json:"headerHashKey" jsonschema:"omitempty"`
+
+	// HashKeySource, HashKey and BoundedLoadFactor are for the ringHash
+	// policy only.
+	HashKeySource     string  `json:"hashKeySource" jsonschema:"omitempty,enum=,enum=ip,enum=header,enum=cookie,enum=query"`
+	HashKey           string  `json:"hashKey" jsonschema:"omitempty"`
+	BoundedLoadFactor float64 `json:"boundedLoadFactor" jsonschema:"omitempty"`
+
+	StickySession *StickySessionSpec `json:"stickySession,omitempty" jsonschema:"omitempty"`
+}
+
+// Validate validates LoadBalanceSpec.
+func (s *LoadBalanceSpec) Validate() error {
+	if s.StickySession != nil {
+		if err := s.StickySession.Validate(); err != nil {
+			return fmt.Errorf("stickySession: %v", err)
+		}
+	}
+
+	if s.Policy != LoadBalancePolicyRingHash {
+		return nil
+	}
+
+	switch s.HashKeySource {
+	case "", HashKeySourceIP:
+	case HashKeySourceHeader, HashKeySourceCookie, HashKeySourceQuery:
+		if s.HashKey == "" {
+			return fmt.Errorf("hashKey is required when hashKeySource is %s", s.HashKeySource)
+		}
+	default:
+		return fmt.Errorf("unknown hashKeySource: %s", s.HashKeySource)
+	}
+
+	if s.BoundedLoadFactor != 0 && s.BoundedLoadFactor <= 1 {
+		return fmt.Errorf("boundedLoadFactor must be greater than 1")
+	}
+
+	return nil
 }
 
 // NewLoadBalancer creates a load balancer for servers according to spec.
 func NewLoadBalancer(spec *LoadBalanceSpec, servers []*Server) LoadBalancer {
+	lb := newPolicyLoadBalancer(spec, servers)
+	if spec.StickySession != nil {
+		return newStickySessionLoadBalancer(spec.StickySession, lb, servers)
+	}
+	return lb
+}
+
+// newPolicyLoadBalancer creates a load balancer according to the policy.
+func newPolicyLoadBalancer(spec *LoadBalanceSpec, servers []*Server) LoadBalancer {
 	switch spec.Policy {
 	case LoadBalancePolicyRoundRobin, "":
 		return newRoundRobinLoadBalancer(servers)
@@ -64,6 +137,12 @@ func NewLoadBalancer(spec *LoadBalanceSpec, servers []*Server) LoadBalancer {
 		return newIPHashLoadBalancer(servers)
 	case LoadBalancePolicyHeaderHash:
 		return newHeaderHashLoadBalancer(servers, spec.HeaderHashKey)
+	case LoadBalancePolicyLeastConnections:
+		return newLeastConnectionsLoadBalancer(servers)
+	case LoadBalancePolicyPeakEWMA:
+		return newPeakEWMALoadBalancer(servers)
+	case LoadBalancePolicyRingHash:
+		return newRingHashLoadBalancer(spec, servers)
 	default:
 		logger.Errorf("unsupported load balancing policy: %s", spec.Policy)
 		return newRoundRobinLoadBalancer(servers)
@@ -75,6 +154,10 @@ type BaseLoadBalancer struct {
 	Servers []*Server
 }
 
+// ReturnServer implements the LoadBalancer interface, it does nothing.
+func (blb *BaseLoadBalancer) ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response) {
+}
+
 // randomLoadBalancer does load balancing in a random manner.
 type randomLoadBalancer struct {
 	BaseLoadBalancer
@@ -203,3 +286,95 @@ func (lb *headerHashLoadBalancer) ChooseServer(req *httpprot.Request) *Server {
 	hash.Write([]byte(v))
 	return lb.Servers[hash.Sum32()%uint32(len(lb.Servers))]
 }
+
+// leastConnectionsLoadBalancer does load balancing by choosing the server
+// with the least in-flight requests.
+type leastConnectionsLoadBalancer struct {
+	BaseLoadBalancer
+	counter uint64
+}
+
+func newLeastConnectionsLoadBalancer(servers []*Server) *leastConnectionsLoadBalancer {
+	return &leastConnectionsLoadBalancer{
+		BaseLoadBalancer: BaseLoadBalancer{
+			Servers: servers,
+		},
+	}
+}
+
+// ChooseServer implements the LoadBalancer interface.
+func (lb *leastConnectionsLoadBalancer) ChooseServer(req *httpprot.Request) *Server {
+	if len(lb.Servers) == 0 {
+		return nil
+	}
+
+	// start from a different server each time, so that servers with the
+	// same number of in-flight requests are chosen in turn.
+	start := int(atomic.AddUint64(&lb.counter, 1) % uint64(len(lb.Servers)))
+
+	var svr *Server
+	for i := 0; i < len(lb.Servers); i++ {
+		s := lb.Servers[(start+i)%len(lb.Servers)]
+		if svr == nil || s.inFlightRequests() < svr.inFlightRequests() {
+			svr = s
+		}
+	}
+
+	svr.incInFlight()
+	return svr
+}
+
+// ReturnServer implements the LoadBalancer interface.
+func (lb *leastConnectionsLoadBalancer) ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response) {
+	server.decInFlight()
+}
+
+// peakEWMALoadBalancer does load balancing based on the peak EWMA of the
+// response latency and the in-flight requests of servers, it picks two
+// servers randomly and chooses the one with the lower cost.
+type peakEWMALoadBalancer struct {
+	BaseLoadBalancer
+	startTimes sync.Map
+}
+
+func newPeakEWMALoadBalancer(servers []*Server) *peakEWMALoadBalancer {
+	return &peakEWMALoadBalancer{
+		BaseLoadBalancer: BaseLoadBalancer{
+			Servers: servers,
+		},
+	}
+}
+
+// ChooseServer implements the LoadBalancer interface.
+func (lb *peakEWMALoadBalancer) ChooseServer(req *httpprot.Request) *Server {
+	var svr *Server
+
+	switch len(lb.Servers) {
+	case 0:
+		return nil
+	case 1:
+		svr = lb.Servers[0]
+	default:
+		i := rand.Intn(len(lb.Servers))
+		j := rand.Intn(len(lb.Servers) - 1)
+		if j >= i {
+			j++
+		}
+		svr = lb.Servers[i]
+		if s := lb.Servers[j]; s.latencyCost() < svr.latencyCost() {
+			svr = s
+		}
+	}
+
+	svr.incInFlight()
+	lb.startTimes.Store(req, time.Now())
+	return svr
+}
+
+// ReturnServer implements the LoadBalancer interface.
+func (lb *peakEWMALoadBalancer) ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response) {
+	server.decInFlight()
+	if v, ok := lb.startTimes.LoadAndDelete(req); ok {
+		server.observeLatency(time.Since(v.(time.Time)))
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"math/rand"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/stretchr/testify/assert"
@@ -161,3 +162,90 @@ func TestHeaderHashLoadBalancer(t *testing.T) {
 		assert.GreaterOrEqual(counter[i], 1)
 	}
 }
+
+func TestLeastConnectionsLoadBalancer(t *testing.T) {
+	assert := assert.New(t)
+
+	var svrs []*Server
+	lb := NewLoadBalancer(&LoadBalanceSpec{Policy: "leastConnections"}, svrs)
+	assert.Nil(lb.ChooseServer(nil))
+
+	svrs = prepareServers(3)
+	lb = NewLoadBalancer(&LoadBalanceSpec{Policy: "leastConnections"}, svrs)
+
+	// servers with the same number of in-flight requests are chosen in turn.
+	chosen := map[int]int{}
+	for i := 0; i < 3; i++ {
+		svr := lb.ChooseServer(nil)
+		chosen[svr.Weight]++
+	}
+	assert.Len(chosen, 3)
+	for _, svr := range svrs {
+		assert.Equal(int64(1), svr.inFlightRequests())
+	}
+
+	// server 2 finished its request, it is the only choice.
+	lb.ReturnServer(svrs[1], nil, nil)
+	for i := 0; i < 3; i++ {
+		svr := lb.ChooseServer(nil)
+		assert.Equal(2, svr.Weight)
+		lb.ReturnServer(svr, nil, nil)
+	}
+}
+
+func TestPeakEWMALoadBalancer(t *testing.T) {
+	assert := assert.New(t)
+
+	var svrs []*Server
+	lb := NewLoadBalancer(&LoadBalanceSpec{Policy: "peakEWMA"}, svrs)
+	assert.Nil(lb.ChooseServer(nil))
+
+	svrs = prepareServers(1)
+	lb = NewLoadBalancer(&LoadBalanceSpec{Policy: "peakEWMA"}, svrs)
+	r, _ := httpprot.NewRequest(&http.Request{Header: http.Header{}})
+	svr := lb.ChooseServer(r)
+	assert.Equal(svrs[0], svr)
+	assert.Equal(int64(1), svr.inFlightRequests())
+	lb.ReturnServer(svr, r, nil)
+	assert.Equal(int64(0), svr.inFlightRequests())
+	assert.Greater(svr.latencyCost(), float64(0))
+
+	svrs = prepareServers(2)
+	svrs[0].observeLatency(100 * time.Millisecond)
+	svrs[1].observeLatency(time.Millisecond)
+	lb = NewLoadBalancer(&LoadBalanceSpec{Policy: "peakEWMA"}, svrs)
+	for i := 0; i < 10; i++ {
+		svr := lb.ChooseServer(nil)
+		assert.Equal(2, svr.Weight)
+		svr.decInFlight()
+	}
+
+	// the slow server is chosen when the fast one is too busy.
+	for i := 0; i < 200; i++ {
+		svrs[1].incInFlight()
+	}
+	assert.Equal(1, lb.ChooseServer(nil).Weight)
+}
+
+func TestServerObserveLatency(t *testing.T) {
+	assert := assert.New(t)
+
+	svr := &Server{}
+	assert.Equal(float64(0), svr.latencyCost())
+
+	svr.observeLatency(10 * time.Millisecond)
+	assert.Equal(float64(10*time.Millisecond), svr.latencyCost())
+
+	// peaks are used directly.
+	svr.observeLatency(50 * time.Millisecond)
+	assert.Equal(float64(50*time.Millisecond), svr.latencyCost())
+
+	// lower values decay the EWMA.
+	svr.observeLatency(time.Millisecond)
+	cost := svr.latencyCost()
+	assert.LessOrEqual(cost, float64(50*time.Millisecond))
+	assert.GreaterOrEqual(cost, float64(time.Millisecond))
+
+	svr.incInFlight()
+	assert.Equal(cost*2, svr.latencyCost())
+}
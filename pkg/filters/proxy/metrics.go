@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot/httpstat"
+	libcb "github.com/megaease/easegress/pkg/util/circuitbreaker"
+	"github.com/megaease/easegress/pkg/util/prometheushelper"
+)
+
+// circuitBreakerStates is the states exported by the circuit breaker
+// state metric, one time series per state, the value of the current
+// state is 1 and others are 0.
+var circuitBreakerStates = []string{"Disabled", "Closed", "HalfOpen", "Open", "ForceOpen"}
+
+// metrics is the Prometheus metrics of a server pool.
+type metrics struct {
+	labels         prometheus.Labels
+	requests       *prometheus.CounterVec
+	duration       prometheus.Observer
+	shortCircuited prometheus.Counter
+	cbState        *prometheus.GaugeVec
+}
+
+var poolLabels = []string{"pipelineName", "proxyName", "poolName"}
+
+func newMetrics(sp *ServerPool) *metrics {
+	var pipelineName, proxyName string
+	if sp.proxy.spec != nil {
+		pipelineName = sp.proxy.spec.Pipeline()
+		proxyName = sp.proxy.Name()
+	}
+
+	poolName := strings.TrimPrefix(sp.name, "proxy#"+proxyName+"#")
+	labels := prometheushelper.Labels(sp.proxy.super,
+		"pipelineName", pipelineName,
+		"proxyName", proxyName,
+		"poolName", poolName,
+	)
+	prometheushelper.Acquire(labels)
+
+	return &metrics{
+		labels: labels,
+		requests: prometheushelper.NewCounter(
+			"proxy_requests_total",
+			"the total number of requests sent to the backend servers",
+			append(poolLabels, "code"),
+		).MustCurryWith(labels),
+		duration: prometheushelper.NewHistogram(
+			"proxy_request_duration_seconds",
+			"the duration of the requests sent to the backend servers",
+			prometheushelper.DurationBuckets,
+			poolLabels,
+		).With(labels),
+		shortCircuited: prometheushelper.NewCounter(
+			"proxy_short_circuited_total",
+			"the total number of requests short circuited by the circuit breaker",
+			poolLabels,
+		).With(labels),
+		cbState: prometheushelper.NewGauge(
+			"proxy_circuit_breaker_state",
+			"the state of the circuit breaker, 1 for the current state",
+			append(poolLabels, "state"),
+		).MustCurryWith(labels),
+	}
+}
+
+// close deletes the series of the server pool.
+func (m *metrics) close() {
+	prometheushelper.Release(m.labels)
+}
+
+func (m *metrics) stat(metric *httpstat.Metric) {
+	m.requests.WithLabelValues(strconv.Itoa(metric.StatusCode)).Inc()
+	m.duration.Observe(metric.Duration.Seconds())
+}
+
+// watchCircuitBreaker exports the state of the circuit breaker, it does
+// nothing if cb is not a circuit breaker.
+func (m *metrics) watchCircuitBreaker(cb interface{}) {
+	listenable, ok := cb.(interface {
+		SetStateListener(listener libcb.EventListenerFunc)
+	})
+	if !ok {
+		return
+	}
+
+	// a new circuit breaker is always closed.
+	m.setCircuitBreakerState("Closed")
+	listenable.SetStateListener(func(event *libcb.Event) {
+		m.setCircuitBreakerState(event.NewState)
+	})
+}
+
+func (m *metrics) setCircuitBreakerState(state string) {
+	for _, s := range circuitBreakerStates {
+		v := 0.0
+		if s == state {
+			v = 1
+		}
+		m.cbState.WithLabelValues(s).Set(v)
+	}
+}
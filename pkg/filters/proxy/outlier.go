@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const (
+	defaultOutlierConsecutiveFailures = 5
+	defaultOutlierBaseEjectionTime    = 30 * time.Second
+	defaultOutlierMaxEjectionTime     = 300 * time.Second
+	defaultOutlierMaxEjectionPercent  = 10
+)
+
+type (
+	// OutlierDetectionSpec is the spec of the passive outlier detection of
+	// a server pool.
+	OutlierDetectionSpec struct {
+		ConsecutiveFailures int    `json:"consecutiveFailures" jsonschema:"omitempty,minimum=1"`
+		BaseEjectionTime    string `json:"baseEjectionTime" jsonschema:"omitempty,format=duration"`
+		MaxEjectionTime     string `json:"maxEjectionTime" jsonschema:"omitempty,format=duration"`
+		MaxEjectionPercent  int    `json:"maxEjectionPercent" jsonschema:"omitempty,minimum=1,maximum=100"`
+	}
+
+	// ServerEjectionStatus is the ejection status of a server.
+	ServerEjectionStatus struct {
+		URL                 string `json:"url"`
+		Ejected             bool   `json:"ejected"`
+		EjectedUntil        string `json:"ejectedUntil,omitempty"`
+		Ejections           int    `json:"ejections"`
+		ConsecutiveFailures int    `json:"consecutiveFailures"`
+	}
+
+	// outlierDetector tracks the consecutive failures of servers from real
+	// traffic, and ejects servers which fail too many times.
+	outlierDetector struct {
+		consecutiveFailures int
+		baseEjectionTime    time.Duration
+		maxEjectionTime     time.Duration
+		maxEjectionPercent  int
+
+		// onRestore is called when an ejected server is restored.
+		onRestore func()
+
+		mu     sync.Mutex
+		states map[string]*outlierState
+	}
+
+	// outlierState is the outlier state of a server, the key is the URL of
+	// the server.
+	outlierState struct {
+		failures     int
+		ejections    int
+		ejectedUntil time.Time
+		timer        *time.Timer
+	}
+)
+
+// Validate validates OutlierDetectionSpec.
+func (spec *OutlierDetectionSpec) Validate() error {
+	base, maxTime := spec.ejectionTimes()
+	if base > maxTime {
+		return fmt.Errorf("baseEjectionTime must not be greater than maxEjectionTime")
+	}
+	return nil
+}
+
+func (spec *OutlierDetectionSpec) ejectionTimes() (base, maxTime time.Duration) {
+	base = defaultOutlierBaseEjectionTime
+	if spec.BaseEjectionTime != "" {
+		if d, err := time.ParseDuration(spec.BaseEjectionTime); err == nil && d > 0 {
+			base = d
+		}
+	}
+
+	maxTime = defaultOutlierMaxEjectionTime
+	if spec.MaxEjectionTime != "" {
+		if d, err := time.ParseDuration(spec.MaxEjectionTime); err == nil && d > 0 {
+			maxTime = d
+		}
+	}
+
+	return
+}
+
+func newOutlierDetector(spec *OutlierDetectionSpec, onRestore func()) *outlierDetector {
+	od := &outlierDetector{
+		consecutiveFailures: spec.ConsecutiveFailures,
+		maxEjectionPercent:  spec.MaxEjectionPercent,
+		onRestore:           onRestore,
+		states:              map[string]*outlierState{},
+	}
+
+	if od.consecutiveFailures <= 0 {
+		od.consecutiveFailures = defaultOutlierConsecutiveFailures
+	}
+	if od.maxEjectionPercent <= 0 {
+		od.maxEjectionPercent = defaultOutlierMaxEjectionPercent
+	}
+	od.baseEjectionTime, od.maxEjectionTime = spec.ejectionTimes()
+
+	return od
+}
+
+// isEjected returns whether the server is ejected.
+func (od *outlierDetector) isEjected(svr *Server) bool {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	state := od.states[svr.URL]
+	return state != nil && state.timer != nil
+}
+
+// ejectionTime returns the ejection time of the n-th ejection, it grows
+// exponentially and is capped by maxEjectionTime.
+func (od *outlierDetector) ejectionTime(n int) time.Duration {
+	d := od.baseEjectionTime
+	for i := 1; i < n && d < od.maxEjectionTime; i++ {
+		d *= 2
+	}
+	if d > od.maxEjectionTime {
+		d = od.maxEjectionTime
+	}
+	return d
+}
+
+// recordSuccess records a successful call to the server.
+func (od *outlierDetector) recordSuccess(svr *Server) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	state := od.states[svr.URL]
+	if state == nil || state.timer != nil {
+		return
+	}
+
+	// the server works well, reset its state.
+	delete(od.states, svr.URL)
+}
+
+// recordFailure records a failed call to the server, total is the number
+// of servers in the pool, which is used to calculate the max number of
+// servers could be ejected. It returns true if the server is ejected.
+func (od *outlierDetector) recordFailure(name string, svr *Server, total int) bool {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	state := od.states[svr.URL]
+	if state == nil {
+		state = &outlierState{}
+		od.states[svr.URL] = state
+	}
+
+	// the server is already ejected, this could happen if the request
+	// is sent before the ejection.
+	if state.timer != nil {
+		return false
+	}
+
+	state.failures++
+	if state.failures < od.consecutiveFailures {
+		return false
+	}
+
+	// at least one server could be ejected regardless of the percentage.
+	maxEjected := total * od.maxEjectionPercent / 100
+	if maxEjected < 1 {
+		maxEjected = 1
+	}
+	if od.ejectedCount() >= maxEjected {
+		return false
+	}
+
+	state.failures = 0
+	state.ejections++
+	d := od.ejectionTime(state.ejections)
+	state.ejectedUntil = time.Now().Add(d)
+
+	url := svr.URL
+	state.timer = time.AfterFunc(d, func() {
+		od.restore(name, url, state)
+	})
+
+	logger.Warnf("%s: server %s is ejected for %s", name, url, d)
+	return true
+}
+
+// restore brings an ejected server back to the load balancer. the
+// ejection count is kept so that the next ejection lasts longer, it is
+// reset when the server serves a request successfully.
+func (od *outlierDetector) restore(name, url string, state *outlierState) {
+	od.mu.Lock()
+	if od.states[url] != state {
+		od.mu.Unlock()
+		return
+	}
+	state.timer = nil
+	state.ejectedUntil = time.Time{}
+	od.mu.Unlock()
+
+	logger.Infof("%s: server %s is restored", name, url)
+	od.onRestore()
+}
+
+// ejectedCount returns the number of ejected servers, the caller must
+// hold the lock.
+func (od *outlierDetector) ejectedCount() int {
+	count := 0
+	for _, state := range od.states {
+		if state.timer != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// prune removes the states of servers which are not in the pool anymore,
+// so that servers which come and go with service discovery do not leak
+// states, and do not occupy the quota of ejected servers.
+func (od *outlierDetector) prune(servers []*Server) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	urls := make(map[string]struct{}, len(servers))
+	for _, svr := range servers {
+		urls[svr.URL] = struct{}{}
+	}
+
+	for url, state := range od.states {
+		if _, ok := urls[url]; ok {
+			continue
+		}
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(od.states, url)
+	}
+}
+
+// close stops all ejection timers.
+func (od *outlierDetector) close() {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	for url, state := range od.states {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(od.states, url)
+	}
+}
+
+func (od *outlierDetector) status() []*ServerEjectionStatus {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	result := make([]*ServerEjectionStatus, 0, len(od.states))
+	for url, state := range od.states {
+		s := &ServerEjectionStatus{
+			URL:                 url,
+			Ejected:             state.timer != nil,
+			Ejections:           state.ejections,
+			ConsecutiveFailures: state.failures,
+		}
+		if s.Ejected {
+			s.EjectedUntil = state.ejectedUntil.Format(time.RFC3339)
+		}
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutlierDetectionSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &OutlierDetectionSpec{}
+	assert.NoError(spec.Validate())
+
+	spec.BaseEjectionTime = "10m"
+	assert.Error(spec.Validate())
+
+	spec.MaxEjectionTime = "1h"
+	assert.NoError(spec.Validate())
+}
+
+func TestOutlierDetectorEjectionTime(t *testing.T) {
+	assert := assert.New(t)
+
+	od := newOutlierDetector(&OutlierDetectionSpec{
+		BaseEjectionTime: "10s",
+		MaxEjectionTime:  "50s",
+	}, func() {})
+
+	assert.Equal(10*time.Second, od.ejectionTime(1))
+	assert.Equal(20*time.Second, od.ejectionTime(2))
+	assert.Equal(40*time.Second, od.ejectionTime(3))
+	assert.Equal(50*time.Second, od.ejectionTime(4))
+	assert.Equal(50*time.Second, od.ejectionTime(100))
+}
+
+func TestOutlierDetector(t *testing.T) {
+	assert := assert.New(t)
+
+	restored := make(chan struct{}, 1)
+	od := newOutlierDetector(&OutlierDetectionSpec{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    "100ms",
+		MaxEjectionPercent:  50,
+	}, func() { restored <- struct{}{} })
+	defer od.close()
+
+	svrs := prepareServers(4)
+	for i := range svrs {
+		svrs[i].URL = "http://192.168.1." + string(rune('1'+i))
+	}
+
+	// a success resets the consecutive failures.
+	assert.False(od.recordFailure("test", svrs[0], 4))
+	assert.False(od.recordFailure("test", svrs[0], 4))
+	od.recordSuccess(svrs[0])
+	assert.False(od.recordFailure("test", svrs[0], 4))
+	assert.False(od.recordFailure("test", svrs[0], 4))
+	assert.False(od.isEjected(svrs[0]))
+	assert.True(od.recordFailure("test", svrs[0], 4))
+	assert.True(od.isEjected(svrs[0]))
+
+	// max ejection percent
+	for i := 0; i < 3; i++ {
+		od.recordFailure("test", svrs[1], 4)
+	}
+	assert.True(od.isEjected(svrs[1]))
+	for i := 0; i < 3; i++ {
+		assert.False(od.recordFailure("test", svrs[2], 4))
+	}
+	assert.False(od.isEjected(svrs[2]))
+
+	status := od.status()
+	assert.Len(status, 3)
+	assert.True(status[0].Ejected)
+	assert.Equal(1, status[0].Ejections)
+	assert.NotEmpty(status[0].EjectedUntil)
+
+	select {
+	case <-restored:
+	case <-time.After(time.Second):
+		t.Fatal("server should be restored")
+	}
+	<-restored
+	assert.False(od.isEjected(svrs[0]))
+	assert.False(od.isEjected(svrs[1]))
+}
+
+func TestOutlierDetectorPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	od := newOutlierDetector(&OutlierDetectionSpec{
+		ConsecutiveFailures: 1,
+		MaxEjectionPercent:  50,
+	}, func() {})
+	defer od.close()
+
+	svrs := prepareServers(2)
+	svrs[0].URL = "http://192.168.1.1"
+	svrs[1].URL = "http://192.168.1.2"
+
+	assert.True(od.recordFailure("test", svrs[0], 2))
+	assert.Len(od.status(), 1)
+
+	// the ejected server left the pool, its state is removed, and the
+	// quota of ejected servers is released.
+	od.prune(svrs[1:])
+	assert.Empty(od.status())
+	assert.False(od.isEjected(svrs[0]))
+	assert.True(od.recordFailure("test", svrs[1], 2))
+}
+
+func TestServerPoolOutlierDetection(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &ServerPoolSpec{
+		BaseServerPoolSpec: BaseServerPoolSpec{
+			Servers: []*Server{
+				{URL: "http://192.168.1.1"},
+				{URL: "http://192.168.1.2"},
+			},
+		},
+		OutlierDetection: &OutlierDetectionSpec{
+			ConsecutiveFailures: 1,
+			MaxEjectionPercent:  50,
+		},
+	}
+	assert.NoError(spec.Validate())
+
+	sp := NewServerPool(&Proxy{}, spec, "test")
+	defer sp.Close()
+
+	sp.recordFailure(spec.Servers[0])
+	for i := 0; i < 10; i++ {
+		svr := sp.LoadBalancer().ChooseServer(nil)
+		assert.Equal("http://192.168.1.2", svr.URL)
+	}
+
+	status := sp.status()
+	assert.Len(status.ServerEjections, 1)
+	assert.True(status.ServerEjections[0].Ejected)
+}
@@ -24,12 +24,14 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
+	"sync"
 	"time"
 
 	gohttpstat "github.com/tcnksm/go-httpstat"
 
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot/httpstat"
 	"github.com/megaease/easegress/pkg/resilience"
@@ -142,6 +144,12 @@ func (spCtx *serverPoolContext) prepareRequest(svr *Server, ctx stdcontext.Conte
 	stdr.Header = req.HTTPHeader().Clone()
 	removeHopByHopHeaders(stdr.Header)
 
+	// "TE: trailers" is required by gRPC, but it is removed as a hop-by-hop
+	// header, so add it back.
+	if grpcprot.IsGRPCContentType(stdr.Header.Get("Content-Type")) {
+		stdr.Header.Set("Te", "trailers")
+	}
+
 	// only set host when server address is not host name OR
 	// server is explicitly told to keep the host of the request.
 	if !svr.addrIsHostName || svr.KeepHost {
@@ -162,6 +170,7 @@ type ServerPool struct {
 
 	proxy        *Proxy
 	spec         *ServerPoolSpec
+	grpc         bool
 	failureCodes map[int]struct{}
 
 	timeout               time.Duration
@@ -169,6 +178,7 @@ type ServerPool struct {
 	circuitBreakerWrapper resilience.Wrapper
 
 	httpStat    *httpstat.HTTPStat
+	metrics     *metrics
 	memoryCache *MemoryCache
 }
 
@@ -183,13 +193,34 @@ type ServerPoolSpec struct {
 	CircuitBreakerPolicy string           `json:"circuitBreakerPolicy" jsonschema:"omitempty"`
 	MemoryCache          *MemoryCacheSpec `json:"memoryCache,omitempty" jsonschema:"omitempty"`
 
-	// FailureCodes would be 5xx if it isn't assigned any value.
+	// FailureCodes would be 5xx if it isn't assigned any value. In gRPC
+	// mode, they are gRPC status codes, and would be the codes of server
+	// side errors if it isn't assigned any value.
 	FailureCodes []int `json:"failureCodes" jsonschema:"omitempty,uniqueItems=true"`
+
+	OutlierDetection *OutlierDetectionSpec `json:"outlierDetection,omitempty" jsonschema:"omitempty"`
+}
+
+// Validate validates ServerPoolSpec.
+func (sps *ServerPoolSpec) Validate() error {
+	if err := sps.BaseServerPoolSpec.Validate(); err != nil {
+		return err
+	}
+
+	if sps.OutlierDetection != nil {
+		if err := sps.OutlierDetection.Validate(); err != nil {
+			return fmt.Errorf("outlierDetection: %v", err)
+		}
+	}
+
+	return nil
 }
 
 // ServerPoolStatus is the status of Pool.
 type ServerPoolStatus struct {
-	Stat *httpstat.Status `json:"stat"`
+	Stat            *httpstat.Status        `json:"stat"`
+	ServerHealth    []*ServerHealthStatus   `json:"serverHealth,omitempty"`
+	ServerEjections []*ServerEjectionStatus `json:"serverEjections,omitempty"`
 }
 
 // NewServerPool creates a new server pool according to spec.
@@ -197,9 +228,17 @@ func NewServerPool(proxy *Proxy, spec *ServerPoolSpec, name string) *ServerPool
 	sp := &ServerPool{
 		proxy:    proxy,
 		spec:     spec,
+		grpc:     proxy.isGRPC(),
 		httpStat: httpstat.New(),
 	}
+
+	// outlier detector must be created before the initialization of the
+	// base server pool as it is used in building the load balancer.
+	if spec.OutlierDetection != nil {
+		sp.outlierDetector = newOutlierDetector(spec.OutlierDetection, sp.refreshLoadBalancer)
+	}
 	sp.BaseServerPool.Init(proxy.super, name, &spec.BaseServerPoolSpec)
+	sp.metrics = newMetrics(sp)
 
 	if spec.MemoryCache != nil {
 		sp.memoryCache = NewMemoryCache(spec.MemoryCache)
@@ -213,15 +252,30 @@ func NewServerPool(proxy *Proxy, spec *ServerPoolSpec, name string) *ServerPool
 	for _, code := range spec.FailureCodes {
 		sp.failureCodes[code] = struct{}{}
 	}
+	if sp.grpc && len(sp.failureCodes) == 0 {
+		for code := range defaultGRPCFailureCodes {
+			sp.failureCodes[code] = struct{}{}
+		}
+	}
 
 	return sp
 }
 
 func (sp *ServerPool) status() *ServerPoolStatus {
-	s := &ServerPoolStatus{Stat: sp.httpStat.Status()}
+	s := &ServerPoolStatus{
+		Stat:            sp.httpStat.Status(),
+		ServerHealth:    sp.healthStatus(),
+		ServerEjections: sp.ejectionStatus(),
+	}
 	return s
 }
 
+// Close closes the server pool.
+func (sp *ServerPool) Close() {
+	sp.BaseServerPool.Close()
+	sp.metrics.close()
+}
+
 // InjectResiliencePolicy injects resilience policies to the server pool.
 func (sp *ServerPool) InjectResiliencePolicy(policies map[string]resilience.Policy) {
 	name := sp.spec.RetryPolicy
@@ -248,6 +302,7 @@ func (sp *ServerPool) InjectResiliencePolicy(policies map[string]resilience.Poli
 			panic(fmt.Errorf("policy %s is not a circuitBreaker policy", name))
 		}
 		sp.circuitBreakerWrapper = policy.CreateWrapper()
+		sp.metrics.watchCircuitBreaker(sp.circuitBreakerWrapper)
 	}
 }
 
@@ -262,6 +317,7 @@ func (sp *ServerPool) collectMetrics(spCtx *serverPoolContext) {
 	collect := func() {
 		metric.Duration = fasttime.Since(spCtx.startTime)
 		sp.httpStat.Stat(metric)
+		sp.metrics.stat(metric)
 		spCtx.LazyAddTag(func() string {
 			return sp.name + "#duration: " + metric.Duration.String()
 		})
@@ -292,10 +348,12 @@ func (sp *ServerPool) collectMetrics(spCtx *serverPoolContext) {
 }
 
 func (sp *ServerPool) handleMirror(spCtx *serverPoolContext) {
-	svr := sp.LoadBalancer().ChooseServer(spCtx.req)
+	lb := sp.LoadBalancer()
+	svr := lb.ChooseServer(spCtx.req)
 	if svr == nil {
 		return
 	}
+	defer lb.ReturnServer(svr, spCtx.req, nil)
 
 	err := spCtx.prepareRequest(svr, spCtx.req.Context(), true)
 	if err != nil {
@@ -315,7 +373,7 @@ func (sp *ServerPool) handleMirror(spCtx *serverPoolContext) {
 func (sp *ServerPool) handle(ctx *context.Context, mirror bool) string {
 	spCtx := &serverPoolContext{
 		Context: ctx,
-		req:     ctx.GetInputRequest().(*httpprot.Request),
+		req:     toHTTPRequest(ctx.GetInputRequest()),
 	}
 
 	if mirror {
@@ -339,7 +397,16 @@ func (sp *ServerPool) handle(ctx *context.Context, mirror bool) string {
 		if sp.timeout > 0 {
 			var cancel stdcontext.CancelFunc
 			stdctx, cancel = stdcontext.WithTimeout(stdctx, sp.timeout)
-			defer cancel()
+			defer func() {
+				// the payload of a stream response is read after the
+				// handler returns, so the context can only be canceled
+				// after the payload is closed.
+				if spCtx.resp != nil && spCtx.resp.IsStream() {
+					spCtx.respCallbackBody.OnClose(cancel)
+				} else {
+					cancel()
+				}
+			}()
 		}
 
 		// this function could be called more than once, and these
@@ -379,6 +446,7 @@ func (sp *ServerPool) handle(ctx *context.Context, mirror bool) string {
 	if err == resilience.ErrShortCircuited {
 		logger.Errorf("%s: short circuited by circuit break policy", sp.name)
 		spCtx.AddTag("short circuited")
+		sp.metrics.shortCircuited.Inc()
 		sp.buildFailureResponse(spCtx, http.StatusServiceUnavailable)
 		return resultShortCircuited
 	}
@@ -397,7 +465,8 @@ func (sp *ServerPool) handle(ctx *context.Context, mirror bool) string {
 }
 
 func (sp *ServerPool) doHandle(stdctx stdcontext.Context, spCtx *serverPoolContext) error {
-	svr := sp.LoadBalancer().ChooseServer(spCtx.req)
+	lb := sp.LoadBalancer()
+	svr := lb.ChooseServer(spCtx.req)
 
 	// if there's no available server.
 	if svr == nil {
@@ -405,6 +474,26 @@ func (sp *ServerPool) doHandle(stdctx stdcontext.Context, spCtx *serverPoolConte
 		return serverPoolError{http.StatusServiceUnavailable, resultInternalError}
 	}
 
+	// the load balancer may be replaced when the request is in progress,
+	// so the server must be returned to the one which chose it.
+	defer func() {
+		// the payload of a stream response is transferred after this
+		// function returns, so the server is returned after the payload
+		// is closed, to count the request as in-flight until then. The
+		// payload could be closed more than once.
+		req, resp := spCtx.req, spCtx.resp
+		if resp != nil && resp.IsStream() && spCtx.respCallbackBody != nil {
+			var once sync.Once
+			spCtx.respCallbackBody.OnClose(func() {
+				once.Do(func() {
+					lb.ReturnServer(svr, req, resp)
+				})
+			})
+			return
+		}
+		lb.ReturnServer(svr, req, resp)
+	}()
+
 	// prepare the request to send.
 	statResult := &gohttpstat.Result{}
 	stdctx = gohttpstat.WithHTTPStat(stdctx, statResult)
@@ -423,8 +512,10 @@ func (sp *ServerPool) doHandle(stdctx stdcontext.Context, spCtx *serverPoolConte
 		})
 
 		if err := spCtx.stdReq.Context().Err(); err == nil {
+			sp.recordFailure(svr)
 			return serverPoolError{http.StatusServiceUnavailable, resultServerError}
 		} else if err == stdcontext.DeadlineExceeded {
+			sp.recordFailure(svr)
 			return serverPoolError{http.StatusRequestTimeout, resultTimeout}
 		}
 
@@ -442,18 +533,49 @@ func (sp *ServerPool) doHandle(stdctx stdcontext.Context, spCtx *serverPoolConte
 		return fmt.Sprintf("status code: %d", resp.StatusCode)
 	})
 
+	// In gRPC mode, the failure codes are gRPC status codes. Only the
+	// status of a Trailers-Only response is available now, and the status
+	// in the trailer is unknown until the payload is fully read, which is
+	// treated as OK here.
+	code := resp.StatusCode
+	if sp.grpc {
+		code = int(grpcprot.FromHTTPResponse(spCtx.resp).GRPCStatus().Code())
+		spCtx.LazyAddTag(func() string {
+			return fmt.Sprintf("grpc status: %d", code)
+		})
+	}
+
 	// If the status code is one of the failure codes, change result to
 	// resultFailureCode, but don't touch the response itself.
 	//
 	// This may be incorrect, but failure code is different from other
 	// errors, and it seems impossible to find a perfect solution.
-	if sp.inFailureCodes(resp.StatusCode) {
+	if sp.inFailureCodes(code) {
+		sp.recordFailure(svr)
 		return serverPoolError{resp.StatusCode, resultFailureCode}
 	}
 
+	sp.recordSuccess(svr)
 	return nil
 }
 
+// recordFailure records a failure of the server for outlier detection.
+func (sp *ServerPool) recordFailure(svr *Server) {
+	if sp.outlierDetector == nil {
+		return
+	}
+	if sp.outlierDetector.recordFailure(sp.name, svr, len(sp.allServers())) {
+		sp.refreshLoadBalancer()
+	}
+}
+
+// recordSuccess records a success of the server for outlier detection.
+func (sp *ServerPool) recordSuccess(svr *Server) {
+	if sp.outlierDetector != nil {
+		sp.outlierDetector.recordSuccess(svr)
+	}
+}
+
 func (sp *ServerPool) mergeResponseHeader(dst, src http.Header) http.Header {
 	for k, v := range src {
 		// CORS Headers
@@ -490,6 +612,11 @@ func (sp *ServerPool) buildResponse(spCtx *serverPoolContext) (err error) {
 	if maxBodySize == 0 {
 		maxBodySize = sp.proxy.spec.ServerMaxBodySize
 	}
+	// gRPC responses are always streams, as the calls could be streaming
+	// ones, and trailers are only available after the payload is read.
+	if sp.grpc {
+		maxBodySize = -1
+	}
 	if err = resp.FetchPayload(maxBodySize); err != nil {
 		logger.Errorf("%s: failed to fetch response payload: %v", sp.name, err)
 		body.Close()
@@ -573,7 +700,13 @@ func (sp *ServerPool) buildFailureResponse(spCtx *serverPoolContext, statusCode
 		resp, _ = httpprot.NewResponse(nil)
 	}
 
-	resp.SetStatusCode(statusCode)
+	if sp.grpc {
+		code := grpcprot.CodeFromHTTPStatus(statusCode)
+		grpcprot.FromHTTPResponse(resp).SetGRPCStatus(code, http.StatusText(statusCode))
+	} else {
+		resp.SetStatusCode(statusCode)
+	}
+
 	spCtx.resp = resp
 	spCtx.SetOutputResponse(resp)
 }
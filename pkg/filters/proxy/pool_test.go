@@ -18,7 +18,10 @@
 package proxy
 
 import (
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/megaease/easegress/pkg/context"
@@ -27,6 +30,7 @@ import (
 	"github.com/megaease/easegress/pkg/resilience"
 	"github.com/megaease/easegress/pkg/tracing"
 	"github.com/megaease/easegress/pkg/util/codectool"
+	"github.com/megaease/easegress/pkg/util/prometheushelper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -250,3 +254,84 @@ func TestInFailureCodes(t *testing.T) {
 	assert.False(sp.inFailureCodes(500))
 	assert.True(sp.inFailureCodes(400))
 }
+
+func TestReturnServerOfStreamResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	const yamlConfig = `
+name: proxy
+kind: Proxy
+serverMaxBodySize: -1
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+  loadBalance:
+    policy: leastConnections
+`
+	proxy := newTestProxy(yamlConfig, assert)
+	defer proxy.Close()
+
+	fnSendRequest = func(r *http.Request, client *http.Client) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("stream body")),
+		}, nil
+	}
+
+	svr := proxy.mainPool.allServers()[0]
+
+	stdr, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:9095", nil)
+	ctx := getCtx(stdr)
+	assert.Equal("", proxy.Handle(ctx))
+	resp := ctx.GetOutputResponse().(*httpprot.Response)
+	assert.True(resp.IsStream())
+
+	// the request is in-flight until the payload is closed.
+	assert.Equal(int64(1), svr.inFlightRequests())
+	io.ReadAll(resp.GetPayload())
+	assert.Equal(int64(1), svr.inFlightRequests())
+	resp.Close()
+	assert.Equal(int64(0), svr.inFlightRequests())
+	ctx.Finish()
+	assert.Equal(int64(0), svr.inFlightRequests())
+}
+
+func TestMetricsOfClosedPool(t *testing.T) {
+	assert := assert.New(t)
+
+	const yamlConfig = `
+name: proxy-metrics
+kind: Proxy
+pools:
+- servers:
+  - url: http://127.0.0.1:9095
+`
+	fnSendRequest = func(r *http.Request, client *http.Client) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("body")),
+		}, nil
+	}
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		prometheushelper.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+	const label = `proxyName="proxy-metrics"`
+
+	proxy := newTestProxy(yamlConfig, assert)
+	stdr, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:9095", nil)
+	assert.Equal("", proxy.Handle(getCtx(stdr)))
+	assert.Contains(scrape(), label)
+
+	// the series are kept when a previous generation is closed.
+	proxy2 := newTestProxy(yamlConfig, assert)
+	proxy.Close()
+	assert.Contains(scrape(), label)
+
+	proxy2.Close()
+	assert.NotContains(scrape(), label)
+}
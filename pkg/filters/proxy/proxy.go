@@ -29,7 +29,6 @@ import (
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/filters"
 	"github.com/megaease/easegress/pkg/logger"
-	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/resilience"
 	"github.com/megaease/easegress/pkg/supervisor"
 	"github.com/megaease/easegress/pkg/util/easemonitor"
@@ -104,6 +103,7 @@ type (
 	Spec struct {
 		filters.BaseSpec `json:",inline"`
 
+		Protocol            string            `json:"protocol" jsonschema:"omitempty,enum=,enum=http,enum=grpc"`
 		Pools               []*ServerPoolSpec `json:"pools" jsonschema:"required"`
 		MirrorPool          *ServerPoolSpec   `json:"mirrorPool,omitempty" jsonschema:"omitempty"`
 		Compression         *CompressionSpec  `json:"compression,omitempty" jsonschema:"omitempty"`
@@ -144,6 +144,12 @@ func (s *Spec) Validate() error {
 		return fmt.Errorf("one and only one mainPool is required")
 	}
 
+	if s.Protocol == protocolGRPC {
+		if err := validateGRPCSpec(s); err != nil {
+			return err
+		}
+	}
+
 	if s.MirrorPool != nil {
 		if s.MirrorPool.Filter == nil {
 			return fmt.Errorf("filter of mirrorPool is required")
@@ -235,6 +241,11 @@ func (p *Proxy) reload() {
 	}
 
 	tlsCfg, _ := p.tlsConfig()
+	if p.isGRPC() {
+		p.client = &http.Client{Transport: newGRPCTransport(tlsCfg)}
+		return
+	}
+
 	p.client = &http.Client{
 		// NOTE: Timeout could be no limit, real client or server could cancel it.
 		Timeout: 0,
@@ -261,6 +272,11 @@ func (p *Proxy) reload() {
 	}
 }
 
+// isGRPC returns whether the proxy is in gRPC mode.
+func (p *Proxy) isGRPC() bool {
+	return p.spec != nil && p.spec.Protocol == protocolGRPC
+}
+
 // Status returns Proxy status.
 func (p *Proxy) Status() interface{} {
 	s := &Status{
@@ -280,20 +296,22 @@ func (p *Proxy) Status() interface{} {
 
 // Close closes Proxy.
 func (p *Proxy) Close() {
-	p.mainPool.close()
+	p.mainPool.Close()
 
 	for _, v := range p.candidatePools {
-		v.close()
+		v.Close()
 	}
 
 	if p.mirrorPool != nil {
-		p.mirrorPool.close()
+		p.mirrorPool.Close()
 	}
+
+	p.client.CloseIdleConnections()
 }
 
 // Handle handles HTTPContext.
 func (p *Proxy) Handle(ctx *context.Context) (result string) {
-	req := ctx.GetInputRequest().(*httpprot.Request)
+	req := toHTTPRequest(ctx.GetInputRequest())
 
 	if p.mirrorPool != nil && p.mirrorPool.filter.Match(req) {
 		go p.mirrorPool.handle(ctx, true)
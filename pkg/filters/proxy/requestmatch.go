@@ -25,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/util/stringtool"
 )
@@ -40,6 +41,7 @@ type RequestMatcherSpec struct {
 	MatchAllHeaders bool                      `json:"matchAllHeaders" jsonschema:"omitempty"`
 	Headers         map[string]*StringMatcher `json:"headers" jsonschema:"omitempty"`
 	URLs            []*MethodAndURLMatcher    `json:"urls" jsonschema:"omitempty"`
+	GRPCMethods     []*GRPCMethodMatcher      `json:"grpcMethods" jsonschema:"omitempty"`
 	Permil          uint32                    `json:"permil" jsonschema:"omitempty,minimum=0,maximum=1000"`
 	HeaderHashKey   string                    `json:"headerHashKey" jsonschema:"omitempty"`
 }
@@ -47,7 +49,7 @@ type RequestMatcherSpec struct {
 // Validate validtes the RequestMatcherSpec.
 func (s *RequestMatcherSpec) Validate() error {
 	if s.Policy == "general" || s.Policy == "" {
-		if len(s.Headers) == 0 {
+		if len(s.Headers) == 0 && len(s.GRPCMethods) == 0 {
 			return fmt.Errorf("headers is not specified")
 		}
 	} else if s.Permil == 0 {
@@ -66,6 +68,12 @@ func (s *RequestMatcherSpec) Validate() error {
 		}
 	}
 
+	for _, m := range s.GRPCMethods {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if s.Policy == "headerHash" && s.HeaderHashKey == "" {
 		return fmt.Errorf("headerHash needs to specify headerHashKey")
 	}
@@ -81,6 +89,7 @@ func NewRequestMatcher(spec *RequestMatcherSpec) RequestMatcher {
 			matchAllHeaders: spec.MatchAllHeaders,
 			headers:         spec.Headers,
 			urls:            spec.URLs,
+			grpcMethods:     spec.GRPCMethods,
 		}
 		matcher.init()
 		return matcher
@@ -141,6 +150,7 @@ type generalMatcher struct {
 	matchAllHeaders bool
 	headers         map[string]*StringMatcher
 	urls            []*MethodAndURLMatcher
+	grpcMethods     []*GRPCMethodMatcher
 }
 
 func (gm *generalMatcher) init() {
@@ -151,21 +161,32 @@ func (gm *generalMatcher) init() {
 	for _, url := range gm.urls {
 		url.init()
 	}
+
+	for _, m := range gm.grpcMethods {
+		m.init()
+	}
 }
 
 // Match implements protocols.Matcher.
 func (gm *generalMatcher) Match(req *httpprot.Request) bool {
-	matched := false
-	if gm.matchAllHeaders {
-		matched = gm.matchAllHeader(req)
-	} else {
-		matched = gm.matchOneHeader(req)
+	// headers could be empty if gRPC methods are specified.
+	matched := true
+	if len(gm.headers) > 0 {
+		if gm.matchAllHeaders {
+			matched = gm.matchAllHeader(req)
+		} else {
+			matched = gm.matchOneHeader(req)
+		}
 	}
 
 	if matched && len(gm.urls) > 0 {
 		matched = gm.matchURL(req)
 	}
 
+	if matched && len(gm.grpcMethods) > 0 {
+		matched = gm.matchGRPCMethod(req)
+	}
+
 	return matched
 }
 
@@ -218,6 +239,51 @@ func (gm *generalMatcher) matchURL(req *httpprot.Request) bool {
 	return false
 }
 
+func (gm *generalMatcher) matchGRPCMethod(req *httpprot.Request) bool {
+	r := grpcprot.FromHTTPRequest(req)
+	for _, m := range gm.grpcMethods {
+		if m.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCMethodMatcher defines the match rule of a gRPC call.
+type GRPCMethodMatcher struct {
+	Service *StringMatcher `json:"service" jsonschema:"required"`
+	Method  *StringMatcher `json:"method,omitempty" jsonschema:"omitempty"`
+}
+
+// Validate validates the GRPCMethodMatcher.
+func (m *GRPCMethodMatcher) Validate() error {
+	if err := m.Service.Validate(); err != nil {
+		return fmt.Errorf("service: %v", err)
+	}
+	if m.Method != nil {
+		if err := m.Method.Validate(); err != nil {
+			return fmt.Errorf("method: %v", err)
+		}
+	}
+	return nil
+}
+
+func (m *GRPCMethodMatcher) init() {
+	m.Service.init()
+	if m.Method != nil {
+		m.Method.init()
+	}
+}
+
+// Match matches a gRPC call, all methods of the service are matched if
+// method is not specified.
+func (m *GRPCMethodMatcher) Match(req *grpcprot.Request) bool {
+	if !m.Service.Match(req.Service()) {
+		return false
+	}
+	return m.Method == nil || m.Method.Match(req.Method())
+}
+
 // MethodAndURLMatcher defines the match rule of a http request
 type MethodAndURLMatcher struct {
 	Methods []string       `json:"methods" jsonschema:"omitempty,uniqueItems=true,format=httpmethod-array"`
@@ -294,3 +294,43 @@ func TestStringMatcher(t *testing.T) {
 	assert.True(sm.Match("/hello"))
 	assert.False(sm.Match("/Hello"))
 }
+
+func TestGRPCMethodMatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &RequestMatcherSpec{
+		GRPCMethods: []*GRPCMethodMatcher{{
+			Service: &StringMatcher{},
+		}},
+	}
+	assert.Error(spec.Validate())
+
+	spec.GRPCMethods[0].Service.Exact = "helloworld.Greeter"
+	spec.GRPCMethods[0].Method = &StringMatcher{}
+	assert.Error(spec.Validate())
+
+	spec.GRPCMethods[0].Method.Prefix = "SayHello"
+	spec.GRPCMethods = append(spec.GRPCMethods, &GRPCMethodMatcher{
+		Service: &StringMatcher{RegEx: "^routeguide\\."},
+	})
+	assert.NoError(spec.Validate())
+
+	m := NewRequestMatcher(spec)
+
+	match := func(path string) bool {
+		stdr, _ := http.NewRequest(http.MethodPost, "http://megaease.com"+path, nil)
+		req, _ := httpprot.NewRequest(stdr)
+		return m.Match(req)
+	}
+
+	assert.True(match("/helloworld.Greeter/SayHello"))
+	assert.True(match("/helloworld.Greeter/SayHelloAgain"))
+	assert.False(match("/helloworld.Greeter/SayGoodbye"))
+	assert.True(match("/routeguide.RouteGuide/GetFeature"))
+	assert.False(match("/grpc.health.v1.Health/Check"))
+
+	// headers and gRPC methods must both match.
+	spec.Headers = map[string]*StringMatcher{"X-Test": {Exact: "test"}}
+	m = NewRequestMatcher(spec)
+	assert.False(match("/helloworld.Greeter/SayHello"))
+}
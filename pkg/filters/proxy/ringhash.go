@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+)
+
+// ringHashVirtualNodes is the number of virtual nodes of a server with
+// the average weight on the hash ring.
+const ringHashVirtualNodes = 100
+
+// ringHashLoadBalancer does load balancing based on consistent hashing,
+// only a small portion of the requests are remapped when servers are
+// added or removed. If bounded load is enabled, a server is skipped when
+// its in-flight requests exceed the load factor times the average.
+type ringHashLoadBalancer struct {
+	BaseLoadBalancer
+	spec    *LoadBalanceSpec
+	ring    []uint32
+	owners  map[uint32]*Server
+	weights map[*Server]float64
+}
+
+func newRingHashLoadBalancer(spec *LoadBalanceSpec, servers []*Server) *ringHashLoadBalancer {
+	lb := &ringHashLoadBalancer{
+		BaseLoadBalancer: BaseLoadBalancer{
+			Servers: servers,
+		},
+		spec:    spec,
+		owners:  map[uint32]*Server{},
+		weights: map[*Server]float64{},
+	}
+
+	if len(servers) == 0 {
+		return lb
+	}
+
+	// weight is relative to the average weight, servers got no weight
+	// are regarded as having the average weight.
+	totalWeight := 0
+	for _, svr := range servers {
+		totalWeight += svr.Weight
+	}
+	avgWeight := float64(totalWeight) / float64(len(servers))
+
+	for _, svr := range servers {
+		w := 1.0
+		if avgWeight > 0 {
+			w = float64(svr.Weight) / avgWeight
+		}
+		lb.weights[svr] = w
+
+		nodes := int(math.Round(w * ringHashVirtualNodes))
+		if nodes < 1 {
+			nodes = 1
+		}
+		for i := 0; i < nodes; i++ {
+			h := crc32.ChecksumIEEE([]byte(svr.URL + "#" + strconv.Itoa(i)))
+			// the first server wins in case of hash collisions.
+			if _, exists := lb.owners[h]; exists {
+				continue
+			}
+			lb.owners[h] = svr
+			lb.ring = append(lb.ring, h)
+		}
+	}
+
+	sort.Slice(lb.ring, func(i, j int) bool {
+		return lb.ring[i] < lb.ring[j]
+	})
+
+	return lb
+}
+
+// hashKey returns the hash key of the request.
+func (lb *ringHashLoadBalancer) hashKey(req *httpprot.Request) string {
+	if req == nil {
+		return ""
+	}
+
+	switch lb.spec.HashKeySource {
+	case HashKeySourceHeader:
+		return req.HTTPHeader().Get(lb.spec.HashKey)
+	case HashKeySourceCookie:
+		if c, err := req.Cookie(lb.spec.HashKey); err == nil {
+			return c.Value
+		}
+		return ""
+	case HashKeySourceQuery:
+		return req.URL().Query().Get(lb.spec.HashKey)
+	default:
+		return req.RealIP()
+	}
+}
+
+// averageLoad returns the average in-flight requests of the servers,
+// including the one being dispatched.
+func (lb *ringHashLoadBalancer) averageLoad() float64 {
+	var total int64
+	for _, s := range lb.Servers {
+		total += s.inFlightRequests()
+	}
+	return float64(total+1) / float64(len(lb.Servers))
+}
+
+// ChooseServer implements the LoadBalancer interface.
+func (lb *ringHashLoadBalancer) ChooseServer(req *httpprot.Request) *Server {
+	if len(lb.ring) == 0 {
+		return nil
+	}
+
+	var svr *Server
+
+	key := lb.hashKey(req)
+	if key == "" {
+		// requests without hash key are distributed randomly.
+		svr = lb.Servers[rand.Intn(len(lb.Servers))]
+		svr.incInFlight()
+		return svr
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(lb.ring), func(i int) bool {
+		return lb.ring[i] >= h
+	})
+
+	svr = lb.owners[lb.ring[idx%len(lb.ring)]]
+	if lb.spec.BoundedLoadFactor > 0 {
+		// walk along the ring to find the first server whose load is
+		// under its capacity, fallback to the owner of the hash if none.
+		avg := lb.averageLoad()
+		for i := 0; i < len(lb.ring); i++ {
+			s := lb.owners[lb.ring[(idx+i)%len(lb.ring)]]
+			capacity := math.Ceil(avg * lb.spec.BoundedLoadFactor * lb.weights[s])
+			if float64(s.inFlightRequests()) < capacity {
+				svr = s
+				break
+			}
+		}
+	}
+
+	svr.incInFlight()
+	return svr
+}
+
+// ReturnServer implements the LoadBalancer interface.
+func (lb *ringHashLoadBalancer) ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response) {
+	server.decInFlight()
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareRingHashServers(count int, weighted bool) []*Server {
+	svrs := make([]*Server, 0, count)
+	for i := 0; i < count; i++ {
+		svr := &Server{URL: fmt.Sprintf("http://192.168.1.%d", i+1)}
+		if weighted {
+			svr.Weight = i + 1
+		}
+		svrs = append(svrs, svr)
+	}
+	return svrs
+}
+
+func TestLoadBalanceSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &LoadBalanceSpec{Policy: "ringHash"}
+	assert.NoError(spec.Validate())
+
+	spec.HashKeySource = "header"
+	assert.Error(spec.Validate())
+
+	spec.HashKey = "X-User"
+	assert.NoError(spec.Validate())
+
+	spec.HashKeySource = "unknown"
+	assert.Error(spec.Validate())
+
+	spec.HashKeySource = "cookie"
+	spec.BoundedLoadFactor = 0.5
+	assert.Error(spec.Validate())
+
+	spec.BoundedLoadFactor = 1.25
+	assert.NoError(spec.Validate())
+}
+
+func TestRingHashLoadBalancer(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &LoadBalanceSpec{
+		Policy:        "ringHash",
+		HashKeySource: "query",
+		HashKey:       "user",
+	}
+
+	lb := NewLoadBalancer(spec, nil)
+	assert.Nil(lb.ChooseServer(nil))
+
+	newRequest := func(user string) *httpprot.Request {
+		stdr, _ := http.NewRequest(http.MethodGet, "http://www.megaease.com/?user="+user, nil)
+		r, _ := httpprot.NewRequest(stdr)
+		return r
+	}
+
+	svrs := prepareRingHashServers(10, false)
+	lb = NewLoadBalancer(spec, svrs)
+
+	chosen := map[string]*Server{}
+	counter := map[*Server]int{}
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		req := newRequest(user)
+		svr := lb.ChooseServer(req)
+		lb.ReturnServer(svr, req, nil)
+
+		chosen[user] = svr
+		counter[svr]++
+
+		// same key, same server.
+		assert.Equal(svr, lb.ChooseServer(req))
+		lb.ReturnServer(svr, req, nil)
+	}
+	assert.Len(counter, 10)
+
+	// remove a server, only keys mapped to it are remapped.
+	removed := svrs[3]
+	lb = NewLoadBalancer(spec, append(svrs[:3:3], svrs[4:]...))
+	for user, old := range chosen {
+		svr := lb.ChooseServer(newRequest(user))
+		if old != removed {
+			assert.Equal(old, svr)
+		} else {
+			assert.NotEqual(removed, svr)
+		}
+	}
+
+	// requests without hash key are distributed randomly.
+	assert.NotNil(lb.ChooseServer(nil))
+}
+
+func TestRingHashLoadBalancerWeight(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &LoadBalanceSpec{Policy: "ringHash", HashKeySource: "header", HashKey: "X-User"}
+	svrs := prepareRingHashServers(2, true)
+	svrs[1].Weight = 3
+	lb := NewLoadBalancer(spec, svrs)
+
+	counter := [2]int{}
+	for i := 0; i < 10000; i++ {
+		req := &http.Request{Header: http.Header{}}
+		req.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		r, _ := httpprot.NewRequest(req)
+		svr := lb.ChooseServer(r)
+		lb.ReturnServer(svr, r, nil)
+		if svr == svrs[0] {
+			counter[0]++
+		} else {
+			counter[1]++
+		}
+	}
+
+	assert.Greater(counter[1], counter[0]*2)
+}
+
+func TestRingHashLoadBalancerBoundedLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &LoadBalanceSpec{
+		Policy:            "ringHash",
+		HashKeySource:     "header",
+		HashKey:           "X-User",
+		BoundedLoadFactor: 1.25,
+	}
+	svrs := prepareRingHashServers(4, false)
+	lb := NewLoadBalancer(spec, svrs)
+
+	req := &http.Request{Header: http.Header{}}
+	req.Header.Set("X-User", "hot-user")
+	r, _ := httpprot.NewRequest(req)
+
+	// all requests have the same key, but no server gets more than its
+	// capacity.
+	for i := 0; i < 100; i++ {
+		lb.ChooseServer(r)
+	}
+	for _, svr := range svrs {
+		assert.LessOrEqual(svr.inFlightRequests(), int64(32))
+		assert.Greater(svr.inFlightRequests(), int64(0))
+	}
+}
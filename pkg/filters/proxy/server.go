@@ -19,13 +19,32 @@ package proxy
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// peakEWMADecay is the decay time of the peak EWMA latency.
+	peakEWMADecay = 10 * time.Second
+
+	// peakEWMAPenalty is the cost of a server which has in-flight
+	// requests but no latency observed yet.
+	peakEWMAPenalty = float64(math.MaxInt32)
 )
 
 // Server is proxy server.
 type Server struct {
+	// runtime statistics used by some load balance policies, they are
+	// accessed atomically and must be the first fields to guarantee the
+	// 64-bit alignment.
+	inFlight  int64
+	ewmaBits  uint64
+	ewmaStamp int64
+
 	URL            string   `json:"url" jsonschema:"required,format=url"`
 	Tags           []string `json:"tags" jsonschema:"omitempty,uniqueItems=true"`
 	Weight         int      `json:"weight" jsonschema:"omitempty,minimum=0,maximum=100"`
@@ -62,3 +81,57 @@ func (s *Server) checkAddrPattern() {
 
 	s.addrIsHostName = net.ParseIP(host) == nil
 }
+
+func (s *Server) incInFlight() {
+	atomic.AddInt64(&s.inFlight, 1)
+}
+
+func (s *Server) decInFlight() {
+	atomic.AddInt64(&s.inFlight, -1)
+}
+
+// inFlightRequests returns the number of in-flight requests of the server,
+// only requests dispatched by load balancers tracking them are counted.
+func (s *Server) inFlightRequests() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// latencyCost returns the cost of sending a request to the server, which
+// is the peak EWMA latency multiplied by the number of in-flight requests.
+func (s *Server) latencyCost() float64 {
+	ewma := math.Float64frombits(atomic.LoadUint64(&s.ewmaBits))
+	inFlight := s.inFlightRequests()
+	if ewma == 0 && inFlight > 0 {
+		return peakEWMAPenalty + float64(inFlight)
+	}
+	return ewma * float64(inFlight+1)
+}
+
+// observeLatency updates the peak EWMA latency of the server with a new
+// observation.
+func (s *Server) observeLatency(d time.Duration) {
+	now := time.Now().UnixNano()
+	rtt := float64(d)
+
+	for {
+		oldBits := atomic.LoadUint64(&s.ewmaBits)
+		ewma := math.Float64frombits(oldBits)
+
+		if rtt > ewma {
+			// be sensitive to peaks, use the new value directly.
+			ewma = rtt
+		} else {
+			elapsed := float64(now - atomic.LoadInt64(&s.ewmaStamp))
+			if elapsed < 0 {
+				elapsed = 0
+			}
+			w := math.Exp(-elapsed / float64(peakEWMADecay))
+			ewma = ewma*w + rtt*(1-w)
+		}
+
+		if atomic.CompareAndSwapUint64(&s.ewmaBits, oldBits, math.Float64bits(ewma)) {
+			atomic.StoreInt64(&s.ewmaStamp, now)
+			return
+		}
+	}
+}
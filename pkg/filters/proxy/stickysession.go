@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+)
+
+const (
+	// DefaultStickySessionCookieName is the default name of the sticky
+	// session cookie.
+	DefaultStickySessionCookieName = "EG_SESSION_STICKY"
+
+	// StickySessionFallbackRebalance chooses another server by the load
+	// balance policy when the sticky server is not available.
+	StickySessionFallbackRebalance = "rebalance"
+	// StickySessionFallbackReject rejects the request when the sticky
+	// server is not available.
+	StickySessionFallbackReject = "reject"
+)
+
+// StickySessionSpec is the spec of sticky session.
+type StickySessionSpec struct {
+	CookieName string `json:"cookieName" jsonschema:"omitempty"`
+	CookieTTL  string `json:"cookieTTL" jsonschema:"omitempty,format=duration"`
+	Fallback   string `json:"fallback" jsonschema:"omitempty,enum=,enum=rebalance,enum=reject"`
+}
+
+// Validate validates StickySessionSpec.
+func (spec *StickySessionSpec) Validate() error {
+	if spec.CookieTTL != "" {
+		d, err := time.ParseDuration(spec.CookieTTL)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("cookieTTL must not be negative")
+		}
+	}
+	return nil
+}
+
+// stickySessionLoadBalancer wraps a load balancer, it sends requests with
+// the sticky session cookie to the server identified by the cookie, and
+// sets the cookie in the response of other requests.
+type stickySessionLoadBalancer struct {
+	LoadBalancer
+	spec       *StickySessionSpec
+	cookieName string
+	cookieTTL  time.Duration
+	servers    map[string]*Server
+}
+
+func newStickySessionLoadBalancer(spec *StickySessionSpec, lb LoadBalancer, servers []*Server) *stickySessionLoadBalancer {
+	sslb := &stickySessionLoadBalancer{
+		LoadBalancer: lb,
+		spec:         spec,
+		cookieName:   spec.CookieName,
+		servers:      map[string]*Server{},
+	}
+
+	if sslb.cookieName == "" {
+		sslb.cookieName = DefaultStickySessionCookieName
+	}
+	if spec.CookieTTL != "" {
+		sslb.cookieTTL, _ = time.ParseDuration(spec.CookieTTL)
+	}
+
+	for _, svr := range servers {
+		sslb.servers[stickySessionServerID(svr)] = svr
+	}
+
+	return sslb
+}
+
+// stickySessionServerID returns the ID of the server used in the cookie,
+// it is a hash of the server URL to avoid exposing the URL to clients.
+func stickySessionServerID(svr *Server) string {
+	h := fnv.New64a()
+	h.Write([]byte(svr.URL))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// stickyServer returns the server identified by the cookie of the
+// request, ok is false if the request does not have the cookie.
+func (lb *stickySessionLoadBalancer) stickyServer(req *httpprot.Request) (svr *Server, ok bool) {
+	if req == nil {
+		return nil, false
+	}
+
+	c, err := req.Cookie(lb.cookieName)
+	if err != nil || c.Value == "" {
+		return nil, false
+	}
+
+	return lb.servers[c.Value], true
+}
+
+// ChooseServer implements the LoadBalancer interface.
+func (lb *stickySessionLoadBalancer) ChooseServer(req *httpprot.Request) *Server {
+	svr, ok := lb.stickyServer(req)
+	if svr != nil {
+		svr.incInFlight()
+		return svr
+	}
+
+	// the sticky server is not in the pool anymore.
+	if ok && lb.spec.Fallback == StickySessionFallbackReject {
+		return nil
+	}
+
+	return lb.LoadBalancer.ChooseServer(req)
+}
+
+// ReturnServer implements the LoadBalancer interface.
+func (lb *stickySessionLoadBalancer) ReturnServer(server *Server, req *httpprot.Request, resp *httpprot.Response) {
+	// the server is chosen by the cookie, the wrapped load balancer
+	// knows nothing about it.
+	if svr, _ := lb.stickyServer(req); svr == server {
+		server.decInFlight()
+		return
+	}
+
+	lb.LoadBalancer.ReturnServer(server, req, resp)
+	if resp == nil {
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     lb.cookieName,
+		Value:    stickySessionServerID(server),
+		Path:     "/",
+		HttpOnly: true,
+	}
+	if lb.cookieTTL > 0 {
+		cookie.Expires = time.Now().Add(lb.cookieTTL)
+		cookie.MaxAge = int(lb.cookieTTL / time.Second)
+	}
+	resp.SetCookie(cookie)
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickySessionSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &StickySessionSpec{}
+	assert.NoError(spec.Validate())
+
+	spec.CookieTTL = "-1h"
+	assert.Error(spec.Validate())
+
+	spec.CookieTTL = "1h"
+	assert.NoError(spec.Validate())
+}
+
+func TestStickySessionLoadBalancer(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &LoadBalanceSpec{
+		Policy: "roundRobin",
+		StickySession: &StickySessionSpec{
+			CookieName: "sticky",
+			CookieTTL:  "1h",
+		},
+	}
+	svrs := prepareRingHashServers(3, false)
+	lb := NewLoadBalancer(spec, svrs)
+
+	// the first request has no cookie, the server is chosen by the policy
+	// and the cookie is set in the response.
+	stdr, _ := http.NewRequest(http.MethodGet, "http://www.megaease.com/", nil)
+	req, _ := httpprot.NewRequest(stdr)
+	resp, _ := httpprot.NewResponse(nil)
+	svr := lb.ChooseServer(req)
+	assert.NotNil(svr)
+	lb.ReturnServer(svr, req, resp)
+
+	cookies := resp.Std().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal("sticky", cookies[0].Name)
+	assert.Equal(3600, cookies[0].MaxAge)
+
+	// requests with the cookie go to the same server.
+	stdr, _ = http.NewRequest(http.MethodGet, "http://www.megaease.com/", nil)
+	stdr.AddCookie(&http.Cookie{Name: "sticky", Value: cookies[0].Value})
+	req, _ = httpprot.NewRequest(stdr)
+	for i := 0; i < 10; i++ {
+		resp, _ := httpprot.NewResponse(nil)
+		s := lb.ChooseServer(req)
+		assert.Equal(svr, s)
+		assert.Equal(int64(1), s.inFlightRequests())
+		lb.ReturnServer(s, req, resp)
+		assert.Equal(int64(0), s.inFlightRequests())
+		assert.Empty(resp.Std().Cookies())
+	}
+
+	// the server disappears, rebalance by default.
+	var others []*Server
+	for _, s := range svrs {
+		if s != svr {
+			others = append(others, s)
+		}
+	}
+	lb = NewLoadBalancer(spec, others)
+	resp, _ = httpprot.NewResponse(nil)
+	s := lb.ChooseServer(req)
+	assert.NotNil(s)
+	assert.NotEqual(svr, s)
+	lb.ReturnServer(s, req, resp)
+	assert.Len(resp.Std().Cookies(), 1)
+	assert.NotEqual(cookies[0].Value, resp.Std().Cookies()[0].Value)
+
+	// reject if configured.
+	spec.StickySession.Fallback = StickySessionFallbackReject
+	lb = NewLoadBalancer(spec, others)
+	assert.Nil(lb.ChooseServer(req))
+}
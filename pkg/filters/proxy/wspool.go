@@ -137,7 +137,8 @@ func (sp *WebSocketServerPool) dialServer(svr *Server, req *httpprot.Request) (*
 
 func (sp *WebSocketServerPool) handle(ctx *context.Context) (result string) {
 	req := ctx.GetInputRequest().(*httpprot.Request)
-	svr := sp.LoadBalancer().ChooseServer(req)
+	lb := sp.LoadBalancer()
+	svr := lb.ChooseServer(req)
 
 	metric := &httpstat.Metric{}
 	startTime := fasttime.Now()
@@ -153,6 +154,7 @@ func (sp *WebSocketServerPool) handle(ctx *context.Context) (result string) {
 		metric.StatusCode = http.StatusServiceUnavailable
 		return resultInternalError
 	}
+	defer lb.ReturnServer(svr, req, nil)
 
 	stdw, _ := ctx.GetData("HTTP_RESPONSE_WRITER").(http.ResponseWriter)
 	if stdw == nil {
@@ -223,7 +225,8 @@ func (sp *WebSocketServerPool) handle(ctx *context.Context) (result string) {
 
 func (sp *WebSocketServerPool) status() *ServerPoolStatus {
 	return &ServerPoolStatus{
-		Stat: sp.httpStat.Status(),
+		Stat:         sp.httpStat.Status(),
+		ServerHealth: sp.healthStatus(),
 	}
 }
 
@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const (
+	// StoreEtcd shares token consumption through the embedded etcd.
+	StoreEtcd = "etcd"
+	// StoreRedis shares token consumption through a Redis compatible
+	// server.
+	StoreRedis = "redis"
+
+	defaultDistributedWindow       = time.Second
+	defaultDistributedSyncInterval = 100 * time.Millisecond
+	// every sync is a write to etcd, which is replicated to all primary
+	// members, so the etcd store syncs much less frequently by default.
+	defaultEtcdSyncInterval = time.Second
+)
+
+type (
+	// DistributedSpec is the spec to share token consumption across all
+	// members of the cluster, so that the limit applies to the whole
+	// cluster instead of every member.
+	DistributedSpec struct {
+		Store        string     `json:"store" jsonschema:"omitempty,enum=,enum=etcd,enum=redis"`
+		Window       string     `json:"window" jsonschema:"omitempty,format=duration"`
+		SyncInterval string     `json:"syncInterval" jsonschema:"omitempty,format=duration"`
+		Redis        *RedisSpec `json:"redis,omitempty" jsonschema:"omitempty"`
+	}
+
+	// RedisSpec is the spec of a Redis compatible server.
+	RedisSpec struct {
+		Addr     string `json:"addr" jsonschema:"required,format=hostport"`
+		Password string `json:"password" jsonschema:"omitempty"`
+		DB       int    `json:"db" jsonschema:"omitempty,minimum=0"`
+	}
+
+	// Store is the shared store of the token consumption of all members.
+	Store interface {
+		// Sync saves the usage of the member to the store, and returns
+		// the usages of all members, including the current one.
+		Sync(key, member, usage string, ttl time.Duration) (map[string]string, error)
+		Close()
+	}
+
+	// etcdStore implements Store with the embedded etcd, usages are
+	// saved under the lease of the member, so they are removed when the
+	// member leaves the cluster.
+	etcdStore struct {
+		cls    cluster.Cluster
+		prefix string
+	}
+
+	// clusterLimiter limits the token consumption of the whole cluster in
+	// a fixed window. Each member counts its local consumption, syncs it
+	// to the shared store periodically and learns the consumption of
+	// other members in return. When the store is unreachable, it falls
+	// back to a local approximation, that's, the limit divided by the
+	// number of members.
+	clusterLimiter struct {
+		key    string
+		member string
+		store  Store
+		window time.Duration
+		limit  int64
+
+		mu        sync.Mutex
+		windowIdx int64
+		local     int64
+		others    int64
+		synced    bool
+		members   int
+		healthy   bool
+	}
+
+	// ClusterStatus is the status of the cluster limiter.
+	ClusterStatus struct {
+		Healthy bool  `json:"healthy"`
+		Members int   `json:"members"`
+		Limit   int64 `json:"limit"`
+		Local   int64 `json:"local"`
+		Others  int64 `json:"others"`
+	}
+)
+
+// Validate validates DistributedSpec.
+func (spec *DistributedSpec) Validate() error {
+	if spec.Store == StoreRedis && spec.Redis == nil {
+		return fmt.Errorf("redis is required when store is redis")
+	}
+
+	window, interval := spec.durations()
+	if interval > window {
+		return fmt.Errorf("syncInterval must not be greater than window")
+	}
+
+	return nil
+}
+
+func (spec *DistributedSpec) durations() (window, interval time.Duration) {
+	window = defaultDistributedWindow
+	if spec.Window != "" {
+		if d, err := time.ParseDuration(spec.Window); err == nil && d > 0 {
+			window = d
+		}
+	}
+
+	if spec.SyncInterval != "" {
+		if d, err := time.ParseDuration(spec.SyncInterval); err == nil && d > 0 {
+			return window, d
+		}
+	}
+
+	interval = defaultDistributedSyncInterval
+	if spec.Store != StoreRedis {
+		interval = defaultEtcdSyncInterval
+	}
+	if interval > window {
+		interval = window
+	}
+	return
+}
+
+func newEtcdStore(cls cluster.Cluster, prefix string) *etcdStore {
+	return &etcdStore{cls: cls, prefix: prefix}
+}
+
+// Sync implements Store.
+func (s *etcdStore) Sync(key, member, usage string, ttl time.Duration) (map[string]string, error) {
+	prefix := s.prefix + key + "/"
+	if err := s.cls.PutUnderLease(prefix+member, usage); err != nil {
+		return nil, err
+	}
+
+	kvs, err := s.cls.GetPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(kvs))
+	for k, v := range kvs {
+		result[strings.TrimPrefix(k, prefix)] = v
+	}
+	return result, nil
+}
+
+// Close implements Store.
+func (s *etcdStore) Close() {
+}
+
+func newClusterLimiter(key, member string, store Store, window time.Duration, limit int64) *clusterLimiter {
+	return &clusterLimiter{
+		key:     key,
+		member:  member,
+		store:   store,
+		window:  window,
+		limit:   limit,
+		members: 1,
+		healthy: true,
+	}
+}
+
+// rollWindow moves to the window of now, the caller must hold the lock.
+func (cl *clusterLimiter) rollWindow(now time.Time) {
+	idx := now.UnixNano() / int64(cl.window)
+	if idx == cl.windowIdx {
+		return
+	}
+
+	cl.windowIdx = idx
+	cl.local = 0
+	cl.others = 0
+	cl.synced = false
+}
+
+// budget returns the max tokens this member could consume in the current
+// window, the caller must hold the lock.
+func (cl *clusterLimiter) budget() int64 {
+	// before the first sync of a window, or when the store is unreachable,
+	// the consumption of other members is unknown, use the local
+	// approximation.
+	if !cl.synced || !cl.healthy {
+		members := int64(cl.members)
+		if members < 1 {
+			members = 1
+		}
+		return cl.limit / members
+	}
+	return cl.limit - cl.others
+}
+
+// acquirePermission acquires a permission, it returns false if the
+// cluster wide limit is reached.
+func (cl *clusterLimiter) acquirePermission() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.rollWindow(time.Now())
+	if cl.local >= cl.budget() {
+		return false
+	}
+
+	cl.local++
+	return true
+}
+
+func formatUsage(windowIdx, count int64) string {
+	return strconv.FormatInt(windowIdx, 10) + ":" + strconv.FormatInt(count, 10)
+}
+
+func parseUsage(usage string) (windowIdx, count int64, err error) {
+	fields := strings.SplitN(usage, ":", 2)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid usage: %s", usage)
+	}
+	if windowIdx, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return
+	}
+	count, err = strconv.ParseInt(fields[1], 10, 64)
+	return
+}
+
+// sync syncs the local consumption to the shared store, and updates the
+// consumption of other members.
+func (cl *clusterLimiter) sync() {
+	cl.mu.Lock()
+	cl.rollWindow(time.Now())
+	windowIdx, local := cl.windowIdx, cl.local
+	cl.mu.Unlock()
+
+	usages, err := cl.store.Sync(cl.key, cl.member, formatUsage(windowIdx, local), 2*cl.window)
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if err != nil {
+		if cl.healthy {
+			logger.Warnf("rate limiter %s: failed to sync with the shared store, fallback to local approximation: %v", cl.key, err)
+		}
+		cl.healthy = false
+		return
+	}
+
+	if !cl.healthy {
+		logger.Infof("rate limiter %s: the shared store is recovered", cl.key)
+	}
+	cl.healthy = true
+	cl.members = len(usages)
+
+	// the window has changed during the sync.
+	if windowIdx != cl.windowIdx {
+		return
+	}
+
+	var others int64
+	for member, usage := range usages {
+		if member == cl.member {
+			continue
+		}
+		idx, count, err := parseUsage(usage)
+		if err != nil {
+			logger.Errorf("rate limiter %s: %v", cl.key, err)
+			continue
+		}
+		if idx == windowIdx {
+			others += count
+		}
+	}
+
+	cl.others = others
+	cl.synced = true
+}
+
+func (cl *clusterLimiter) status() *ClusterStatus {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return &ClusterStatus{
+		Healthy: cl.healthy,
+		Members: cl.members,
+		Limit:   cl.limit,
+		Local:   cl.local,
+		Others:  cl.others,
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimiter
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStore struct {
+	sync.Mutex
+	usages map[string]map[string]string
+	err    error
+}
+
+func (s *mockStore) Sync(key, member, usage string, ttl time.Duration) (map[string]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if s.usages[key] == nil {
+		s.usages[key] = map[string]string{}
+	}
+	s.usages[key][member] = usage
+
+	result := map[string]string{}
+	for k, v := range s.usages[key] {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (s *mockStore) Close() {
+}
+
+func TestDistributedSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &DistributedSpec{}
+	assert.NoError(spec.Validate())
+
+	spec.Store = StoreRedis
+	assert.Error(spec.Validate())
+
+	spec.Redis = &RedisSpec{Addr: "127.0.0.1:6379"}
+	assert.NoError(spec.Validate())
+
+	spec.Window = "100ms"
+	spec.SyncInterval = "1s"
+	assert.Error(spec.Validate())
+
+	// the etcd store syncs less frequently by default, but not longer
+	// than the window.
+	spec = &DistributedSpec{}
+	window, interval := spec.durations()
+	assert.Equal(defaultDistributedWindow, window)
+	assert.Equal(defaultEtcdSyncInterval, interval)
+	spec.Window = "500ms"
+	_, interval = spec.durations()
+	assert.Equal(500*time.Millisecond, interval)
+	spec = &DistributedSpec{Store: StoreRedis, Redis: &RedisSpec{Addr: "127.0.0.1:6379"}}
+	_, interval = spec.durations()
+	assert.Equal(defaultDistributedSyncInterval, interval)
+}
+
+func TestDistributedWindowValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := Spec{
+		Rule: Rule{
+			Policies:         []*Policy{{Name: "default", LimitForPeriod: 10, LimitRefreshPeriod: "1m"}},
+			DefaultPolicyRef: "default",
+		},
+	}
+	assert.NoError(spec.Validate())
+
+	spec.Distributed = &DistributedSpec{}
+	assert.Error(spec.Validate())
+
+	spec.Distributed.Window = "1m"
+	assert.NoError(spec.Validate())
+}
+
+func TestClusterLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	store := &mockStore{usages: map[string]map[string]string{}}
+	// a large window to make sure all operations are in the same window.
+	window := time.Hour
+
+	cl1 := newClusterLimiter("0", "member1", store, window, 100)
+	cl2 := newClusterLimiter("0", "member2", store, window, 100)
+	cl3 := newClusterLimiter("0", "member3", store, window, 100)
+	cl1.sync()
+	cl2.sync()
+	cl3.sync()
+
+	// 3 members now, members 2 and 3 consume 40 and 30 tokens.
+	for i := 0; i < 40; i++ {
+		assert.True(cl2.acquirePermission())
+	}
+	for i := 0; i < 30; i++ {
+		assert.True(cl3.acquirePermission())
+	}
+	cl2.sync()
+	cl3.sync()
+	cl1.sync()
+
+	// member 1 could only consume the remaining 30 tokens.
+	for i := 0; i < 30; i++ {
+		assert.True(cl1.acquirePermission())
+	}
+	assert.False(cl1.acquirePermission())
+
+	status := cl1.status()
+	assert.True(status.Healthy)
+	assert.Equal(3, status.Members)
+	assert.Equal(int64(70), status.Others)
+
+	// the store is unreachable, fallback to local approximation, which
+	// is 100/3 = 33 tokens.
+	store.err = fmt.Errorf("unreachable")
+	cl1.sync()
+	assert.False(cl1.status().Healthy)
+	for i := 0; i < 3; i++ {
+		assert.True(cl1.acquirePermission())
+	}
+	assert.False(cl1.acquirePermission())
+}
+
+func TestClusterLimiterWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	store := &mockStore{usages: map[string]map[string]string{}}
+	cl := newClusterLimiter("0", "member1", store, 50*time.Millisecond, 10)
+
+	for i := 0; i < 10; i++ {
+		assert.True(cl.acquirePermission())
+	}
+	assert.False(cl.acquirePermission())
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(cl.acquirePermission())
+}
+
+func TestClusterLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &URLRule{policy: &Policy{LimitForPeriod: 10, LimitRefreshPeriod: "100ms"}}
+	assert.Equal(int64(100), u.clusterLimit(time.Second))
+
+	u = &URLRule{policy: &Policy{LimitForPeriod: 10, LimitRefreshPeriod: "1m"}}
+	assert.Equal(int64(10), u.clusterLimit(time.Minute))
+	assert.Equal(int64(15), u.clusterLimit(90*time.Second))
+
+	// rounded up.
+	u = &URLRule{policy: &Policy{LimitForPeriod: 10, LimitRefreshPeriod: "300ms"}}
+	assert.Equal(int64(34), u.clusterLimit(time.Second))
+}
+
+func TestUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	idx, count, err := parseUsage(formatUsage(100, 20))
+	assert.NoError(err)
+	assert.Equal(int64(100), idx)
+	assert.Equal(int64(20), count)
+
+	_, _, err = parseUsage("100")
+	assert.Error(err)
+	_, _, err = parseUsage("a:20")
+	assert.Error(err)
+}
+
+func TestReadRESP(t *testing.T) {
+	assert := assert.New(t)
+
+	read := func(s string) (interface{}, error) {
+		return readRESP(bufio.NewReader(strings.NewReader(s)))
+	}
+
+	v, err := read("+OK\r\n")
+	assert.NoError(err)
+	assert.Equal("OK", v)
+
+	_, err = read("-ERR wrong\r\n")
+	assert.Error(err)
+
+	v, err = read(":12\r\n")
+	assert.NoError(err)
+	assert.Equal(int64(12), v)
+
+	v, err = read("$5\r\nhello\r\n")
+	assert.NoError(err)
+	assert.Equal("hello", v)
+
+	v, err = read("*4\r\n$2\r\nm1\r\n$4\r\n1:10\r\n$2\r\nm2\r\n$4\r\n1:20\r\n")
+	assert.NoError(err)
+	assert.Equal([]string{"m1", "1:10", "m2", "1:20"}, v)
+
+	_, err = read("*1\r\n:1\r\n")
+	assert.Error(err)
+
+	_, err = read("?\r\n")
+	assert.Error(err)
+}
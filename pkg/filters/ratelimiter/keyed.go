@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimiter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	librl "github.com/megaease/easegress/pkg/util/ratelimiter"
+)
+
+const (
+	// KeySourceRealIP uses the real IP of the client as the key.
+	KeySourceRealIP = "realIP"
+	// KeySourceHeader uses the value of a request header as the key.
+	KeySourceHeader = "header"
+	// KeySourceQuery uses the value of a query parameter as the key.
+	KeySourceQuery = "query"
+	// KeySourceData uses a value in the context data as the key, the
+	// value is set by previous filters, for example, validators.
+	KeySourceData = "data"
+
+	defaultMaxKeys = 10000
+	// maxStatusKeys is the max number of limiting keys in the status.
+	maxStatusKeys = 100
+)
+
+type (
+	// KeySpec is the spec to extract the key from a request, requests
+	// with different keys are limited separately.
+	KeySpec struct {
+		Source  string `json:"source" jsonschema:"required,enum=realIP,enum=header,enum=query,enum=data"`
+		Name    string `json:"name" jsonschema:"omitempty"`
+		MaxKeys int    `json:"maxKeys" jsonschema:"omitempty,minimum=1"`
+	}
+
+	// keyedLimiter maintains a rate limiter for each key, the least
+	// recently used ones are evicted when the number of keys exceeds the
+	// limit.
+	keyedLimiter struct {
+		spec   *KeySpec
+		policy *librl.Policy
+
+		mu    sync.Mutex
+		cache *lru.Cache
+	}
+
+	// KeysStatus is the status of the keyed limiter.
+	KeysStatus struct {
+		Tracked  int      `json:"tracked"`
+		Limiting []string `json:"limiting,omitempty"`
+	}
+)
+
+// Validate validates KeySpec.
+func (spec *KeySpec) Validate() error {
+	switch spec.Source {
+	case KeySourceRealIP:
+		return nil
+	case KeySourceHeader, KeySourceQuery, KeySourceData:
+		if spec.Name == "" {
+			return fmt.Errorf("name is required when source is %s", spec.Source)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown key source: %s", spec.Source)
+	}
+}
+
+// extract extracts the key from the request, it returns an empty string
+// if the request does not have the key.
+func (spec *KeySpec) extract(ctx *context.Context, req *httpprot.Request) string {
+	switch spec.Source {
+	case KeySourceRealIP:
+		return req.RealIP()
+	case KeySourceHeader:
+		return req.HTTPHeader().Get(spec.Name)
+	case KeySourceQuery:
+		return req.URL().Query().Get(spec.Name)
+	case KeySourceData:
+		switch v := ctx.GetData(spec.Name).(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func newKeyedLimiter(spec *KeySpec, policy *librl.Policy) *keyedLimiter {
+	size := spec.MaxKeys
+	if size <= 0 {
+		size = defaultMaxKeys
+	}
+
+	// error is impossible as size is positive.
+	cache, _ := lru.New(size)
+	return &keyedLimiter{
+		spec:   spec,
+		policy: policy,
+		cache:  cache,
+	}
+}
+
+// get returns the rate limiter of the key, it creates one if not exists.
+func (kl *keyedLimiter) get(key string) *librl.RateLimiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	if v, ok := kl.cache.Get(key); ok {
+		return v.(*librl.RateLimiter)
+	}
+
+	rl := librl.New(kl.policy)
+	kl.cache.Add(key, rl)
+	return rl
+}
+
+func (kl *keyedLimiter) status() *KeysStatus {
+	s := &KeysStatus{}
+
+	for _, k := range kl.cache.Keys() {
+		v, ok := kl.cache.Peek(k)
+		if !ok {
+			continue
+		}
+		s.Tracked++
+
+		if len(s.Limiting) >= maxStatusKeys {
+			continue
+		}
+		if v.(*librl.RateLimiter).State() == librl.StateLimiting {
+			s.Limiting = append(s.Limiting, k.(string))
+		}
+	}
+
+	sort.Strings(s.Limiting)
+	return s
+}
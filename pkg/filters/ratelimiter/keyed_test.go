@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimiter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	librl "github.com/megaease/easegress/pkg/util/ratelimiter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &KeySpec{Source: "realIP"}
+	assert.NoError(spec.Validate())
+
+	spec.Source = "header"
+	assert.Error(spec.Validate())
+
+	spec.Name = "X-User"
+	assert.NoError(spec.Validate())
+
+	spec.Source = "unknown"
+	assert.Error(spec.Validate())
+}
+
+func TestKeySpecExtract(t *testing.T) {
+	assert := assert.New(t)
+
+	stdr, _ := http.NewRequest(http.MethodGet, "http://www.megaease.com/?user=u1", nil)
+	stdr.Header.Set("X-User", "u2")
+	stdr.RemoteAddr = "192.168.1.1:8080"
+	req, _ := httpprot.NewRequest(stdr)
+
+	ctx := context.New(nil)
+	ctx.SetData("user", "u3")
+	ctx.SetData("uid", 4)
+
+	assert.Equal("192.168.1.1", (&KeySpec{Source: "realIP"}).extract(ctx, req))
+	assert.Equal("u1", (&KeySpec{Source: "query", Name: "user"}).extract(ctx, req))
+	assert.Equal("u2", (&KeySpec{Source: "header", Name: "X-User"}).extract(ctx, req))
+	assert.Equal("u3", (&KeySpec{Source: "data", Name: "user"}).extract(ctx, req))
+	assert.Equal("4", (&KeySpec{Source: "data", Name: "uid"}).extract(ctx, req))
+	assert.Equal("", (&KeySpec{Source: "data", Name: "unknown"}).extract(ctx, req))
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	// a long timeout to make sure requests exceed the limit are permitted
+	// with waiting, and the state of the rate limiter is limiting.
+	policy := librl.NewPolicy(time.Hour, time.Hour, 2)
+	kl := newKeyedLimiter(&KeySpec{Source: "realIP", MaxKeys: 2}, policy)
+
+	// each key has its own bucket.
+	for _, key := range []string{"k1", "k2"} {
+		for i := 0; i < 2; i++ {
+			permitted, d := kl.get(key).AcquirePermission()
+			assert.True(permitted)
+			assert.Equal(time.Duration(0), d)
+		}
+		permitted, d := kl.get(key).AcquirePermission()
+		assert.True(permitted)
+		assert.Greater(d, time.Duration(0))
+	}
+
+	s := kl.status()
+	assert.Equal(2, s.Tracked)
+	assert.Equal([]string{"k1", "k2"}, s.Limiting)
+
+	// k1 is the least recently used key and is evicted.
+	kl.get("k3")
+	s = kl.status()
+	assert.Equal(2, s.Tracked)
+	assert.Equal([]string{"k2"}, s.Limiting)
+
+	permitted, d := kl.get("k1").AcquirePermission()
+	assert.True(permitted)
+	assert.Equal(time.Duration(0), d)
+}
@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/megaease/easegress/pkg/context"
@@ -56,10 +58,11 @@ func init() {
 type (
 	// Policy defines the policy of a rate limiter
 	Policy struct {
-		Name               string `json:"name" jsonschema:"required"`
-		TimeoutDuration    string `json:"timeoutDuration" jsonschema:"omitempty,format=duration"`
-		LimitRefreshPeriod string `json:"limitRefreshPeriod" jsonschema:"omitempty,format=duration"`
-		LimitForPeriod     int    `json:"limitForPeriod" jsonschema:"omitempty,minimum=1"`
+		Name               string   `json:"name" jsonschema:"required"`
+		TimeoutDuration    string   `json:"timeoutDuration" jsonschema:"omitempty,format=duration"`
+		LimitRefreshPeriod string   `json:"limitRefreshPeriod" jsonschema:"omitempty,format=duration"`
+		LimitForPeriod     int      `json:"limitForPeriod" jsonschema:"omitempty,minimum=1"`
+		Key                *KeySpec `json:"key,omitempty" jsonschema:"omitempty"`
 	}
 
 	// URLRule defines the rate limiter rule for a URL pattern
@@ -67,6 +70,8 @@ type (
 		urlrule.URLRule `json:",inline"`
 		policy          *Policy
 		rl              *librl.RateLimiter
+		kl              *keyedLimiter
+		cl              *clusterLimiter
 	}
 
 	// Spec is the configuration of a rate limiter
@@ -77,14 +82,31 @@ type (
 
 	// Rule is the detailed config of RateLimiter.
 	Rule struct {
-		Policies         []*Policy  `json:"policies" jsonschema:"required"`
-		DefaultPolicyRef string     `json:"defaultPolicyRef" jsonschema:"omitempty"`
-		URLs             []*URLRule `json:"urls" jsonschema:"required"`
+		Policies         []*Policy        `json:"policies" jsonschema:"required"`
+		DefaultPolicyRef string           `json:"defaultPolicyRef" jsonschema:"omitempty"`
+		URLs             []*URLRule       `json:"urls" jsonschema:"required"`
+		Distributed      *DistributedSpec `json:"distributed,omitempty" jsonschema:"omitempty"`
 	}
 
 	// RateLimiter defines the rate limiter
 	RateLimiter struct {
 		spec *Spec
+
+		store Store
+		done  chan struct{}
+		wg    sync.WaitGroup
+	}
+
+	// Status is the status of RateLimiter.
+	Status struct {
+		URLs []*URLStatus `json:"urls,omitempty"`
+	}
+
+	// URLStatus is the status of a URL rule.
+	URLStatus struct {
+		ID      string         `json:"id"`
+		Keys    *KeysStatus    `json:"keys,omitempty"`
+		Cluster *ClusterStatus `json:"cluster,omitempty"`
 	}
 )
 
@@ -106,9 +128,45 @@ URLLoop:
 		return fmt.Errorf("policy '%s' is not defined", name)
 	}
 
+	for _, p := range spec.Policies {
+		if p.Key == nil {
+			continue
+		}
+		if err := p.Key.Validate(); err != nil {
+			return fmt.Errorf("policy '%s': key: %v", p.Name, err)
+		}
+		if spec.Distributed != nil {
+			return fmt.Errorf("policy '%s': key is not supported in distributed mode", p.Name)
+		}
+	}
+
+	if spec.Distributed != nil {
+		if err := spec.Distributed.Validate(); err != nil {
+			return fmt.Errorf("distributed: %v", err)
+		}
+
+		// the cluster wide limit of a window shorter than the refresh
+		// period is rounded up, which permits more requests than
+		// configured.
+		window, _ := spec.Distributed.durations()
+		for _, p := range spec.Policies {
+			if period := p.refreshPeriod(); window < period {
+				return fmt.Errorf("policy '%s': limitRefreshPeriod %v is longer than the window %v of distributed mode", p.Name, period, window)
+			}
+		}
+	}
+
 	return nil
 }
 
+// refreshPeriod returns the limit refresh period of the policy.
+func (p *Policy) refreshPeriod() time.Duration {
+	if d, err := time.ParseDuration(p.LimitRefreshPeriod); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Millisecond
+}
+
 func (url *URLRule) createRateLimiter() {
 	policy := librl.Policy{
 		LimitForPeriod: url.policy.LimitForPeriod,
@@ -131,6 +189,27 @@ func (url *URLRule) createRateLimiter() {
 	}
 
 	url.rl = librl.New(&policy)
+	if url.policy.Key != nil {
+		url.kl = newKeyedLimiter(url.policy.Key, &policy)
+	}
+}
+
+// clusterLimit returns the limit of the whole cluster in a window, it is
+// rounded up and is at least 1. The window must not be shorter than the
+// refresh period, which is ensured by Validate.
+func (url *URLRule) clusterLimit(window time.Duration) int64 {
+	limit := int64(url.policy.LimitForPeriod)
+	if limit == 0 {
+		limit = 50
+	}
+
+	period := url.policy.refreshPeriod()
+
+	n := (limit*int64(window) + int64(period) - 1) / int64(period)
+	if n < 1 {
+		n = 1
+	}
+	return n
 }
 
 // Name returns the name of the RateLimiter filter instance.
@@ -227,7 +306,9 @@ OuterLoop:
 			url.Init()
 			rl.bindPolicyToURL(url)
 			url.rl = prev.rl
+			url.kl = prev.kl
 			prev.rl = nil
+			prev.kl = nil
 			rl.setStateListenerForURL(url)
 			continue OuterLoop
 		}
@@ -238,11 +319,65 @@ OuterLoop:
 // Init initializes RateLimiter.
 func (rl *RateLimiter) Init() {
 	rl.reload(nil)
+	rl.initDistributed()
 }
 
 // Inherit inherits previous generation of RateLimiter.
 func (rl *RateLimiter) Inherit(previousGeneration filters.Filter) {
 	rl.reload(previousGeneration.(*RateLimiter))
+	rl.initDistributed()
+}
+
+// initDistributed creates the cluster limiters if the distributed mode is
+// enabled, consumptions of the previous generation are kept in the shared
+// store, so there's no need to inherit the cluster limiters.
+func (rl *RateLimiter) initDistributed() {
+	spec := rl.spec.Distributed
+	if spec == nil {
+		return
+	}
+
+	super := rl.spec.Super()
+	if super == nil || super.Cluster() == nil {
+		logger.Errorf("rate limiter %s: cluster is not available, distributed mode is disabled", rl.spec.Name())
+		return
+	}
+
+	cls := super.Cluster()
+	prefix := cls.Layout().RateLimiterPrefix(rl.spec.Pipeline(), rl.spec.Name())
+	if spec.Store == StoreRedis {
+		rl.store = newRedisStore(spec.Redis, prefix)
+	} else {
+		rl.store = newEtcdStore(cls, prefix)
+	}
+
+	window, interval := spec.durations()
+	member := super.Options().Name
+	for i, u := range rl.spec.URLs {
+		u.cl = newClusterLimiter(strconv.Itoa(i), member, rl.store, window, u.clusterLimit(window))
+	}
+
+	rl.done = make(chan struct{})
+	rl.wg.Add(1)
+	go rl.syncDistributed(interval)
+}
+
+func (rl *RateLimiter) syncDistributed(interval time.Duration) {
+	defer rl.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.done:
+			return
+		case <-ticker.C:
+			for _, u := range rl.spec.URLs {
+				u.cl.sync()
+			}
+		}
+	}
 }
 
 // Handle handles HTTP request
@@ -253,7 +388,18 @@ func (rl *RateLimiter) Handle(ctx *context.Context) string {
 			continue
 		}
 
-		permitted, d := u.rl.AcquirePermission()
+		// requests without the key share the rate limiter of the URL.
+		limiter := u.rl
+		if u.kl != nil {
+			if key := u.kl.spec.extract(ctx, req); key != "" {
+				limiter = u.kl.get(key)
+			}
+		}
+
+		permitted, d := limiter.AcquirePermission()
+		if permitted && u.cl != nil {
+			permitted = u.cl.acquirePermission()
+		}
 		if !permitted {
 			ctx.AddTag("rateLimiter: too many requests")
 
@@ -288,9 +434,33 @@ func (rl *RateLimiter) Handle(ctx *context.Context) string {
 
 // Status returns Status generated by Runtime.
 func (rl *RateLimiter) Status() interface{} {
-	return nil
+	s := &Status{}
+	for _, u := range rl.spec.URLs {
+		us := &URLStatus{ID: u.ID()}
+		if u.kl != nil {
+			us.Keys = u.kl.status()
+		}
+		if u.cl != nil {
+			us.Cluster = u.cl.status()
+		}
+		if us.Keys != nil || us.Cluster != nil {
+			s.URLs = append(s.URLs, us)
+		}
+	}
+
+	if len(s.URLs) == 0 {
+		return nil
+	}
+	return s
 }
 
 // Close closes RateLimiter.
 func (rl *RateLimiter) Close() {
+	if rl.done == nil {
+		return
+	}
+
+	close(rl.done)
+	rl.wg.Wait()
+	rl.store.Close()
 }
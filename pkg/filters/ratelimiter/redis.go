@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimiter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisTimeout = time.Second
+
+// redisStore implements Store with a Redis compatible server, usages of
+// a key are saved in a hash whose fields are member names. It only uses
+// a few commands of the RESP protocol, so a full featured client is not
+// required.
+type redisStore struct {
+	spec   *RedisSpec
+	prefix string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func newRedisStore(spec *RedisSpec, prefix string) *redisStore {
+	return &redisStore{spec: spec, prefix: prefix}
+}
+
+// Sync implements Store.
+func (s *redisStore) Sync(key, member, usage string, ttl time.Duration) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.sync(s.prefix+key, member, usage, ttl)
+	if err != nil {
+		// close the connection, a new one is created in the next call.
+		s.closeConn()
+	}
+	return result, err
+}
+
+func (s *redisStore) sync(key, member, usage string, ttl time.Duration) (map[string]string, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if _, err := s.do("HSET", key, member, usage); err != nil {
+		return nil, err
+	}
+	if _, err := s.do("PEXPIRE", key, ms); err != nil {
+		return nil, err
+	}
+
+	reply, err := s.do("HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, ok := reply.([]string)
+	if !ok || len(fields)%2 != 0 {
+		return nil, fmt.Errorf("unexpected reply of HGETALL: %v", reply)
+	}
+
+	result := make(map[string]string, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		result[fields[i]] = fields[i+1]
+	}
+	return result, nil
+}
+
+func (s *redisStore) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", s.spec.Addr, redisTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.rd = bufio.NewReader(conn)
+
+	if s.spec.Password != "" {
+		if _, err = s.do("AUTH", s.spec.Password); err != nil {
+			return err
+		}
+	}
+	if s.spec.DB != 0 {
+		if _, err = s.do("SELECT", strconv.Itoa(s.spec.DB)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *redisStore) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.rd = nil
+	}
+}
+
+// do sends a command and reads its reply, the reply is a string, an
+// int64 or a []string depending on the command.
+func (s *redisStore) do(args ...string) (interface{}, error) {
+	s.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(s.conn, sb.String()); err != nil {
+		return nil, err
+	}
+
+	return readRESP(s.rd)
+}
+
+func readRESPLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", fmt.Errorf("invalid RESP line: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// readRESP reads a reply of the RESP protocol, nested arrays are not
+// supported as they are not used.
+func readRESP(rd *bufio.Reader) (interface{}, error) {
+	line, err := readRESPLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty RESP line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis error: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		return readRESPBulk(rd, line)
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		result := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			line, err := readRESPLine(rd)
+			if err != nil {
+				return nil, err
+			}
+			if line == "" || line[0] != '$' {
+				return nil, fmt.Errorf("unsupported RESP array element: %q", line)
+			}
+			v, err := readRESPBulk(rd, line)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unsupported RESP reply: %q", line)
+}
+
+// readRESPBulk reads a bulk string, line is the header of the bulk string.
+func readRESPBulk(rd *bufio.Reader, line string) (string, error) {
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return "", err
+	}
+	if n < 0 {
+		return "", nil
+	}
+
+	buf := make([]byte, n+2)
+	if _, err = io.ReadFull(rd, buf); err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+// Close implements Store.
+func (s *redisStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConn()
+}
@@ -40,9 +40,9 @@ import (
 
 type (
 	// BasicAuthValidatorSpec defines the configuration of Basic Auth validator.
-	// There are 'file' and 'etcd' modes.
+	// There are 'file', 'etcd' and 'ldap' modes.
 	BasicAuthValidatorSpec struct {
-		Mode string `json:"mode" jsonschema:"omitempty,enum=FILE,enum=ETCD"`
+		Mode string `json:"mode" jsonschema:"omitempty,enum=FILE,enum=ETCD,enum=LDAP"`
 		// Required for 'FILE' mode.
 		// UserFile is path to file containing encrypted user credentials in apache2-utils/htpasswd format.
 		// To add user `userY`, use `sudo htpasswd /etc/apache2/.htpasswd userY`
@@ -58,6 +58,9 @@ type (
 		// Username and password are used for Basic Authentication. If "username" is empty, the value of "key"
 		// entry is used as username for Basic Auth.
 		EtcdPrefix string `json:"etcdPrefix" jsonschema:"omitempty"`
+		// Required for 'LDAP' mode.
+		// LDAP is the configuration to authenticate users with an LDAP server.
+		LDAP *LDAPSpec `json:"ldap,omitempty" jsonschema:"omitempty"`
 	}
 
 	// AuthorizedUsersCache provides cached lookup for authorized users.
@@ -325,6 +328,8 @@ func NewBasicAuthValidator(spec *BasicAuthValidatorSpec, supervisor *supervisor.
 		cache = newEtcdUserCache(supervisor.Cluster(), spec.EtcdPrefix)
 	case "FILE":
 		cache = newHtpasswdUserCache(spec.UserFile, 1*time.Minute)
+	case "LDAP":
+		cache = newLDAPUserCache(spec.LDAP)
 	default:
 		logger.Errorf("BasicAuth validator spec unvalid.")
 		return nil
@@ -337,6 +342,14 @@ func NewBasicAuthValidator(spec *BasicAuthValidatorSpec, supervisor *supervisor.
 	return bav
 }
 
+// Validate validates the BasicAuthValidatorSpec.
+func (spec BasicAuthValidatorSpec) Validate() error {
+	if spec.Mode == "LDAP" && spec.LDAP == nil {
+		return fmt.Errorf("ldap is required for LDAP mode")
+	}
+	return nil
+}
+
 func parseBasicAuthorizationHeader(hdr *httpheader.HTTPHeader) (string, error) {
 	const prefix = "Basic "
 
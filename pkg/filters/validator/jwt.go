@@ -22,50 +22,315 @@ import (
 	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/MicahParks/keyfunc"
 	"github.com/golang-jwt/jwt/v4"
 
+	"github.com/megaease/easegress/pkg/logger"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/util/codectool"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	jwksRetryInterval          = time.Minute
+	oidcDiscoveryPath          = "/.well-known/openid-configuration"
 )
 
 // JWTValidatorSpec defines the configuration of JWT validator
 type JWTValidatorSpec struct {
-	Algorithm string `json:"algorithm" jsonschema:"enum=HS256,enum=HS384,enum=HS512,enum=RS256,enum=RS384,enum=RS512,enum=ES256,enum=ES384,enum=ES512,enum=EdDSA"`
+	Algorithm string `json:"algorithm" jsonschema:"omitempty,enum=,enum=HS256,enum=HS384,enum=HS512,enum=RS256,enum=RS384,enum=RS512,enum=ES256,enum=ES384,enum=ES512,enum=EdDSA"`
 	//PublicKey is in hex encoding
 	PublicKey string `json:"publicKey" jsonschema:"pattern=^$|^[A-Fa-f0-9]+$"`
 	// Secret is in hex encoding
 	Secret string `json:"secret" jsonschema:"pattern=^$|^[A-Fa-f0-9]+$"`
+	// JWKSURL is the URL of a JSON Web Key Set, the key to verify a token
+	// is selected from the set by the 'kid' header of the token.
+	JWKSURL string `json:"jwksURL,omitempty" jsonschema:"omitempty,format=url"`
+	// JWKSRefreshInterval is the interval to refresh the JSON Web Key Set.
+	JWKSRefreshInterval string `json:"jwksRefreshInterval,omitempty" jsonschema:"omitempty,format=duration"`
+	// Issuer is the expected value of the 'iss' claim. If none of PublicKey,
+	// Secret and JWKSURL is specified, the JWKS URL is discovered from the
+	// OpenID Provider configuration of the issuer.
+	Issuer string `json:"issuer,omitempty" jsonschema:"omitempty"`
+	// Audiences are the accepted values of the 'aud' claim, a token must
+	// be issued to at least one of them.
+	Audiences []string `json:"audiences,omitempty" jsonschema:"omitempty"`
+	// ClockSkew is the tolerance of the clock skew when validating the
+	// 'exp', 'nbf' and 'iat' claims.
+	ClockSkew string `json:"clockSkew,omitempty" jsonschema:"omitempty,format=duration"`
+	// Rules are the claim rules to authorize requests, a request needs to
+	// pass all of the rules matching it.
+	Rules []*JWTClaimRule `json:"rules,omitempty" jsonschema:"omitempty"`
+	// ForwardClaims are the claims forwarded to the request headers or
+	// the context data, for later filters.
+	ForwardClaims []*JWTClaimForward `json:"forwardClaims,omitempty" jsonschema:"omitempty"`
 	// CookieName specifies the name of a cookie, if not empty, and the cookie with
 	// this name both exists and has a non-empty value, its value is used as token
 	// string, the Authorization header is used to get the token string otherwise.
 	CookieName string `json:"cookieName" jsonschema:"omitempty"`
 }
 
+// Validate validates the JWTValidatorSpec.
+func (spec JWTValidatorSpec) Validate() error {
+	n := 0
+	for _, s := range []string{spec.PublicKey, spec.Secret, spec.JWKSURL} {
+		if s != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("only one of publicKey, secret and jwksURL can be specified")
+	}
+
+	if n == 0 && spec.Issuer == "" {
+		return fmt.Errorf("one of publicKey, secret, jwksURL and issuer must be specified")
+	}
+
+	if spec.usesJWKS() {
+		return nil
+	}
+
+	if spec.Algorithm == "" {
+		return fmt.Errorf("algorithm is required for publicKey or secret")
+	}
+
+	if spec.PublicKey != "" {
+		if strings.HasPrefix(spec.Algorithm, "HS") {
+			return fmt.Errorf("publicKey can not be used with algorithm %s", spec.Algorithm)
+		}
+		publicKeyBytes, _ := hex.DecodeString(spec.PublicKey)
+		p, _ := pem.Decode(publicKeyBytes)
+		if p == nil {
+			return fmt.Errorf("invalid publicKey: not in PEM format")
+		}
+		if _, err := x509.ParsePKIXPublicKey(p.Bytes); err != nil {
+			return fmt.Errorf("invalid publicKey: %v", err)
+		}
+	} else if !strings.HasPrefix(spec.Algorithm, "HS") {
+		return fmt.Errorf("secret can only be used with algorithm HS256, HS384 or HS512")
+	}
+
+	return nil
+}
+
+// usesJWKS returns whether keys are from a JSON Web Key Set.
+func (spec *JWTValidatorSpec) usesJWKS() bool {
+	return spec.PublicKey == "" && spec.Secret == ""
+}
+
 // NewJWTValidator creates a new JWT validator
 func NewJWTValidator(spec *JWTValidatorSpec) *JWTValidator {
-	var key interface{}
+	v := &JWTValidator{spec: spec}
+
+	if spec.ClockSkew != "" {
+		v.clockSkew, _ = time.ParseDuration(spec.ClockSkew)
+	}
+
+	for _, r := range spec.Rules {
+		r.init()
+	}
+
+	if spec.usesJWKS() {
+		v.initJWKS()
+		return v
+	}
+
 	if len(spec.PublicKey) > 0 {
 		publicKeyBytes, _ := hex.DecodeString(spec.PublicKey)
 		p, _ := pem.Decode(publicKeyBytes)
-		key, _ = x509.ParsePKIXPublicKey(p.Bytes)
+		v.key, _ = x509.ParsePKIXPublicKey(p.Bytes)
 	} else {
-		key, _ = hex.DecodeString(spec.Secret)
-	}
-	return &JWTValidator{
-		spec: spec,
-		key:  key,
+		v.key, _ = hex.DecodeString(spec.Secret)
 	}
+	return v
 }
 
 // JWTValidator defines the JWT validator
 type JWTValidator struct {
-	spec *JWTValidatorSpec
-	key  interface{}
+	spec      *JWTValidatorSpec
+	key       interface{}
+	clockSkew time.Duration
+
+	// jwks is a *keyfunc.JWKS, it is loaded in background, and is nil
+	// before loaded.
+	jwks       atomic.Value
+	jwksURL    string
+	jwksClient *http.Client
+	// jwksLock protects closed, it makes sure the JWKS loaded after the
+	// validator is closed is released.
+	jwksLock sync.Mutex
+	closed   bool
+	done     chan struct{}
+}
+
+// openIDConfig is the part of the OpenID Provider configuration used by
+// the JWT validator.
+type openIDConfig struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// discoverJWKSURL fetches the OpenID Provider configuration of the issuer
+// and returns the JWKS URL in it.
+//
+// Reference: https://openid.net/specs/openid-connect-discovery-1_0.html
+func (v *JWTValidator) discoverJWKSURL() (string, error) {
+	url := strings.TrimSuffix(v.spec.Issuer, "/") + oidcDiscoveryPath
+	resp, err := v.jwksClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code of %s: %d", url, resp.StatusCode)
+	}
+
+	var cfg openIDConfig
+	if err = codectool.DecodeJSON(resp.Body, &cfg); err != nil {
+		return "", fmt.Errorf("failed to decode OpenID configuration: %v", err)
+	}
+
+	if cfg.Issuer != v.spec.Issuer {
+		return "", fmt.Errorf("issuer mismatch, expected %s, got %s", v.spec.Issuer, cfg.Issuer)
+	}
+	if cfg.JWKSURI == "" {
+		return "", fmt.Errorf("jwks_uri is not in the OpenID configuration")
+	}
+
+	return cfg.JWKSURI, nil
+}
+
+// initJWKS starts to load the JSON Web Key Set in background, so that an
+// unreachable identity provider does not block the creation of the
+// validator.
+func (v *JWTValidator) initJWKS() {
+	v.jwksClient = &http.Client{Timeout: 10 * time.Second}
+	v.jwksURL = v.spec.JWKSURL
+	v.done = make(chan struct{})
+	go v.loadJWKS()
+}
+
+// loadJWKS discovers the JWKS URL if required, fetches the JSON Web Key
+// Set and starts to refresh it periodically. It retries every
+// jwksRetryInterval until succeeded or the validator is closed.
+func (v *JWTValidator) loadJWKS() {
+	interval := defaultJWKSRefreshInterval
+	if v.spec.JWKSRefreshInterval != "" {
+		interval, _ = time.ParseDuration(v.spec.JWKSRefreshInterval)
+	}
+
+	for {
+		jwks, err := v.fetchJWKS(interval)
+		if err == nil {
+			v.jwksLock.Lock()
+			defer v.jwksLock.Unlock()
+			if v.closed {
+				jwks.EndBackground()
+			} else {
+				v.jwks.Store(jwks)
+			}
+			return
+		}
+		logger.Errorf("%v", err)
+
+		select {
+		case <-v.done:
+			return
+		case <-time.After(jwksRetryInterval):
+		}
+	}
+}
+
+// fetchJWKS fetches the JSON Web Key Set.
+func (v *JWTValidator) fetchJWKS(interval time.Duration) (*keyfunc.JWKS, error) {
+	if v.jwksURL == "" {
+		url, err := v.discoverJWKSURL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover JWKS URL of issuer %s: %v", v.spec.Issuer, err)
+		}
+		v.jwksURL = url
+	}
+
+	jwks, err := keyfunc.Get(v.jwksURL, keyfunc.Options{
+		Client:            v.jwksClient,
+		RefreshInterval:   interval,
+		RefreshRateLimit:  jwksRetryInterval,
+		RefreshTimeout:    10 * time.Second,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			logger.Errorf("failed to refresh JWKS from %s: %v", v.jwksURL, err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get JWKS from %s: %v", v.jwksURL, err)
+	}
+	return jwks, nil
+}
+
+// getJWKS returns the JSON Web Key Set, or nil if it is not loaded yet.
+func (v *JWTValidator) getJWKS() *keyfunc.JWKS {
+	jwks, _ := v.jwks.Load().(*keyfunc.JWKS)
+	return jwks
+}
+
+// keyFunc returns the key to verify the token.
+func (v *JWTValidator) keyFunc(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	if v.spec.Algorithm != "" && alg != v.spec.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", alg)
+	}
+
+	if !v.spec.usesJWKS() {
+		return v.key, nil
+	}
+
+	jwks := v.getJWKS()
+	if jwks == nil {
+		return nil, fmt.Errorf("JWKS is not loaded yet")
+	}
+	return jwks.Keyfunc(token)
+}
+
+// validateClaims validates the registered claims of the token.
+func (v *JWTValidator) validateClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+
+	if !claims.VerifyExpiresAt(now.Add(-v.clockSkew).Unix(), false) {
+		return fmt.Errorf("token is expired")
+	}
+	if !claims.VerifyNotBefore(now.Add(v.clockSkew).Unix(), false) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if !claims.VerifyIssuedAt(now.Add(v.clockSkew).Unix(), false) {
+		return fmt.Errorf("token used before issued")
+	}
+
+	if v.spec.Issuer != "" && !claims.VerifyIssuer(v.spec.Issuer, true) {
+		return fmt.Errorf("invalid issuer")
+	}
+
+	if len(v.spec.Audiences) > 0 {
+		for _, aud := range v.spec.Audiences {
+			if claims.VerifyAudience(aud, true) {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid audience")
+	}
+
+	return nil
 }
 
-// Validate validates the JWT token of a http request
-func (v *JWTValidator) Validate(req *httpprot.Request) error {
+// Validate validates the JWT token of a http request, and returns the
+// claims of the token if it is valid.
+func (v *JWTValidator) Validate(req *httpprot.Request) (jwt.MapClaims, error) {
 	var token string
 
 	if v.spec.CookieName != "" {
@@ -78,22 +343,44 @@ func (v *JWTValidator) Validate(req *httpprot.Request) error {
 		const prefix = "Bearer "
 		authHdr := req.HTTPHeader().Get("Authorization")
 		if !strings.HasPrefix(authHdr, prefix) {
-			return fmt.Errorf("unexpected authorization header: %s", authHdr)
+			return nil, fmt.Errorf("unexpected authorization header: %s", authHdr)
 		}
 		token = authHdr[len(prefix):]
 	}
-	// jwt.Parse does everything including parsing and verification
-	t, e := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if alg := token.Method.Alg(); alg != v.spec.Algorithm {
-			return nil, fmt.Errorf("unexpected signing method: %v", alg)
-		}
-		return v.key, nil
-	})
+
+	// claims are validated by validateClaims to support clock skew.
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	t, e := parser.Parse(token, v.keyFunc)
 	if e != nil {
-		return e
+		return nil, e
 	}
 	if !t.Valid {
-		return fmt.Errorf("invalid jwt token")
+		return nil, fmt.Errorf("invalid jwt token")
+	}
+
+	claims := t.Claims.(jwt.MapClaims)
+	if e = v.validateClaims(claims); e != nil {
+		return nil, e
+	}
+	return claims, nil
+}
+
+// Close closes the JWT validator, it stops the loading and refreshing of
+// the JSON Web Key Set.
+func (v *JWTValidator) Close() {
+	if !v.spec.usesJWKS() {
+		return
+	}
+
+	v.jwksLock.Lock()
+	defer v.jwksLock.Unlock()
+
+	if v.closed {
+		return
+	}
+	v.closed = true
+	close(v.done)
+	if jwks := v.getJWKS(); jwks != nil {
+		jwks.EndBackground()
 	}
-	return nil
 }
@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/util/stringtool"
+)
+
+type (
+	// JWTClaimRule defines the claims required by requests matching the
+	// path and methods of the rule.
+	JWTClaimRule struct {
+		// Path, PathPrefix and PathRegexp are the path patterns of the rule,
+		// the rule applies to all paths if none of them is specified.
+		Path       string `json:"path,omitempty" jsonschema:"omitempty,pattern=^/"`
+		PathPrefix string `json:"pathPrefix,omitempty" jsonschema:"omitempty,pattern=^/"`
+		PathRegexp string `json:"pathRegexp,omitempty" jsonschema:"omitempty,format=regexp"`
+		// Methods are the HTTP methods of the rule, the rule applies to all
+		// methods if it is empty.
+		Methods []string `json:"methods,omitempty" jsonschema:"omitempty,uniqueItems=true,format=httpmethod-array"`
+		// Claims are the required claims, a request needs to pass all of
+		// them.
+		Claims []*JWTClaimRequirement `json:"claims" jsonschema:"required"`
+
+		pathRE *regexp.Regexp
+	}
+
+	// JWTClaimRequirement defines the requirement of a claim. The value of
+	// the claim is treated as a list of strings: a string value is split by
+	// spaces, as the OAuth/2 'scope' claim, and an array value is converted
+	// element by element.
+	JWTClaimRequirement struct {
+		// Name is the name of the claim, nested claims could be referenced
+		// by dot separated names, e.g. 'realm_access.roles'.
+		Name string `json:"name" jsonschema:"required"`
+		// Contains are the values that the claim must contain all of.
+		Contains []string `json:"contains,omitempty" jsonschema:"omitempty"`
+		// ContainsAny are the values that the claim must contain at least
+		// one of.
+		ContainsAny []string `json:"containsAny,omitempty" jsonschema:"omitempty"`
+	}
+
+	// JWTClaimForward defines how to forward a claim of a validated token.
+	JWTClaimForward struct {
+		// Claim is the name of the claim, same as JWTClaimRequirement.Name.
+		Claim string `json:"claim" jsonschema:"required"`
+		// Header is the name of the request header to set the claim value
+		// to, the header is always removed from the request first to
+		// prevent it from being forged by clients.
+		Header string `json:"header,omitempty" jsonschema:"omitempty"`
+		// DataKey is the key of the context data to store the claim value,
+		// the value is stored as is, e.g. an array claim is stored as an
+		// array.
+		DataKey string `json:"dataKey,omitempty" jsonschema:"omitempty"`
+	}
+)
+
+// Validate validates the JWTClaimRule.
+func (r JWTClaimRule) Validate() error {
+	if len(r.Claims) == 0 {
+		return fmt.Errorf("claims of a rule can not be empty")
+	}
+	for _, c := range r.Claims {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate validates the JWTClaimRequirement.
+func (r JWTClaimRequirement) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name of claim is required")
+	}
+	if len(r.Contains) == 0 && len(r.ContainsAny) == 0 {
+		return fmt.Errorf("claim %s: one of contains and containsAny must be specified", r.Name)
+	}
+	return nil
+}
+
+// Validate validates the JWTClaimForward.
+func (f JWTClaimForward) Validate() error {
+	if f.Header == "" && f.DataKey == "" {
+		return fmt.Errorf("claim %s: one of header and dataKey must be specified", f.Claim)
+	}
+	return nil
+}
+
+func (r *JWTClaimRule) init() {
+	if r.PathRegexp != "" {
+		r.pathRE = regexp.MustCompile(r.PathRegexp)
+	}
+}
+
+// Match returns whether the rule applies to the request.
+func (r *JWTClaimRule) Match(req *httpprot.Request) bool {
+	if len(r.Methods) > 0 && !stringtool.StrInSlice(req.Method(), r.Methods) {
+		return false
+	}
+
+	if r.Path == "" && r.PathPrefix == "" && r.pathRE == nil {
+		return true
+	}
+
+	path := req.Path()
+	if r.Path != "" && r.Path == path {
+		return true
+	}
+	if r.PathPrefix != "" && strings.HasPrefix(path, r.PathPrefix) {
+		return true
+	}
+	return r.pathRE != nil && r.pathRE.MatchString(path)
+}
+
+// lookupClaim returns the value of a claim, the claim is looked up by the
+// full name first, and then as a dot separated path of nested claims.
+func lookupClaim(claims jwt.MapClaims, name string) (interface{}, bool) {
+	if v, ok := claims[name]; ok {
+		return v, true
+	}
+
+	var v interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// claimToString converts a scalar claim value to string.
+func claimToString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// claimToList converts a claim value to a list of strings.
+func claimToList(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			list = append(list, claimToString(e))
+		}
+		return list
+	default:
+		return []string{claimToString(v)}
+	}
+}
+
+// claimToHeader converts a claim value to a header value, elements of an
+// array value are separated by commas.
+func claimToHeader(v interface{}) string {
+	if a, ok := v.([]interface{}); ok {
+		return strings.Join(claimToList(a), ",")
+	}
+	return claimToString(v)
+}
+
+// Check checks whether the claims meet the requirement.
+func (r *JWTClaimRequirement) Check(claims jwt.MapClaims) error {
+	v, ok := lookupClaim(claims, r.Name)
+	if !ok {
+		return fmt.Errorf("claim %s is missing", r.Name)
+	}
+
+	values := claimToList(v)
+	for _, c := range r.Contains {
+		if !stringtool.StrInSlice(c, values) {
+			return fmt.Errorf("claim %s does not contain %s", r.Name, c)
+		}
+	}
+
+	if len(r.ContainsAny) == 0 {
+		return nil
+	}
+	for _, c := range r.ContainsAny {
+		if stringtool.StrInSlice(c, values) {
+			return nil
+		}
+	}
+	return fmt.Errorf("claim %s does not contain any of %s", r.Name, strings.Join(r.ContainsAny, ","))
+}
+
+// Authorize checks the claims of a validated token against the rules
+// matching the request, the request needs to pass all of them.
+func (v *JWTValidator) Authorize(req *httpprot.Request, claims jwt.MapClaims) error {
+	for _, rule := range v.spec.Rules {
+		if !rule.Match(req) {
+			continue
+		}
+		for _, c := range rule.Claims {
+			if err := c.Check(claims); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForwardClaims forwards the claims of a validated token to the request
+// headers and the context data.
+func (v *JWTValidator) ForwardClaims(ctx *context.Context, req *httpprot.Request, claims jwt.MapClaims) {
+	for _, f := range v.spec.ForwardClaims {
+		val, ok := lookupClaim(claims, f.Claim)
+
+		if f.Header != "" {
+			req.HTTPHeader().Del(f.Header)
+			if ok {
+				req.HTTPHeader().Set(f.Header, claimToHeader(val))
+			}
+		}
+
+		if f.DataKey != "" && ok {
+			ctx.SetData(f.DataKey, val)
+		}
+	}
+}
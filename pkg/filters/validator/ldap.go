@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validator
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	cache "github.com/patrickmn/go-cache"
+
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const (
+	defaultLDAPUserFilter     = "(uid=%s)"
+	defaultLDAPGroupAttribute = "memberOf"
+	defaultLDAPCacheTTL       = time.Minute
+	ldapTimeout               = 10 * time.Second
+)
+
+type (
+	// LDAPSpec defines the configuration of the LDAP mode of Basic Auth
+	// validator. A user is authenticated by: binding with BindDN, searching
+	// the user entry with UserFilter, and then binding with the DN of the
+	// user entry and the password from the request.
+	LDAPSpec struct {
+		// URL is the URL of the LDAP server, e.g. 'ldap://ldap.example.com:389'
+		// or 'ldaps://ldap.example.com:636'.
+		URL string `json:"url" jsonschema:"required,format=url"`
+		// StartTLS upgrades the connection of an 'ldap://' URL to TLS.
+		StartTLS bool `json:"startTLS,omitempty" jsonschema:"omitempty"`
+		// InsecureTLS skips the verification of the server certificate.
+		InsecureTLS bool `json:"insecureTLS,omitempty" jsonschema:"omitempty"`
+		// BindDN and BindPassword are the credentials to search users,
+		// anonymous search is used if BindDN is empty.
+		BindDN       string `json:"bindDN,omitempty" jsonschema:"omitempty"`
+		BindPassword string `json:"bindPassword,omitempty" jsonschema:"omitempty"`
+		// BaseDN is the search base of users.
+		BaseDN string `json:"baseDN" jsonschema:"required"`
+		// UserFilter is the filter to search a user, '%s' is replaced by
+		// the escaped username, default is '(uid=%s)'. For Active Directory,
+		// it is usually '(sAMAccountName=%s)'.
+		UserFilter string `json:"userFilter,omitempty" jsonschema:"omitempty"`
+		// Groups are DNs of groups, if not empty, a user must be a member of
+		// at least one of them.
+		Groups []string `json:"groups,omitempty" jsonschema:"omitempty"`
+		// GroupAttribute is the attribute of the user entry which lists
+		// the groups of the user, default is 'memberOf'.
+		GroupAttribute string `json:"groupAttribute,omitempty" jsonschema:"omitempty"`
+		// CacheTTL is the time to live of successful authentications in
+		// the cache, default is 1m, failed authentications are not cached.
+		CacheTTL string `json:"cacheTTL,omitempty" jsonschema:"omitempty,format=duration"`
+	}
+
+	ldapUserCache struct {
+		spec      *LDAPSpec
+		tlsConfig *tls.Config
+		cache     *cache.Cache
+	}
+)
+
+// Validate validates the LDAPSpec.
+func (spec LDAPSpec) Validate() error {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if spec.StartTLS {
+			return fmt.Errorf("startTLS can not be used with ldaps")
+		}
+	default:
+		return fmt.Errorf("scheme of url must be ldap or ldaps")
+	}
+
+	if spec.UserFilter != "" && strings.Count(spec.UserFilter, "%s") != 1 {
+		return fmt.Errorf("userFilter must contain exactly one %%s")
+	}
+
+	return nil
+}
+
+func newLDAPUserCache(spec *LDAPSpec) *ldapUserCache {
+	ttl := defaultLDAPCacheTTL
+	if spec.CacheTTL != "" {
+		ttl, _ = time.ParseDuration(spec.CacheTTL)
+	}
+
+	serverName := ""
+	if u, err := url.Parse(spec.URL); err == nil {
+		serverName = u.Hostname()
+	}
+
+	return &ldapUserCache{
+		spec: spec,
+		tlsConfig: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: spec.InsecureTLS,
+		},
+		cache: cache.New(ttl, 2*ttl),
+	}
+}
+
+func (luc *ldapUserCache) dial() (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	conn, err := ldap.DialURL(luc.spec.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(luc.tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if luc.spec.StartTLS {
+		if err = conn.StartTLS(luc.tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// authenticate authenticates the user with the LDAP server.
+func (luc *ldapUserCache) authenticate(username, password string) error {
+	conn, err := luc.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to LDAP server: %v", err)
+	}
+	defer conn.Close()
+
+	if luc.spec.BindDN != "" {
+		if err = conn.Bind(luc.spec.BindDN, luc.spec.BindPassword); err != nil {
+			return fmt.Errorf("failed to bind with bindDN: %v", err)
+		}
+	}
+
+	filter := luc.spec.UserFilter
+	if filter == "" {
+		filter = defaultLDAPUserFilter
+	}
+	filter = fmt.Sprintf(filter, ldap.EscapeFilter(username))
+
+	groupAttr := luc.spec.GroupAttribute
+	if groupAttr == "" {
+		groupAttr = defaultLDAPGroupAttribute
+	}
+
+	// "1.1" means no attributes are requested.
+	attrs := []string{"1.1"}
+	if len(luc.spec.Groups) > 0 {
+		attrs = []string{groupAttr}
+	}
+
+	req := ldap.NewSearchRequest(luc.spec.BaseDN, ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases, 2, int(ldapTimeout/time.Second), false,
+		filter, attrs, nil)
+	result, err := conn.Search(req)
+	if err != nil {
+		return fmt.Errorf("failed to search user %s: %v", username, err)
+	}
+	if len(result.Entries) != 1 {
+		return fmt.Errorf("user %s not found or not unique", username)
+	}
+
+	entry := result.Entries[0]
+	if err = conn.Bind(entry.DN, password); err != nil {
+		return fmt.Errorf("failed to bind as user %s: %v", username, err)
+	}
+
+	if len(luc.spec.Groups) == 0 {
+		return nil
+	}
+	for _, g := range entry.GetAttributeValues(groupAttr) {
+		for _, expected := range luc.spec.Groups {
+			if strings.EqualFold(g, expected) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("user %s is not a member of the required groups", username)
+}
+
+func (luc *ldapUserCache) cacheKey(username, password string) string {
+	sum := sha256.Sum256([]byte(username + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (luc *ldapUserCache) Match(username string, password string) bool {
+	// an empty password means an unauthenticated bind in LDAP, which
+	// always succeeds.
+	if username == "" || password == "" {
+		return false
+	}
+
+	key := luc.cacheKey(username, password)
+	if _, ok := luc.cache.Get(key); ok {
+		return true
+	}
+
+	if err := luc.authenticate(username, password); err != nil {
+		logger.Debugf("LDAP authentication failed: %v", err)
+		return false
+	}
+
+	luc.cache.SetDefault(key, struct{}{})
+	return true
+}
+
+// WatchChanges does nothing as users are always authenticated by the LDAP
+// server, except for the cached ones.
+func (luc *ldapUserCache) WatchChanges() {
+}
+
+func (luc *ldapUserCache) Close() {
+	luc.cache.Flush()
+}
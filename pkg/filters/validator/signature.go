@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package validator
+
+import (
+	stdcontext "context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/megaease/easegress/pkg/cluster/customdata"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/signer"
+)
+
+type (
+	// SignatureValidatorSpec defines the configuration of the signature
+	// validator, it is derived from signer.Spec.
+	SignatureValidatorSpec struct {
+		signer.Spec `json:",inline"`
+		// AccessKeyCustomDataKind is the kind of custom data storing access
+		// keys, the ID of a custom data item is the access key id, and its
+		// 'secret' field is the access key secret. Access keys in
+		// AccessKeys take precedence over those in custom data.
+		AccessKeyCustomDataKind string `json:"accessKeyCustomDataKind,omitempty" jsonschema:"omitempty"`
+		// AccessKeyIDHeader is the request header to set the access key id
+		// of a verified request to.
+		AccessKeyIDHeader string `json:"accessKeyIdHeader,omitempty" jsonschema:"omitempty"`
+	}
+
+	// SignatureValidator verifies request signatures produced by
+	// signer.Signer.
+	SignatureValidator struct {
+		spec   *SignatureValidatorSpec
+		signer *signer.Signer
+		store  *accessKeyStore
+	}
+
+	// accessKeyStore implements signer.AccessKeyStore, it looks up access
+	// keys in the spec and then in custom data.
+	accessKeyStore struct {
+		static map[string]string
+		// dynamic is a map[string]string loaded from custom data.
+		dynamic atomic.Value
+		stopCtx stdcontext.Context
+		cancel  stdcontext.CancelFunc
+	}
+)
+
+// Validate validates the SignatureValidatorSpec.
+func (spec SignatureValidatorSpec) Validate() error {
+	if len(spec.AccessKeys) == 0 && spec.AccessKeyCustomDataKind == "" {
+		return fmt.Errorf("one of accessKeys and accessKeyCustomDataKind must be specified")
+	}
+	return nil
+}
+
+// NewSignatureValidator creates a new signature validator.
+func NewSignatureValidator(spec *SignatureValidatorSpec, super *supervisor.Supervisor) *SignatureValidator {
+	store := &accessKeyStore{static: spec.AccessKeys}
+	store.dynamic.Store(map[string]string{})
+	store.stopCtx, store.cancel = stdcontext.WithCancel(stdcontext.Background())
+
+	if spec.AccessKeyCustomDataKind != "" {
+		if super == nil || super.Cluster() == nil {
+			logger.Errorf("signature validator: cluster is not available, no access keys are loaded from custom data")
+		} else {
+			store.load(super, spec.AccessKeyCustomDataKind)
+		}
+	}
+
+	return &SignatureValidator{
+		spec:   spec,
+		signer: signer.CreateFromSpec(&spec.Spec).SetAccessKeyStore(store),
+		store:  store,
+	}
+}
+
+// load loads access keys from custom data and watches their changes.
+func (s *accessKeyStore) load(super *supervisor.Supervisor, kind string) {
+	cls := super.Cluster()
+	cds := customdata.NewStore(cls, cls.Layout().CustomDataKindPrefix(), cls.Layout().CustomDataPrefix())
+
+	// the kind is loaded on every update, as its idField may change.
+	update := func(data []customdata.Data) {
+		s.update(getKind(cds, kind), data)
+	}
+
+	data, err := cds.ListData(kind)
+	if err != nil {
+		logger.Errorf("signature validator: failed to load access keys: %v", err)
+	} else {
+		update(data)
+	}
+
+	go func() {
+		for {
+			err := cds.Watch(s.stopCtx, kind, update)
+			if err == nil {
+				return
+			}
+			logger.Errorf("signature validator: failed to watch access keys: %v", err)
+
+			select {
+			case <-time.After(10 * time.Second):
+			case <-s.stopCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// getKind gets the custom data kind, it returns a kind with the default
+// idField if failed.
+func getKind(cds *customdata.Store, name string) *customdata.Kind {
+	kind, err := cds.GetKind(name)
+	if err != nil {
+		logger.Errorf("signature validator: failed to get custom data kind %s: %v", name, err)
+	}
+	if kind == nil {
+		kind = &customdata.Kind{Name: name}
+	}
+	return kind
+}
+
+// update replaces the access keys loaded from custom data, the ID of an
+// access key is the ID of its custom data.
+func (s *accessKeyStore) update(kind *customdata.Kind, data []customdata.Data) {
+	keys := make(map[string]string, len(data))
+	for _, d := range data {
+		id, secret := kind.DataID(d), d.GetString("secret")
+		if secret == "" {
+			logger.Errorf("signature validator: access key %s has no secret", id)
+			continue
+		}
+		keys[id] = secret
+	}
+	s.dynamic.Store(keys)
+}
+
+// GetSecret implements signer.AccessKeyStore.
+func (s *accessKeyStore) GetSecret(id string) (string, bool) {
+	if secret, ok := s.static[id]; ok {
+		return secret, true
+	}
+	secret, ok := s.dynamic.Load().(map[string]string)[id]
+	return secret, ok
+}
+
+// Validate verifies the signature of a request.
+func (v *SignatureValidator) Validate(req *httpprot.Request) error {
+	vCtx := v.signer.NewVerificationContext()
+	if req.IsStream() {
+		vCtx.ExcludeBody(true)
+	}
+	if err := vCtx.Verify(req.Std(), req.GetPayload); err != nil {
+		return err
+	}
+
+	// the header is overwritten, so it could not be forged by clients.
+	if v.spec.AccessKeyIDHeader != "" {
+		req.HTTPHeader().Set(v.spec.AccessKeyIDHeader, vCtx.AccessKeyID)
+	}
+	return nil
+}
+
+// Close closes the signature validator.
+func (v *SignatureValidator) Close() {
+	v.store.cancel()
+}
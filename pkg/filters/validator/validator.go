@@ -22,11 +22,12 @@ import (
 
 	"fmt"
 
+	"github.com/golang-jwt/jwt/v4"
+
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/filters"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot/httpheader"
-	"github.com/megaease/easegress/pkg/util/signer"
 	"github.com/megaease/easegress/pkg/util/stringtool"
 )
 
@@ -60,7 +61,7 @@ type (
 
 		headers   *httpheader.Validator
 		jwt       *JWTValidator
-		signer    *signer.Signer
+		signature *SignatureValidator
 		oauth2    *OAuth2Validator
 		basicAuth *BasicAuthValidator
 	}
@@ -71,7 +72,7 @@ type (
 
 		Headers   *httpheader.ValidatorSpec `json:"headers,omitempty" jsonschema:"omitempty"`
 		JWT       *JWTValidatorSpec         `json:"jwt,omitempty" jsonschema:"omitempty"`
-		Signature *signer.Spec              `json:"signature,omitempty" jsonschema:"omitempty"`
+		Signature *SignatureValidatorSpec   `json:"signature,omitempty" jsonschema:"omitempty"`
 		OAuth2    *OAuth2ValidatorSpec      `json:"oauth2,omitempty" jsonschema:"omitempty"`
 		BasicAuth *BasicAuthValidatorSpec   `json:"basicAuth,omitempty" jsonschema:"omitempty"`
 	}
@@ -118,7 +119,7 @@ func (v *Validator) reload() {
 		v.jwt = NewJWTValidator(v.spec.JWT)
 	}
 	if v.spec.Signature != nil {
-		v.signer = signer.CreateFromSpec(v.spec.Signature)
+		v.signature = NewSignatureValidator(v.spec.Signature, v.spec.Super())
 	}
 	if v.spec.OAuth2 != nil {
 		v.oauth2 = NewOAuth2Validator(v.spec.OAuth2)
@@ -149,18 +150,20 @@ func (v *Validator) Handle(ctx *context.Context) string {
 			return resultInvalid
 		}
 	}
+	var claims jwt.MapClaims
 	if v.jwt != nil {
-		if err := v.jwt.Validate(req); err != nil {
+		var err error
+		if claims, err = v.jwt.Validate(req); err != nil {
 			prepareErrorResponse(http.StatusUnauthorized, "JWT validator: ", err)
 			return resultInvalid
 		}
-	}
-	if v.signer != nil {
-		vCtx := v.signer.NewVerificationContext()
-		if req.IsStream() {
-			vCtx.ExcludeBody(true)
+		if err = v.jwt.Authorize(req, claims); err != nil {
+			prepareErrorResponse(http.StatusForbidden, "JWT authorization: ", err)
+			return resultInvalid
 		}
-		if err := vCtx.Verify(req.Std(), req.GetPayload); err != nil {
+	}
+	if v.signature != nil {
+		if err := v.signature.Validate(req); err != nil {
 			prepareErrorResponse(http.StatusUnauthorized, "signature validator: ", err)
 			return resultInvalid
 		}
@@ -178,6 +181,11 @@ func (v *Validator) Handle(ctx *context.Context) string {
 		}
 	}
 
+	// claims are forwarded after all validations passed.
+	if v.jwt != nil {
+		v.jwt.ForwardClaims(ctx, req, claims)
+	}
+
 	return ""
 }
 
@@ -186,6 +194,12 @@ func (v *Validator) Status() interface{} { return nil }
 
 // Close closes validations.
 func (v *Validator) Close() {
+	if v.jwt != nil {
+		v.jwt.Close()
+	}
+	if v.signature != nil {
+		v.signature.Close()
+	}
 	if v.basicAuth != nil {
 		v.basicAuth.Close()
 	}
@@ -18,24 +18,37 @@
 package validator
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang-jwt/jwt/v4"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
 	cluster "github.com/megaease/easegress/pkg/cluster"
 	"github.com/megaease/easegress/pkg/cluster/clustertest"
+	"github.com/megaease/easegress/pkg/cluster/customdata"
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/filters"
 	"github.com/megaease/easegress/pkg/logger"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/supervisor"
 	"github.com/megaease/easegress/pkg/util/codectool"
+	"github.com/megaease/easegress/pkg/util/signer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -244,6 +257,325 @@ jwt:
 	}
 }
 
+func TestJWTValidatorSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := JWTValidatorSpec{}
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{Algorithm: "HS256", Secret: "313233343536"}
+	assert.NoError(spec.Validate())
+
+	spec.JWKSURL = "http://127.0.0.1/jwks"
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{Secret: "313233343536"}
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{Algorithm: "RS256", Secret: "313233343536"}
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{Algorithm: "HS256", PublicKey: "313233343536"}
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{Algorithm: "RS256", PublicKey: "313233343536"}
+	assert.Error(spec.Validate())
+
+	spec = JWTValidatorSpec{JWKSURL: "http://127.0.0.1/jwks"}
+	assert.NoError(spec.Validate())
+
+	spec = JWTValidatorSpec{Issuer: "http://127.0.0.1"}
+	assert.NoError(spec.Validate())
+}
+
+func TestJWTJWKS(t *testing.T) {
+	assert := assert.New(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+
+	var issuer string
+	var jwksFetched int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer": "%s", "jwks_uri": "%s/jwks"}`, issuer, issuer)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&jwksFetched, 1)
+		n := base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes())
+		fmt.Fprintf(w, `{"keys": [{"kty": "RSA", "kid": "key1", "use": "sig", "alg": "RS256", "n": "%s", "e": "%s"}]}`, n, e)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	yamlConfig := fmt.Sprintf(`
+kind: Validator
+name: validator
+jwt:
+  issuer: %s
+  audiences: [api1, api2]
+  clockSkew: 1m
+`, issuer)
+	v := createValidator(yamlConfig, nil, nil)
+	defer v.Close()
+	assert.Eventually(func() bool {
+		return v.jwt.getJWKS() != nil
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(int32(1), atomic.LoadInt32(&jwksFetched))
+
+	ctx := context.New(nil)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	assert.Nil(err)
+	setRequest(t, ctx, req)
+
+	now := time.Now()
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(privateKey)
+		assert.Nil(err)
+		return s
+	}
+	claims := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": issuer,
+			"aud": "api2",
+			"sub": "user1",
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Unix(),
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", sign("key1", claims(nil)), true},
+		{"multiple audiences", sign("key1", claims(func(c jwt.MapClaims) { c["aud"] = []string{"other", "api1"} })), true},
+		{"expired within skew", sign("key1", claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-30 * time.Second).Unix() })), true},
+		{"not before within skew", sign("key1", claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(30 * time.Second).Unix() })), true},
+		{"expired", sign("key1", claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-2 * time.Minute).Unix() })), false},
+		{"not before", sign("key1", claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(2 * time.Minute).Unix() })), false},
+		{"wrong issuer", sign("key1", claims(func(c jwt.MapClaims) { c["iss"] = "http://example.com" })), false},
+		{"wrong audience", sign("key1", claims(func(c jwt.MapClaims) { c["aud"] = "other" })), false},
+		{"no audience", sign("key1", claims(func(c jwt.MapClaims) { delete(c, "aud") })), false},
+		{"unknown kid", sign("key2", claims(nil)), false},
+	}
+
+	for _, c := range cases {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		result := v.Handle(ctx)
+		if c.valid {
+			assert.Equal("", result, c.name)
+		} else {
+			assert.Equal(resultInvalid, result, c.name)
+		}
+	}
+
+	// a token signed by HMAC with the public key as the secret.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+	token.Header["kid"] = "key1"
+	hmacToken, err := token.SignedString(privateKey.N.Bytes())
+	assert.Nil(err)
+	req.Header.Set("Authorization", "Bearer "+hmacToken)
+	assert.Equal(resultInvalid, v.Handle(ctx))
+
+	// the JWKS URL is used directly, and the algorithm is checked.
+	yamlConfig = fmt.Sprintf(`
+kind: Validator
+name: validator
+jwt:
+  algorithm: RS384
+  jwksURL: %s/jwks
+`, issuer)
+	v2 := createValidator(yamlConfig, nil, nil)
+	defer v2.Close()
+	assert.Eventually(func() bool {
+		return v2.jwt.getJWKS() != nil
+	}, 3*time.Second, 10*time.Millisecond)
+	req.Header.Set("Authorization", "Bearer "+sign("key1", claims(nil)))
+	assert.Equal(resultInvalid, v2.Handle(ctx))
+}
+
+func TestJWTJWKSLoading(t *testing.T) {
+	assert := assert.New(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+
+	// the JWKS server blocks until released.
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		n := base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes())
+		fmt.Fprintf(w, `{"keys": [{"kty": "RSA", "kid": "key1", "use": "sig", "alg": "RS256", "n": "%s", "e": "%s"}]}`, n, e)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	v := createValidator(fmt.Sprintf(`
+kind: Validator
+name: validator
+jwt:
+  jwksURL: %s
+`, server.URL), nil, nil)
+	defer v.Close()
+	assert.Less(time.Since(start), time.Second)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1"})
+	token.Header["kid"] = "key1"
+	signed, err := token.SignedString(privateKey)
+	assert.Nil(err)
+
+	ctx := context.New(nil)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	assert.Nil(err)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	setRequest(t, ctx, req)
+
+	// requests are rejected before the key set is loaded.
+	start = time.Now()
+	assert.Equal(resultInvalid, v.Handle(ctx))
+	assert.Less(time.Since(start), time.Second)
+	assert.Equal(http.StatusUnauthorized, ctx.GetOutputResponse().(*httpprot.Response).StatusCode())
+
+	close(release)
+	assert.Eventually(func() bool {
+		return v.Handle(ctx) == ""
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestJWTClaims(t *testing.T) {
+	assert := assert.New(t)
+
+	const yamlConfig = `
+kind: Validator
+name: validator
+jwt:
+  algorithm: HS256
+  secret: "313233343536"
+  rules:
+  - pathPrefix: /orders
+    methods: [POST, PUT]
+    claims:
+    - name: scope
+      contains: ["orders:write"]
+  - pathPrefix: /admin
+    claims:
+    - name: realm_access.roles
+      containsAny: [admin, ops]
+  forwardClaims:
+  - claim: sub
+    header: X-User
+  - claim: realm_access.roles
+    header: X-Roles
+    dataKey: roles
+  - claim: tenant
+    header: X-Tenant
+`
+	v := createValidator(yamlConfig, nil, nil)
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		s, err := token.SignedString([]byte("123456"))
+		assert.Nil(err)
+		return s
+	}
+
+	reader := sign(jwt.MapClaims{
+		"sub":          "user1",
+		"scope":        "orders:read profile",
+		"realm_access": map[string]interface{}{"roles": []string{"user"}},
+	})
+	writer := sign(jwt.MapClaims{
+		"sub":          "user2",
+		"scope":        "orders:read orders:write",
+		"realm_access": map[string]interface{}{"roles": []string{"user", "ops"}},
+	})
+
+	cases := []struct {
+		method string
+		path   string
+		token  string
+		result string
+	}{
+		{http.MethodGet, "/orders/1", reader, ""},
+		{http.MethodPost, "/orders", reader, resultInvalid},
+		{http.MethodPost, "/orders", writer, ""},
+		{http.MethodGet, "/admin/users", reader, resultInvalid},
+		{http.MethodGet, "/admin/users", writer, ""},
+	}
+
+	for _, c := range cases {
+		ctx := context.New(nil)
+		req, err := http.NewRequest(c.method, "http://example.com"+c.path, nil)
+		assert.Nil(err)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		setRequest(t, ctx, req)
+
+		result := v.Handle(ctx)
+		assert.Equal(c.result, result, "%s %s", c.method, c.path)
+		if result != "" {
+			resp := ctx.GetOutputResponse().(*httpprot.Response)
+			assert.Equal(http.StatusForbidden, resp.StatusCode())
+		}
+	}
+
+	ctx := context.New(nil)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/admin", nil)
+	assert.Nil(err)
+	req.Header.Set("Authorization", "Bearer "+writer)
+	req.Header.Set("X-Tenant", "forged")
+	setRequest(t, ctx, req)
+
+	assert.Equal("", v.Handle(ctx))
+	assert.Equal("user2", req.Header.Get("X-User"))
+	assert.Equal("user,ops", req.Header.Get("X-Roles"))
+	assert.Equal("", req.Header.Get("X-Tenant"))
+	assert.Equal([]interface{}{"user", "ops"}, ctx.GetData("roles"))
+}
+
+func TestJWTClaimsSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := JWTClaimRule{}
+	assert.Error(rule.Validate())
+
+	rule.Claims = []*JWTClaimRequirement{{Name: "scope"}}
+	assert.Error(rule.Validate())
+
+	rule.Claims[0].Contains = []string{"read"}
+	assert.NoError(rule.Validate())
+
+	f := JWTClaimForward{Claim: "sub"}
+	assert.Error(f.Validate())
+	f.DataKey = "sub"
+	assert.NoError(f.Validate())
+
+	claims := jwt.MapClaims{
+		"https://example.com/roles": []interface{}{"a"},
+		"a":                         map[string]interface{}{"b": float64(1664431848)},
+	}
+	v, ok := lookupClaim(claims, "https://example.com/roles")
+	assert.True(ok)
+	assert.Equal([]string{"a"}, claimToList(v))
+
+	v, ok = lookupClaim(claims, "a.b")
+	assert.True(ok)
+	assert.Equal("1664431848", claimToHeader(v))
+
+	_, ok = lookupClaim(claims, "a.b.c")
+	assert.False(ok)
+}
+
 func TestOAuth2JWT(t *testing.T) {
 	assert := assert.New(t)
 
@@ -374,6 +706,144 @@ signature:
 	}
 }
 
+func TestSignatureVerification(t *testing.T) {
+	assert := assert.New(t)
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedGetRawPrefix = func(prefix string) (map[string]*mvccpb.KeyValue, error) {
+		assert.Equal("/custom-data/partners/", prefix)
+		return map[string]*mvccpb.KeyValue{
+			"p1": {Value: []byte("name: PARTNER1\nsecret: SECRET1")},
+			"p2": {Value: []byte("name: PARTNER2")},
+		}, nil
+	}
+	syncer := clustertest.NewMockedSyncer()
+	ch := make(chan map[string]*mvccpb.KeyValue)
+	syncer.MockedSyncRawPrefix = func(prefix string) (<-chan map[string]*mvccpb.KeyValue, error) {
+		return ch, nil
+	}
+	syncer.MockedClose = func() {}
+	cls.MockedSyncer = func(t time.Duration) (cluster.Syncer, error) {
+		return syncer, nil
+	}
+
+	var mockMap sync.Map
+	super := supervisor.NewMock(nil, cls, mockMap, mockMap, nil, nil, false, nil, nil)
+
+	v := createValidator(`
+kind: Validator
+name: validator
+signature:
+  ttl: 1m
+  accessKeys:
+    AKID: SECRET
+  accessKeyCustomDataKind: partners
+  accessKeyIdHeader: X-Access-Key-Id
+  literal:
+    scopeSuffix: partner_request
+    algorithmName: X-Ptn-Algorithm
+    algorithmValue: PTN-HMAC-SHA256
+    signedHeaders: X-Ptn-SignedHeaders
+    signature: X-Ptn-Signature
+    date: X-Ptn-Date
+    expires: X-Ptn-Expires
+    credential: X-Ptn-Credential
+    contentSha256: X-Ptn-Content-Sha256
+`, nil, super)
+	defer v.Close()
+
+	literal := v.spec.Signature.Literal
+	newContext := func(id, secret string, timestamp time.Time, presign bool) *context.Context {
+		var stdr *http.Request
+		s := signer.New().SetLiteral(literal).SetCredential(id, secret)
+		sctx := s.NewSigningContext(timestamp, "region", "service")
+		if presign {
+			stdr, _ = http.NewRequest(http.MethodGet, "http://example.com/orders?id=1", nil)
+			stdr.Header.Set("X-Custom", "value")
+			assert.Nil(sctx.Presign(stdr, time.Minute))
+		} else {
+			stdr, _ = http.NewRequest(http.MethodPost, "http://example.com/orders?id=1", strings.NewReader("order"))
+			stdr.Header.Set("X-Custom", "value")
+			assert.Nil(sctx.Sign(stdr, nil))
+		}
+		// not signed, it should be overwritten.
+		stdr.Header.Set("X-Access-Key-Id", "forged")
+
+		req, err := httpprot.NewRequest(stdr)
+		assert.Nil(err)
+		assert.Nil(req.FetchPayload(0))
+		ctx := context.New(nil)
+		ctx.SetInputRequest(req)
+		return ctx
+	}
+
+	ctx := newContext("AKID", "SECRET", time.Now(), false)
+	assert.Equal("", v.Handle(ctx))
+	req := ctx.GetInputRequest().(*httpprot.Request)
+	assert.Equal("AKID", req.HTTPHeader().Get("X-Access-Key-Id"))
+
+	ctx = newContext("PARTNER1", "SECRET1", time.Now(), false)
+	assert.Equal("", v.Handle(ctx))
+	req = ctx.GetInputRequest().(*httpprot.Request)
+	assert.Equal("PARTNER1", req.HTTPHeader().Get("X-Access-Key-Id"))
+
+	ctx = newContext("PARTNER1", "SECRET1", time.Now(), true)
+	assert.Equal("", v.Handle(ctx))
+
+	// wrong secret, unknown access key, access key without secret, and
+	// expired signature.
+	assert.Equal(resultInvalid, v.Handle(newContext("PARTNER1", "WRONG", time.Now(), false)))
+	assert.Equal(resultInvalid, v.Handle(newContext("UNKNOWN", "SECRET", time.Now(), false)))
+	assert.Equal(resultInvalid, v.Handle(newContext("PARTNER2", "", time.Now(), false)))
+	ctx = newContext("AKID", "SECRET", time.Now().Add(-2*time.Minute), false)
+	assert.Equal(resultInvalid, v.Handle(ctx))
+	assert.Equal(http.StatusUnauthorized, ctx.GetOutputResponse().(*httpprot.Response).StatusCode())
+
+	// access keys are updated live.
+	ch <- map[string]*mvccpb.KeyValue{
+		"p2": {Value: []byte("name: PARTNER2\nsecret: SECRET2")},
+	}
+	assert.Eventually(func() bool {
+		return v.Handle(newContext("PARTNER2", "SECRET2", time.Now(), false)) == ""
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(resultInvalid, v.Handle(newContext("PARTNER1", "SECRET1", time.Now(), false)))
+}
+
+func TestAccessKeyStoreIDField(t *testing.T) {
+	assert := assert.New(t)
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedGetRaw = func(key string) (*mvccpb.KeyValue, error) {
+		assert.Equal("/custom-data-kinds/partners", key)
+		return &mvccpb.KeyValue{Value: []byte("name: partners\nidField: accessKeyId")}, nil
+	}
+	cds := customdata.NewStore(cls, "/custom-data-kinds/", "/custom-data/")
+
+	s := &accessKeyStore{}
+	s.update(getKind(cds, "partners"), []customdata.Data{
+		{"name": "Partner One", "accessKeyId": "PARTNER1", "secret": "SECRET1"},
+	})
+	secret, ok := s.GetSecret("PARTNER1")
+	assert.True(ok)
+	assert.Equal("SECRET1", secret)
+	_, ok = s.GetSecret("Partner One")
+	assert.False(ok)
+}
+
+func TestSignatureValidatorSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := SignatureValidatorSpec{}
+	assert.Error(spec.Validate())
+
+	spec.AccessKeyCustomDataKind = "partners"
+	assert.NoError(spec.Validate())
+
+	spec = SignatureValidatorSpec{}
+	spec.AccessKeys = map[string]string{"AKID": "SECRET"}
+	assert.NoError(spec.Validate())
+}
+
 func check(e error) {
 	if e != nil {
 		panic(e)
@@ -585,3 +1055,214 @@ lastEntry: "byebye"
 		v.Close()
 	})
 }
+
+// ldapStandIn is an in-process LDAP server for testing, it only supports
+// simple bind and search with an equality filter.
+type ldapStandIn struct {
+	listener net.Listener
+	binds    int32
+	entries  []*ldapStandInEntry
+}
+
+type ldapStandInEntry struct {
+	dn       string
+	uid      string
+	password string
+	memberOf []string
+}
+
+func newLDAPStandIn(t *testing.T, entries []*ldapStandInEntry) *ldapStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := &ldapStandIn{listener: l, entries: entries}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStandIn) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStandIn) Close() {
+	s.listener.Close()
+}
+
+func (s *ldapStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			atomic.AddInt32(&s.binds, 1)
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := ldap.LDAPResultInvalidCredentials
+			for _, e := range s.entries {
+				if e.dn == dn && e.password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			conn.Write(ldapStandInResult(id, ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			filter := op.Children[6]
+			if filter.Tag == ldap.FilterEqualityMatch && filter.Children[0].Value.(string) == "uid" {
+				uid := filter.Children[1].Value.(string)
+				for _, e := range s.entries {
+					if e.uid == uid {
+						conn.Write(ldapStandInEntryMessage(id, e))
+					}
+				}
+			}
+			conn.Write(ldapStandInResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			return
+		}
+	}
+}
+
+func ldapStandInMessage(id int64, op *ber.Packet) []byte {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(op)
+	return p.Bytes()
+}
+
+func ldapStandInResult(id int64, tag ber.Tag, code int) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return ldapStandInMessage(id, op)
+}
+
+func ldapStandInEntryMessage(id int64, e *ldapStandInEntry) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "memberOf", "Type"))
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	for _, g := range e.memberOf {
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, g, "Value"))
+	}
+	attr.AppendChild(values)
+	attrs.AppendChild(attr)
+	op.AppendChild(attrs)
+
+	return ldapStandInMessage(id, op)
+}
+
+func TestBasicAuthLDAP(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newLDAPStandIn(t, []*ldapStandInEntry{
+		{dn: "cn=admin,dc=example,dc=org", password: "adminpass"},
+		{
+			dn:       "uid=alice,ou=people,dc=example,dc=org",
+			uid:      "alice",
+			password: "alicepass",
+			memberOf: []string{"cn=gateway,ou=groups,dc=example,dc=org"},
+		},
+		{
+			dn:       "uid=bob,ou=people,dc=example,dc=org",
+			uid:      "bob",
+			password: "bobpass",
+			memberOf: []string{"cn=others,ou=groups,dc=example,dc=org"},
+		},
+	})
+	defer server.Close()
+
+	yamlConfig := fmt.Sprintf(`
+kind: Validator
+name: validator
+basicAuth:
+  mode: LDAP
+  ldap:
+    url: %s
+    bindDN: cn=admin,dc=example,dc=org
+    bindPassword: adminpass
+    baseDN: dc=example,dc=org
+    groups: ["CN=gateway,OU=groups,DC=example,DC=org"]
+    cacheTTL: 1m
+`, server.URL())
+	v := createValidator(yamlConfig, nil, nil)
+	defer v.Close()
+
+	check := func(user, password, expected string) {
+		ctx := context.New(nil)
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		assert.Nil(err)
+		req.SetBasicAuth(user, password)
+		setRequest(t, ctx, req)
+		assert.Equal(expected, v.Handle(ctx), "user %s", user)
+	}
+
+	check("alice", "alicepass", "")
+	binds := atomic.LoadInt32(&server.binds)
+	assert.Equal(int32(2), binds)
+
+	// successful authentications are cached.
+	check("alice", "alicepass", "")
+	assert.Equal(binds, atomic.LoadInt32(&server.binds))
+
+	check("alice", "wrong", resultInvalid)
+	check("alice", "", resultInvalid)
+	check("bob", "bobpass", resultInvalid)
+	check("carol", "carolpass", resultInvalid)
+	check("*", "alicepass", resultInvalid)
+
+	// failed authentications are not cached.
+	binds = atomic.LoadInt32(&server.binds)
+	check("alice", "wrong", resultInvalid)
+	assert.Equal(binds+2, atomic.LoadInt32(&server.binds))
+}
+
+func TestLDAPSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := LDAPSpec{URL: "ldap://127.0.0.1", BaseDN: "dc=example,dc=org"}
+	assert.NoError(spec.Validate())
+
+	spec.StartTLS = true
+	assert.NoError(spec.Validate())
+
+	spec.URL = "ldaps://127.0.0.1"
+	assert.Error(spec.Validate())
+
+	spec.URL = "http://127.0.0.1"
+	assert.Error(spec.Validate())
+
+	spec = LDAPSpec{URL: "ldap://127.0.0.1", UserFilter: "(sAMAccountName=%s)"}
+	assert.NoError(spec.Validate())
+
+	spec.UserFilter = "(sAMAccountName=alice)"
+	assert.Error(spec.Validate())
+
+	basicAuth := BasicAuthValidatorSpec{Mode: "LDAP"}
+	assert.Error(basicAuth.Validate())
+
+	spec = LDAPSpec{}
+	codectool.MustUnmarshal([]byte(`
+url: ldaps://127.0.0.1
+baseDN: dc=example,dc=org
+insecureTLS: true
+`), &spec)
+	assert.True(spec.InsecureTLS)
+}
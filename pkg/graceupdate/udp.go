@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graceupdate
+
+import (
+	"context"
+	"net"
+)
+
+// ListenUDP listens on the local UDP address laddr.
+//
+// gracenet only supports stream listeners, so a UDP socket is not passed
+// to the new process on graceful update. Instead, it is created with
+// SO_REUSEPORT where supported, which allows the new process to listen on
+// the same address while the old process is still serving.
+//
+// SO_REUSEPORT also allows other sockets to listen on the same address
+// silently, and the datagrams are distributed among them. So except in
+// the new process of a graceful update, the address is checked with a
+// socket without SO_REUSEPORT first, to fail if it is already in use.
+func ListenUDP(laddr string) (*net.UDPConn, error) {
+	if !IsInherit() {
+		probe, err := net.ListenPacket("udp", laddr)
+		if err != nil {
+			return nil, err
+		}
+		probe.Close()
+	}
+
+	lc := net.ListenConfig{Control: reusePort}
+	conn, err := lc.ListenPacket(context.Background(), "udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
@@ -0,0 +1,47 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graceupdate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUDP(t *testing.T) {
+	assert := assert.New(t)
+
+	conn1, err := ListenUDP("127.0.0.1:0")
+	assert.NoError(err)
+	defer conn1.Close()
+
+	// the address is in use.
+	_, err = ListenUDP(conn1.LocalAddr().String())
+	assert.Error(err)
+
+	// a new process is able to listen on the same address during graceful
+	// update.
+	didInherit = true
+	defer func() { didInherit = false }()
+	conn2, err := ListenUDP(conn1.LocalAddr().String())
+	assert.NoError(err)
+	defer conn2.Close()
+}
@@ -68,6 +68,7 @@ func Sync() {
 	httpFilterAccessLogger.Sync()
 	httpFilterDumpLogger.Sync()
 	restAPILogger.Sync()
+	auditLogger.Sync()
 }
 
 // APIAccess logs admin api log.
@@ -84,6 +85,12 @@ func APIAccess(
 		fasttime.Format(requestTime, fasttime.RFC3339), processTime)
 }
 
+// Audit logs an audit record of the admin api, the record is expected to
+// be a single line JSON.
+func Audit(record string) {
+	auditLogger.Info(record)
+}
+
 // HTTPAccess logs http access log.
 func HTTPAccess(template string, args ...interface{}) {
 	httpFilterAccessLogger.Debugf(template, args...)
@@ -36,6 +36,7 @@ func Init(opt *option.Options) {
 	initDefault(opt)
 	initHTTPFilter(opt)
 	initRestAPI(opt)
+	initAudit(opt)
 }
 
 // InitNop initializes all logger as nop, mainly for unit testing
@@ -44,6 +45,7 @@ func InitNop() {
 	httpFilterAccessLogger = nop.Sugar()
 	httpFilterDumpLogger = nop.Sugar()
 	restAPILogger = nop.Sugar()
+	auditLogger = nop.Sugar()
 
 	defaultLogger = nop.Sugar()
 	gressLogger = defaultLogger
@@ -56,6 +58,7 @@ func InitMock() {
 	httpFilterAccessLogger = mock.Sugar()
 	httpFilterDumpLogger = mock.Sugar()
 	restAPILogger = mock.Sugar()
+	auditLogger = mock.Sugar()
 
 	defaultLogger = mock.Sugar()
 	gressLogger = defaultLogger
@@ -67,6 +70,7 @@ const (
 	filterHTTPAccessFilename = "filter_http_access.log"
 	filterHTTPDumpFilename   = "filter_http_dump.log"
 	adminAPIFilename         = "admin_api.log"
+	auditFilename            = "admin_audit.log"
 
 	// EtcdClientFilename is the filename of etcd client log.
 	EtcdClientFilename = "etcd_client.log"
@@ -86,6 +90,7 @@ var (
 	httpFilterAccessLogger *zap.SugaredLogger
 	httpFilterDumpLogger   *zap.SugaredLogger
 	restAPILogger          *zap.SugaredLogger
+	auditLogger            *zap.SugaredLogger
 )
 
 // EtcdClientLoggerConfig generates the config of etcd client logger.
@@ -176,11 +181,21 @@ func initRestAPI(opt *option.Options) {
 	restAPILogger = newPlainLogger(opt, adminAPIFilename, systemLogMaxCacheCount)
 }
 
+// initAudit initializes the audit logger, it is not affected by
+// DisableAccessLog as the audit log is required for security reasons.
+func initAudit(opt *option.Options) {
+	auditLogger = newFileLogger(opt, auditFilename, systemLogMaxCacheCount)
+}
+
 func newPlainLogger(opt *option.Options, filename string, maxCacheCount uint32) *zap.SugaredLogger {
 	if opt.DisableAccessLog {
 		return zap.NewNop().Sugar()
 	}
 
+	return newFileLogger(opt, filename, maxCacheCount)
+}
+
+func newFileLogger(opt *option.Options, filename string, maxCacheCount uint32) *zap.SugaredLogger {
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:       "",
 		LevelKey:      "",
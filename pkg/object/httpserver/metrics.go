@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpserver
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/megaease/easegress/pkg/protocols/httpprot/httpstat"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/prometheushelper"
+)
+
+// metrics is the Prometheus metrics of HTTPServer.
+type metrics struct {
+	labels   prometheus.Labels
+	requests *prometheus.CounterVec
+	duration prometheus.Observer
+	reqSize  prometheus.Observer
+	respSize prometheus.Observer
+}
+
+func newMetrics(superSpec *supervisor.Spec) *metrics {
+	labels := prometheushelper.Labels(superSpec.Super(), "httpServerName", superSpec.Name())
+	prometheushelper.Acquire(labels)
+
+	return &metrics{
+		labels: labels,
+		requests: prometheushelper.NewCounter(
+			"httpserver_requests_total",
+			"the total number of requests of the HTTPServer",
+			[]string{"httpServerName", "code"},
+		).MustCurryWith(labels),
+		duration: prometheushelper.NewHistogram(
+			"httpserver_request_duration_seconds",
+			"the duration of requests of the HTTPServer",
+			prometheushelper.DurationBuckets,
+			[]string{"httpServerName"},
+		).With(labels),
+		reqSize: prometheushelper.NewHistogram(
+			"httpserver_request_size_bytes",
+			"the size of requests of the HTTPServer",
+			prometheushelper.SizeBuckets,
+			[]string{"httpServerName"},
+		).With(labels),
+		respSize: prometheushelper.NewHistogram(
+			"httpserver_response_size_bytes",
+			"the size of responses of the HTTPServer",
+			prometheushelper.SizeBuckets,
+			[]string{"httpServerName"},
+		).With(labels),
+	}
+}
+
+func (m *metrics) stat(metric *httpstat.Metric) {
+	m.requests.WithLabelValues(strconv.Itoa(metric.StatusCode)).Inc()
+	m.duration.Observe(metric.Duration.Seconds())
+	m.reqSize.Observe(float64(metric.ReqSize))
+	m.respSize.Observe(float64(metric.RespSize))
+}
+
+// close deletes the series of the HTTPServer.
+func (m *metrics) close() {
+	prometheushelper.Release(m.labels)
+}
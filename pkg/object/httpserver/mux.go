@@ -29,6 +29,7 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/megaease/easegress/pkg/object/globalfilter"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 
 	"github.com/megaease/easegress/pkg/context"
@@ -56,6 +57,7 @@ type (
 		spec      *Spec
 		httpStat  *httpstat.HTTPStat
 		topN      *httpstat.TopN
+		metrics   *metrics
 
 		muxMapper context.MuxMapper
 
@@ -378,12 +380,19 @@ func (m *mux) reload(superSpec *supervisor.Spec, muxMapper context.MuxMapper) {
 		tracer = oldInst.tracer
 	}
 
+	// metrics are shared by all instances, they are closed with the mux.
+	metrics := oldInst.metrics
+	if metrics == nil {
+		metrics = newMetrics(superSpec)
+	}
+
 	inst := &muxInstance{
 		superSpec:    superSpec,
 		spec:         spec,
 		muxMapper:    muxMapper,
 		httpStat:     m.httpStat,
 		topN:         m.topN,
+		metrics:      metrics,
 		ipFilter:     newIPFilter(spec.IPFilter),
 		ipFilterChan: newIPFilterChain(nil, spec.IPFilter),
 		rules:        make([]*muxRule, len(spec.Rules)),
@@ -436,16 +445,31 @@ func buildFailureResponse(ctx *context.Context, statusCode int) *httpprot.Respon
 	return resp
 }
 
+// flushWriter flushes the data to the client after each write.
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.f.Flush()
+	return n, err
+}
+
 func (mi *muxInstance) sendResponse(ctx *context.Context, stdw http.ResponseWriter) (int, uint64) {
 	var resp *httpprot.Response
-	if v := ctx.GetResponse(context.DefaultNamespace); v == nil {
+	switch r := ctx.GetResponse(context.DefaultNamespace).(type) {
+	case nil:
 		logger.Errorf("%s: response is nil", mi.superSpec.Name())
 		resp = buildFailureResponse(ctx, http.StatusServiceUnavailable)
-	} else if r, ok := v.(*httpprot.Response); !ok {
+	case *httpprot.Response:
+		resp = r
+	case *grpcprot.Response:
+		resp = r.Response
+	default:
 		logger.Errorf("%s: expect an HTTP response", mi.superSpec.Name())
 		resp = buildFailureResponse(ctx, http.StatusServiceUnavailable)
-	} else {
-		resp = r
 	}
 
 	// Send the response
@@ -454,7 +478,20 @@ func (mi *muxInstance) sendResponse(ctx *context.Context, stdw http.ResponseWrit
 		header[k] = v
 	}
 	stdw.WriteHeader(resp.StatusCode())
-	respBodySize, _ := io.Copy(stdw, resp.GetPayload())
+
+	// gRPC messages in a stream must be sent to the client as soon as
+	// they arrive, as there could be a long interval between them.
+	var w io.Writer = stdw
+	if f, ok := stdw.(http.Flusher); ok && resp.IsStream() && grpcprot.IsGRPCContentType(header.Get("Content-Type")) {
+		w = &flushWriter{w: stdw, f: f}
+	}
+	respBodySize, _ := io.Copy(w, resp.GetPayload())
+
+	// Trailers are only available after the payload is fully read if the
+	// payload is a stream, and they are required by gRPC.
+	for k, v := range resp.Std().Trailer {
+		header[http.TrailerPrefix+k] = v
+	}
 
 	return resp.StatusCode(), uint64(respBodySize) + uint64(resp.MetaSize())
 }
@@ -466,7 +503,7 @@ func (mi *muxInstance) serveHTTP(stdw http.ResponseWriter, stdr *http.Request) {
 	stdr.Body = body
 
 	startAt := fasttime.Now()
-	span := mi.tracer.NewSpanWithStart(mi.superSpec.Name(), startAt)
+	span := mi.tracer.NewSpanForHTTP(stdr, mi.superSpec.Name(), startAt)
 	ctx := context.New(span)
 	ctx.SetData("HTTP_RESPONSE_WRITER", stdw)
 
@@ -503,6 +540,9 @@ func (mi *muxInstance) serveHTTP(stdw http.ResponseWriter, stdr *http.Request) {
 		metric.Duration = fasttime.Since(startAt)
 		topN.Stat(metric)
 		mi.httpStat.Stat(metric)
+		if mi.metrics != nil {
+			mi.metrics.stat(metric)
+		}
 
 		span.Finish()
 
@@ -547,6 +587,11 @@ func (mi *muxInstance) serveHTTP(stdw http.ResponseWriter, stdr *http.Request) {
 	if maxBodySize == 0 {
 		maxBodySize = mi.spec.ClientMaxBodySize
 	}
+	// gRPC calls could be streaming ones, so their payloads are always
+	// handled as streams.
+	if grpcprot.IsGRPCRequest(stdr) {
+		maxBodySize = -1
+	}
 	err := req.FetchPayload(maxBodySize)
 	if err == httpprot.ErrRequestEntityTooLarge {
 		logger.Errorf("%s: %s, you may need to increase 'clientMaxBodySize' or set it to -1", mi.superSpec.Name(), err.Error())
@@ -689,5 +734,9 @@ func (mi *muxInstance) close() {
 }
 
 func (m *mux) close() {
-	m.inst.Load().(*muxInstance).close()
+	inst := m.inst.Load().(*muxInstance)
+	inst.close()
+	if inst.metrics != nil {
+		inst.metrics.close()
+	}
 }
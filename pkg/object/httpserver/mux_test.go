@@ -28,6 +28,7 @@ import (
 
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/context/contexttest"
+	"github.com/megaease/easegress/pkg/protocols/grpcprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot"
 	"github.com/megaease/easegress/pkg/protocols/httpprot/httpstat"
 	"github.com/megaease/easegress/pkg/supervisor"
@@ -369,6 +370,30 @@ rules:
 	assert.Equal(http.StatusBadRequest, stdw.Code)
 }
 
+func TestSendGRPCResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	mi := &muxInstance{}
+
+	resp, _ := grpcprot.NewResponse(nil)
+	resp.SetPayload(strings.NewReader("message"))
+	resp.Std().Trailer = http.Header{}
+	resp.Std().Trailer.Set(grpcprot.HeaderStatus, "0")
+
+	ctx := context.New(tracing.NoopSpan)
+	ctx.SetResponse(context.DefaultNamespace, resp)
+
+	stdw := httptest.NewRecorder()
+	code, _ := mi.sendResponse(ctx, stdw)
+	assert.Equal(http.StatusOK, code)
+
+	result := stdw.Result()
+	assert.True(stdw.Flushed)
+	assert.Equal("message", stdw.Body.String())
+	assert.Equal(grpcprot.ContentType, result.Header.Get("Content-Type"))
+	assert.Equal("0", result.Trailer.Get(grpcprot.HeaderStatus))
+}
+
 func TestMuxInstanceSearch(t *testing.T) {
 	assert := assert.New(t)
 
@@ -30,6 +30,8 @@ import (
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/graceupdate"
@@ -296,9 +298,14 @@ func (r *runtime) startHTTP1And2Server() {
 	fw := filterwriter.New(os.Stderr, func(p []byte) bool {
 		return !bytes.Contains(p, []byte("TLS handshake error"))
 	})
+	var handler http.Handler = r.mux
+	if r.spec.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: keepAliveTimeout})
+	}
+
 	r.server = &http.Server{
 		Addr:        fmt.Sprintf(":%d", r.spec.Port),
-		Handler:     r.mux,
+		Handler:     handler,
 		IdleTimeout: keepAliveTimeout,
 		ErrorLog:    log.New(fw, "", log.LstdFlags),
 	}
@@ -34,6 +34,7 @@ type (
 	// Spec describes the HTTPServer.
 	Spec struct {
 		HTTP3             bool          `json:"http3" jsonschema:"omitempty"`
+		H2C               bool          `json:"h2c" jsonschema:"omitempty"`
 		KeepAlive         bool          `json:"keepAlive" jsonschema:"required"`
 		HTTPS             bool          `json:"https" jsonschema:"required"`
 		AutoCert          bool          `json:"autoCert" jsonschema:"omitempty"`
@@ -122,6 +123,11 @@ func (spec *Spec) Validate() error {
 		return nil
 	}
 
+	// HTTP/2 is always supported over TLS.
+	if spec.H2C {
+		return fmt.Errorf("h2c is for cleartext only, it conflicts with https")
+	}
+
 	if spec.CertBase64 == "" && spec.KeyBase64 == "" && len(spec.Certs) == 0 && len(spec.Keys) == 0 && !spec.AutoCert {
 		return fmt.Errorf("certBase64/keyBase64, certs/keys are both empty and autocert is disabled when https enabled")
 	}
@@ -75,6 +75,17 @@ rules:
 	superSpec, err = supervisor.NewSpec(yamlConfig)
 	assert.True(strings.Contains(err.Error(), "keepAliveTimeout: invalid duration"))
 	assert.Nil(superSpec)
+
+	yamlConfig = `
+name: http-server-test
+kind: HTTPServer
+port: 10080
+https: true
+autoCert: true
+h2c: true`
+	superSpec, err = supervisor.NewSpec(yamlConfig)
+	assert.True(strings.Contains(err.Error(), "h2c is for cleartext only"))
+	assert.Nil(superSpec)
 }
 
 func TestTlsConfig(t *testing.T) {
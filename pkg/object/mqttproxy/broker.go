@@ -35,6 +35,7 @@ import (
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/logger"
 	"github.com/megaease/easegress/pkg/protocols/mqttprot"
+	"github.com/megaease/easegress/pkg/supervisor"
 	"github.com/megaease/easegress/pkg/tracing"
 	"github.com/megaease/easegress/pkg/util/codectool"
 	"github.com/openzipkin/zipkin-go/model"
@@ -54,11 +55,14 @@ type (
 		tlsCfg    *tls.Config
 		pipelines map[PacketType]string
 		muxMapper context.MuxMapper
+		metrics   *metrics
 
 		sessMgr           *SessionManager
 		topicMgr          *TopicManager
 		connectionLimiter *Limiter
 		memberURL         func(string, string) ([]string, error)
+		transferClient    *http.Client
+		memberToken       *api.MemberTokenLoader
 
 		// done is the channel for shutdowning this proxy.
 		done      chan struct{}
@@ -109,15 +113,18 @@ func getPipelineMap(spec *Spec) (map[PacketType]string, error) {
 	return ans, nil
 }
 
-func newBroker(spec *Spec, store storage, muxMapper context.MuxMapper, memberURL func(string, string) ([]string, error)) *Broker {
+func newBroker(super *supervisor.Supervisor, spec *Spec, store storage, muxMapper context.MuxMapper, memberURL func(string, string) ([]string, error)) *Broker {
 	broker := &Broker{
-		egName:    spec.EGName,
-		name:      spec.Name,
-		spec:      spec,
-		clients:   make(map[string]*Client),
-		memberURL: memberURL,
-		done:      make(chan struct{}),
-		muxMapper: muxMapper,
+		egName:         spec.EGName,
+		name:           spec.Name,
+		spec:           spec,
+		clients:        make(map[string]*Client),
+		memberURL:      memberURL,
+		transferClient: newTransferClient(super),
+		memberToken:    newMemberTokenLoader(super),
+		done:           make(chan struct{}),
+		muxMapper:      muxMapper,
+		metrics:        newMetrics(super, spec.Name),
 	}
 	pipelines, err := getPipelineMap(spec)
 	if err != nil {
@@ -148,6 +155,38 @@ func newBroker(spec *Spec, store storage, muxMapper context.MuxMapper, memberURL
 	return broker
 }
 
+// newTransferClient returns the HTTP client to transfer messages to other
+// members, it uses the TLS config of the admin API if it is served over
+// TLS.
+func newTransferClient(super *supervisor.Supervisor) *http.Client {
+	if super == nil || super.Options() == nil {
+		return http.DefaultClient
+	}
+
+	cfg, err := api.ClientTLSConfig(super.Options())
+	if err != nil {
+		logger.Errorf("create tls config to transfer messages failed: %v", err)
+		return http.DefaultClient
+	}
+	if cfg == nil {
+		return http.DefaultClient
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return &http.Client{Transport: transport}
+}
+
+// newMemberTokenLoader returns the loader of the member token, which
+// authenticates the transfer requests if the admin API requires
+// authentication.
+func newMemberTokenLoader(super *supervisor.Supervisor) *api.MemberTokenLoader {
+	if super == nil || super.Cluster() == nil {
+		return nil
+	}
+	return api.NewMemberTokenLoader(super.Cluster())
+}
+
 func (b *Broker) setListener() error {
 	var l net.Listener
 	var err error
@@ -240,6 +279,7 @@ func (b *Broker) deleteSession(clientID string) {
 		}
 	}
 	delete(b.clients, clientID)
+	b.metrics.connections.Set(float64(len(b.clients)))
 }
 
 func (b *Broker) run() {
@@ -366,7 +406,9 @@ func (b *Broker) handleConn(conn net.Conn) {
 		}
 	}
 	b.clients[client.info.cid] = client
+	b.metrics.connections.Set(float64(len(b.clients)))
 	b.Unlock()
+	b.metrics.connects.Inc()
 
 	b.setSession(client, connect)
 	err = connack.Write(conn)
@@ -412,6 +454,21 @@ func (b *Broker) requestTransfer(span *model.SpanContext, egName, name string, d
 		logger.SpanErrorf(span, "json data marshal failed: %v", err)
 		return
 	}
+
+	// the credential of the client is replaced by the member token.
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Authorization")
+	if b.memberToken != nil {
+		token, err := b.memberToken.Token()
+		if err != nil {
+			logger.SpanErrorf(span, "load member token failed: %v", err)
+		} else {
+			header.Set(api.MemberTokenHeader, token)
+		}
+	}
+
 	for _, url := range urls {
 		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
 		req.Header = header.Clone()
@@ -419,7 +476,7 @@ func (b *Broker) requestTransfer(span *model.SpanContext, egName, name string, d
 			logger.SpanErrorf(span, "make new request failed: %v", err)
 			continue
 		}
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := b.transferClient.Do(req)
 		if err != nil {
 			logger.SpanErrorf(span, "http client send msg failed:%v", err)
 		} else {
@@ -446,6 +503,7 @@ func (b *Broker) sendMsgToClient(span *model.SpanContext, topic string, payload
 			logger.SpanDebugf(span, "client %v not on broker %v in eg %v", clientID, b.name, b.egName)
 		} else {
 			client.session.publish(span, topic, payload, qos)
+			b.metrics.publishDelivered.Inc()
 		}
 	}
 }
@@ -464,8 +522,10 @@ func (b *Broker) removeClient(clientID string) {
 	if val, ok := b.clients[clientID]; ok {
 		if val.disconnected() {
 			delete(b.clients, clientID)
+			b.metrics.disconnects.Inc()
 		}
 	}
+	b.metrics.connections.Set(float64(len(b.clients)))
 	b.Unlock()
 }
 
@@ -640,7 +700,7 @@ func (b *Broker) registerAPIs() {
 	group := &api.Group{
 		Group: b.name,
 		Entries: []*api.Entry{
-			{Path: b.mqttAPIPrefix(mqttAPITopicPublishPrefix), Method: http.MethodPost, Handler: b.httpTopicsPublishHandler},
+			{Path: b.mqttAPIPrefix(mqttAPITopicPublishPrefix), Method: http.MethodPost, Handler: b.httpTopicsPublishHandler, Internal: true},
 			{Path: b.mqttAPIPrefix(mqttAPISessionQueryPrefix), Method: http.MethodGet, Handler: b.httpGetAllSessionHandler},
 			{Path: b.mqttAPIPrefix(mqttAPISessionDeletePrefix), Method: http.MethodDelete, Handler: b.httpDeleteSessionHandler},
 		},
@@ -670,6 +730,7 @@ func (b *Broker) close() {
 		go v.closeAndDelSession()
 	}
 	b.clients = nil
+	b.metrics.close()
 }
 
 func newContext(packet packets.ControlPacket, client mqttprot.Client) *context.Context {
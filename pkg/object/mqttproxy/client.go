@@ -64,6 +64,7 @@ var processPacketMap = map[string]processFnWithErr{
 	"*packets.PublishPacket": func(c *Client, packet packets.ControlPacket) error {
 		publish := packet.(*packets.PublishPacket)
 		logger.SpanDebugf(nil, "client %s process publish %v", c.info.cid, publish.TopicName)
+		c.broker.metrics.publishReceived.Inc()
 		if !c.checkPublishLimit(publish) {
 			logger.SpanErrorf(nil, "client %v publish limiter drop packet %v", c.info.cid, publish.TopicName)
 			return nil
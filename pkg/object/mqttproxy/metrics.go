@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/prometheushelper"
+)
+
+// metrics is the Prometheus metrics of a MQTTProxy.
+type metrics struct {
+	labels           prometheus.Labels
+	connections      prometheus.Gauge
+	connects         prometheus.Counter
+	disconnects      prometheus.Counter
+	publishReceived  prometheus.Counter
+	publishDelivered prometheus.Counter
+}
+
+func newMetrics(super *supervisor.Supervisor, name string) *metrics {
+	labels := prometheushelper.Labels(super, "mqttProxyName", name)
+	names := []string{"mqttProxyName"}
+	prometheushelper.Acquire(labels)
+
+	return &metrics{
+		labels: labels,
+		connections: prometheushelper.NewGauge(
+			"mqttproxy_connections",
+			"the number of connected clients",
+			names,
+		).With(labels),
+		connects: prometheushelper.NewCounter(
+			"mqttproxy_connects_total",
+			"the total number of accepted client connections",
+			names,
+		).With(labels),
+		disconnects: prometheushelper.NewCounter(
+			"mqttproxy_disconnects_total",
+			"the total number of client disconnections",
+			names,
+		).With(labels),
+		publishReceived: prometheushelper.NewCounter(
+			"mqttproxy_publish_received_total",
+			"the total number of PUBLISH packets received from clients",
+			names,
+		).With(labels),
+		publishDelivered: prometheushelper.NewCounter(
+			"mqttproxy_publish_delivered_total",
+			"the total number of messages delivered to subscribers",
+			names,
+		).With(labels),
+	}
+}
+
+// close deletes the series of the MQTTProxy.
+func (m *metrics) close() {
+	prometheushelper.Release(m.labels)
+}
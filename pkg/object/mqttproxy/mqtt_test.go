@@ -20,12 +20,18 @@ package mqttproxy
 import (
 	"bytes"
 	stdcontext "context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"sync"
@@ -34,6 +40,9 @@ import (
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/megaease/easegress/pkg/api"
+	"github.com/megaease/easegress/pkg/cluster"
+	"github.com/megaease/easegress/pkg/cluster/clustertest"
 	"github.com/megaease/easegress/pkg/context"
 	"github.com/megaease/easegress/pkg/filters"
 	_ "github.com/megaease/easegress/pkg/filters/mqttclientauth"
@@ -45,6 +54,8 @@ import (
 	"github.com/openzipkin/zipkin-go/propagation/b3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 func init() {
@@ -119,7 +130,7 @@ func getDefaultSpec() *Spec {
 
 func getBrokerFromSpec(spec *Spec, mapper context.MuxMapper) *Broker {
 	store := newStorage(nil)
-	broker := newBroker(spec, store, mapper, func(s, ss string) ([]string, error) {
+	broker := newBroker(nil, spec, store, mapper, func(s, ss string) ([]string, error) {
 		m := map[string]string{
 			"test":  "http://localhost:8888/mqtt",
 			"test1": "http://localhost:8889/mqtt",
@@ -1371,10 +1382,14 @@ func TestMQTTProxy(t *testing.T) {
 	broker.reconnectWatcher()
 	mp.Close()
 
-	ans, err := updatePort("http://example.com:1234", "demo.com:2345")
+	ans, err := updatePort("http://example.com:1234", "demo.com:2345", false)
 	assert.Nil(err)
 	assert.Equal("http://example.com:2345", ans)
 
+	ans, err = updatePort("http://example.com:1234", "demo.com:2345", true)
+	assert.Nil(err)
+	assert.Equal("https://example.com:2345", ans)
+
 	yamlStr := `
 name: mqtt-proxy
 kind: MQTTProxy
@@ -1399,6 +1414,155 @@ kind: MQTTProxy
 	newmp.Close()
 }
 
+func TestMemberURLOverTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	members := map[string]string{
+		"/status/members/eg-0": `
+options:
+  name: eg-0
+  api-addr: 127.0.0.1:2381
+`,
+		"/status/members/eg-1": `
+options:
+  name: eg-1
+  api-addr: 127.0.0.1:2391
+  cluster:
+    initial-advertise-peer-urls:
+    - http://127.0.0.1:2390
+`,
+		"/status/members/eg-2": `
+options:
+  name: eg-2
+  api-addr: 127.0.0.1:2401
+  api-cert-file: /etc/easegress/api.crt
+  api-key-file: /etc/easegress/api.key
+  cluster:
+    initial-advertise-peer-urls:
+    - http://127.0.0.1:2400
+`,
+	}
+
+	cls := clustertest.NewMockedCluster()
+	cls.MockedLayout = func() *cluster.Layout {
+		return &cluster.Layout{}
+	}
+	cls.MockedGetPrefix = func(prefix string) (map[string]string, error) {
+		return members, nil
+	}
+	super := supervisor.NewMock(option.New(), cls, sync.Map{}, sync.Map{}, nil, nil, false, nil, nil)
+	superSpec, err := super.NewSpec(`
+name: mqtt-proxy
+kind: MQTTProxy
+`)
+	assert.Nil(err)
+
+	urls, err := memberURLFunc(superSpec)("eg-0", "mqtt-proxy")
+	assert.Nil(err)
+	assert.ElementsMatch([]string{
+		"http://127.0.0.1:2391/apis/v2/mqttproxy/mqtt-proxy/topics/publish",
+		"https://127.0.0.1:2401/apis/v2/mqttproxy/mqtt-proxy/topics/publish",
+	}, urls)
+}
+
+func TestHTTPTransferOverTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	clientCerts := make(chan int, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts <- len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// the certificate of the test server is self signed, so it is used as
+	// the certificate, the key and the CA of the admin API.
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "api.crt")
+	keyFile := filepath.Join(dir, "api.key")
+	key, err := x509.MarshalPKCS8PrivateKey(server.TLS.Certificates[0].PrivateKey)
+	assert.Nil(err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(os.WriteFile(certFile, cert, 0o600))
+	assert.Nil(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	opt := option.New()
+	opt.APICertFile = certFile
+	opt.APIKeyFile = keyFile
+	opt.APIClientCAFile = certFile
+	super := supervisor.NewMock(opt, nil, sync.Map{}, sync.Map{}, nil, nil, false, nil, nil)
+
+	broker := &Broker{
+		egName: "eg-0",
+		memberURL: func(string, string) ([]string, error) {
+			return []string{server.URL}, nil
+		},
+		transferClient: newTransferClient(super),
+	}
+	broker.requestTransfer(nil, "eg-0", "mqtt-proxy", HTTPJsonData{Topic: "test", Payload: "data"}, http.Header{})
+
+	select {
+	case n := <-clientCerts:
+		assert.Equal(1, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not transferred over TLS")
+	}
+
+	// without the certificate, messages are transferred with the default
+	// client, which does not trust the test server.
+	assert.Equal(http.DefaultClient, newTransferClient(supervisor.NewMock(option.New(), nil, sync.Map{}, sync.Map{}, nil, nil, false, nil, nil)))
+}
+
+func TestHTTPTransferMemberToken(t *testing.T) {
+	assert := assert.New(t)
+
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer server.Close()
+
+	kvs := map[string]string{}
+	cls := clustertest.NewMockedCluster()
+	cls.MockedLayout = func() *cluster.Layout {
+		return &cluster.Layout{}
+	}
+	cls.MockedSTM = func(apply func(concurrency.STM) error) error {
+		return apply(&clustertest.MockedSTM{
+			MockedGet: func(key ...string) string {
+				return kvs[key[0]]
+			},
+			MockedPut: func(key, val string, opts ...clientv3.OpOption) {
+				kvs[key] = val
+			},
+		})
+	}
+	super := supervisor.NewMock(option.New(), cls, sync.Map{}, sync.Map{}, nil, nil, false, nil, nil)
+
+	broker := &Broker{
+		egName: "eg-0",
+		memberURL: func(string, string) ([]string, error) {
+			return []string{server.URL}, nil
+		},
+		transferClient: http.DefaultClient,
+		memberToken:    newMemberTokenLoader(super),
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer client-token")
+	broker.requestTransfer(nil, "eg-0", "mqtt-proxy", HTTPJsonData{Topic: "test", Payload: "data"}, header)
+
+	select {
+	case h := <-headers:
+		token, err := api.NewMemberTokenLoader(cls).Token()
+		assert.Nil(err)
+		assert.Equal(token, h.Get(api.MemberTokenHeader))
+		assert.Empty(h.Get("Authorization"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not transferred")
+	}
+}
+
 func TestPipeline(t *testing.T) {
 	// create test pipeline first
 	yamlStr := `
@@ -72,7 +72,9 @@ func (mp *MQTTProxy) Status() *supervisor.Status {
 	return &supervisor.Status{}
 }
 
-func updatePort(urlStr string, hostWithPort string) (string, error) {
+// updatePort replaces the port of urlStr with the port of hostWithPort,
+// and the scheme with https if useTLS is true, or http otherwise.
+func updatePort(urlStr string, hostWithPort string, useTLS bool) (string, error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return "", fmt.Errorf("parse url %v failed: %v", urlStr, err)
@@ -86,6 +88,10 @@ func updatePort(urlStr string, hostWithPort string) (string, error) {
 		return "", fmt.Errorf("split host for hostWithPort %v failed: %v", hostWithPort, err)
 	}
 	u.Host = net.JoinHostPort(host, port)
+	u.Scheme = "http"
+	if useTLS {
+		u.Scheme = "https"
+	}
 	return u.String(), nil
 }
 
@@ -119,7 +125,9 @@ func memberURLFunc(superSpec *supervisor.Spec) func(string, string) ([]string, e
 				}
 				egURL := egURLs[0]
 				apiAddr := memberStatus.Options.APIAddr
-				newURL, err := updatePort(egURL, apiAddr)
+				// the admin API of the member is served over TLS if it
+				// has a certificate.
+				newURL, err := updatePort(egURL, apiAddr, memberStatus.Options.APICertFile != "")
 				if err != nil {
 					return nil, fmt.Errorf("get url for %v failed: %v", memberStatus.Options.Name, err)
 				}
@@ -140,7 +148,7 @@ func (mp *MQTTProxy) Init(superSpec *supervisor.Spec, muxMapper context.MuxMappe
 	mp.superSpec, mp.spec = superSpec, spec
 
 	store := newStorage(superSpec.Super().Cluster())
-	mp.broker = newBroker(spec, store, muxMapper, memberURLFunc(superSpec))
+	mp.broker = newBroker(superSpec.Super(), spec, store, muxMapper, memberURLFunc(superSpec))
 	if mp.broker == nil {
 		panic(fmt.Sprintf("broker %v start failed", spec.Name))
 	}
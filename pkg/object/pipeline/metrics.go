@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pipeline
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/prometheushelper"
+)
+
+// metrics is the Prometheus metrics of the filters of a Pipeline.
+type metrics struct {
+	labels   prometheus.Labels
+	results  *prometheus.CounterVec
+	duration prometheus.ObserverVec
+}
+
+func newMetrics(superSpec *supervisor.Spec) *metrics {
+	labels := prometheushelper.Labels(superSpec.Super(), "pipelineName", superSpec.Name())
+	prometheushelper.Acquire(labels)
+
+	return &metrics{
+		labels: labels,
+		results: prometheushelper.NewCounter(
+			"pipeline_filter_results_total",
+			"the total number of results of the filters of the Pipeline",
+			[]string{"pipelineName", "filterName", "filterKind", "result"},
+		).MustCurryWith(labels),
+		duration: prometheushelper.NewHistogram(
+			"pipeline_filter_duration_seconds",
+			"the duration of the filters of the Pipeline",
+			prometheushelper.DurationBuckets,
+			[]string{"pipelineName", "filterName", "filterKind"},
+		).MustCurryWith(labels),
+	}
+}
+
+func (m *metrics) stat(stat *FilterStat) {
+	m.results.WithLabelValues(stat.Name, stat.Kind, stat.Result).Inc()
+	m.duration.WithLabelValues(stat.Name, stat.Kind).Observe(stat.Duration.Seconds())
+}
+
+// close deletes the series of the Pipeline, including those of its
+// filters.
+func (m *metrics) close() {
+	prometheushelper.Release(m.labels)
+}
@@ -59,6 +59,7 @@ type (
 		filters    map[string]filters.Filter
 		flow       []FlowNode
 		resilience map[string]resilience.Policy
+		metrics    *metrics
 	}
 
 	// Spec describes the Pipeline.
@@ -236,6 +237,7 @@ func (p *Pipeline) reload(previousGeneration *Pipeline) {
 
 	super := p.superSpec.Super()
 	pipelineName := p.superSpec.Name()
+	p.metrics = newMetrics(p.superSpec)
 
 	// create resilience
 	for _, r := range p.spec.Resilience {
@@ -378,6 +380,9 @@ func (p *Pipeline) doHandle(ctx *context.Context, flow []FlowNode, stats []Filte
 			Duration: fasttime.Since(start),
 			Result:   result,
 		})
+		if p.metrics != nil {
+			p.metrics.stat(&stats[len(stats)-1])
+		}
 
 		var ok bool
 		if next, ok = node.JumpIf[result]; result != "" && !ok {
@@ -413,6 +418,9 @@ func (p *Pipeline) Close() {
 	for _, filter := range p.filters {
 		filter.Close()
 	}
+	if p.metrics != nil {
+		p.metrics.close()
+	}
 }
 
 // ToMetrics implements easemonitor.Metricer.
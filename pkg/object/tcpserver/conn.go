@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const copyBufferSize = 32 * 1024
+
+type (
+	// peekedConn is a connection whose data have been partly read out,
+	// it reads the data from reader, which replays the read data first.
+	peekedConn struct {
+		net.Conn
+		reader io.Reader
+	}
+
+	// readOnlyConn is used to read the client hello message by the TLS
+	// library without responding to the client.
+	readOnlyConn struct {
+		net.Conn
+		reader io.Reader
+	}
+)
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// CloseWrite closes the write side of the underlying connection.
+func (c *peekedConn) CloseWrite() error {
+	return closeWrite(c.Conn)
+}
+
+func (c *readOnlyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *readOnlyConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// peekClientHello reads the TLS client hello message from conn, and
+// returns the message and a connection which replays the message to
+// its reader, so the message could be passed through to the upstream.
+func peekClientHello(conn net.Conn) (*tls.ClientHelloInfo, net.Conn, error) {
+	var hello *tls.ClientHelloInfo
+	buf := &bytes.Buffer{}
+
+	err := tls.Server(&readOnlyConn{Conn: conn, reader: io.TeeReader(conn, buf)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = &tls.ClientHelloInfo{}
+			*hello = *info
+			return nil, nil
+		},
+	}).Handshake()
+
+	// the handshake always fails as the connection is read only, but the
+	// client hello message has been read if hello is set.
+	if hello == nil {
+		return nil, nil, err
+	}
+
+	return hello, &peekedConn{Conn: conn, reader: io.MultiReader(buf, conn)}, nil
+}
+
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return conn.Close()
+}
+
+// pipe copies data between the client and the upstream in both directions
+// until both sides are closed, or the connections are idle for longer than
+// idleTimeout if it is not zero. It returns the bytes read from the client
+// and the upstream.
+func pipe(client, upstream net.Conn, idleTimeout time.Duration) (int64, int64) {
+	lastActive := time.Now().UnixNano()
+	var in, out int64
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	copyData := func(dst, src net.Conn, n *int64) {
+		defer wg.Done()
+
+		var err error
+		*n, err = copyConn(dst, src, idleTimeout, &lastActive)
+		if err == nil {
+			// the source has sent all its data.
+			closeWrite(dst)
+			return
+		}
+
+		// stop copying in the other direction.
+		client.Close()
+		upstream.Close()
+	}
+
+	go copyData(upstream, client, &in)
+	go copyData(client, upstream, &out)
+	wg.Wait()
+
+	return in, out
+}
+
+// copyConn copies data from src to dst until src reaches EOF or an error
+// occurs, a nil error is returned on EOF. Reading from src times out only
+// if no data is transferred in both directions during idleTimeout.
+func copyConn(dst, src net.Conn, idleTimeout time.Duration, lastActive *int64) (int64, error) {
+	buf := make([]byte, copyBufferSize)
+	var written int64
+
+	for {
+		if idleTimeout > 0 {
+			src.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			atomic.StoreInt64(lastActive, time.Now().UnixNano())
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+
+		if err == io.EOF {
+			return written, nil
+		}
+		if err == nil {
+			continue
+		}
+
+		// the other direction is active, keep reading.
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && idleTimeout > 0 {
+			idle := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(lastActive))
+			if idle < idleTimeout {
+				continue
+			}
+		}
+		return written, err
+	}
+}
@@ -0,0 +1,388 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/graceupdate"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+
+	// handshakeTimeout is the timeout of TLS handshakes and reading the
+	// client hello messages in passthrough mode.
+	handshakeTimeout = 10 * time.Second
+
+	// listenRetryInterval is the interval to retry listening on the port
+	// after failures, e.g. the port is in use.
+	listenRetryInterval = 10 * time.Second
+
+	stateNil     stateType = "nil"
+	stateFailed  stateType = "failed"
+	stateRunning stateType = "running"
+	stateClosed  stateType = "closed"
+)
+
+var gnet = graceupdate.Global
+
+type (
+	stateType string
+
+	// config is the immutable runtime configuration generated from the
+	// spec, connections use the config when they are accepted.
+	config struct {
+		spec           *Spec
+		connectTimeout time.Duration
+		idleTimeout    time.Duration
+		ipFilter       *ipfilter.IPFilter
+		tlsConfig      *tls.Config
+		pools          []*proxy.Layer4ServerPool
+	}
+
+	runtime struct {
+		// statistics accessed atomically, they must be the first fields
+		// to guarantee the 64-bit alignment.
+		activeConns   int64
+		totalConns    int64
+		rejectedConns int64
+		failedConns   int64
+		bytesIn       int64
+		bytesOut      int64
+
+		name   string
+		config atomic.Value // *config
+		done   chan struct{}
+
+		// mu protects the fields below.
+		mu       sync.Mutex
+		listener net.Listener
+		conns    map[net.Conn]struct{}
+		state    stateType
+		err      error
+	}
+
+	// Status contains all status generated by runtime, for displaying to users.
+	Status struct {
+		Name  string    `json:"name"`
+		State stateType `json:"state"`
+		Error string    `json:"error,omitempty"`
+
+		ActiveConnections   int64 `json:"activeConnections"`
+		TotalConnections    int64 `json:"totalConnections"`
+		RejectedConnections int64 `json:"rejectedConnections"`
+		FailedConnections   int64 `json:"failedConnections"`
+		BytesIn             int64 `json:"bytesIn"`
+		BytesOut            int64 `json:"bytesOut"`
+	}
+)
+
+func newRuntime(name string) *runtime {
+	r := &runtime{
+		name:  name,
+		done:  make(chan struct{}),
+		conns: map[net.Conn]struct{}{},
+		state: stateNil,
+	}
+
+	go r.retryListen()
+
+	return r
+}
+
+func newConfig(super *supervisor.Supervisor, name string, spec *Spec) *config {
+	c := &config{
+		spec:           spec,
+		connectTimeout: spec.connectTimeout(),
+		idleTimeout:    spec.idleTimeout(),
+	}
+
+	if spec.IPFilter != nil {
+		c.ipFilter = ipfilter.New(spec.IPFilter)
+	}
+
+	if spec.TLS != nil && !spec.TLS.Passthrough {
+		// the spec has been validated.
+		c.tlsConfig, _ = spec.TLS.tlsConfig()
+	}
+
+	for i, rule := range spec.Rules {
+		poolName := fmt.Sprintf("%s#rule%d", name, i)
+		c.pools = append(c.pools, proxy.NewLayer4ServerPool(super, rule.Pool, poolName))
+	}
+
+	return c
+}
+
+func (c *config) close() {
+	for _, pool := range c.pools {
+		pool.Close()
+	}
+}
+
+// choosePool returns the pool of the first rule matching the server name.
+func (c *config) choosePool(serverName string) *proxy.Layer4ServerPool {
+	for i, rule := range c.spec.Rules {
+		if rule.match(serverName) {
+			return c.pools[i]
+		}
+	}
+	return nil
+}
+
+func (r *runtime) getConfig() *config {
+	c, _ := r.config.Load().(*config)
+	return c
+}
+
+// reload applies the new spec, the listener is kept if the port is not
+// changed, so the established connections are not affected.
+func (r *runtime) reload(super *supervisor.Supervisor, spec *Spec) {
+	next := newConfig(super, r.name, spec)
+
+	r.mu.Lock()
+	prev := r.getConfig()
+	r.config.Store(next)
+	if r.listener != nil && prev.spec.Port != spec.Port {
+		r.listener.Close()
+		r.listener = nil
+	}
+	if r.listener == nil {
+		r._listen()
+	}
+	r.mu.Unlock()
+
+	if prev != nil {
+		prev.close()
+	}
+}
+
+// _listen listens on the port, the caller must hold the lock.
+func (r *runtime) _listen() {
+	port := r.getConfig().spec.Port
+	listener, err := gnet.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		logger.Errorf("tcp server %s listen on port %d failed: %v", r.name, port, err)
+		r.state, r.err = stateFailed, err
+		return
+	}
+
+	r.listener = listener
+	r.state, r.err = stateRunning, nil
+	go r.serve(listener)
+}
+
+func (r *runtime) retryListen() {
+	ticker := time.NewTicker(listenRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.state == stateFailed {
+				r._listen()
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *runtime) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err == nil {
+			go r.handleConn(conn)
+			continue
+		}
+
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+
+		r.mu.Lock()
+		// the error is expected if the listener has been closed.
+		if r.listener == listener {
+			logger.Errorf("tcp server %s accept failed: %v", r.name, err)
+			listener.Close()
+			r.listener = nil
+			r.state, r.err = stateFailed, err
+		}
+		r.mu.Unlock()
+		return
+	}
+}
+
+// addConn tracks the connection, it returns false if the connection
+// should be rejected as the connection limit is reached or the runtime
+// is closed.
+func (r *runtime) addConn(conn net.Conn, maxConns uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == stateClosed {
+		return false
+	}
+	if maxConns > 0 && len(r.conns) >= int(maxConns) {
+		return false
+	}
+
+	r.conns[conn] = struct{}{}
+	return true
+}
+
+func (r *runtime) removeConn(conn net.Conn) {
+	r.mu.Lock()
+	delete(r.conns, conn)
+	r.mu.Unlock()
+}
+
+func (r *runtime) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	atomic.AddInt64(&r.totalConns, 1)
+	c := r.getConfig()
+
+	clientAddr := conn.RemoteAddr().String()
+	clientIP, _, _ := net.SplitHostPort(clientAddr)
+	if c.ipFilter != nil && !c.ipFilter.Allow(clientIP) {
+		atomic.AddInt64(&r.rejectedConns, 1)
+		return
+	}
+
+	if !r.addConn(conn, c.spec.MaxConnections) {
+		atomic.AddInt64(&r.rejectedConns, 1)
+		return
+	}
+	defer r.removeConn(conn)
+
+	atomic.AddInt64(&r.activeConns, 1)
+	defer atomic.AddInt64(&r.activeConns, -1)
+
+	client, serverName, err := c.handshake(conn)
+	if err != nil {
+		logger.Debugf("tcp server %s: handshake with %s failed: %v", r.name, clientAddr, err)
+		atomic.AddInt64(&r.failedConns, 1)
+		return
+	}
+
+	pool := c.choosePool(serverName)
+	if pool == nil {
+		atomic.AddInt64(&r.rejectedConns, 1)
+		return
+	}
+
+	addr, done, err := pool.ChooseServer(clientAddr)
+	if err != nil {
+		logger.Warnf("tcp server %s: choose server for %s failed: %v", r.name, clientAddr, err)
+		atomic.AddInt64(&r.failedConns, 1)
+		return
+	}
+	defer done()
+
+	upstream, err := net.DialTimeout("tcp", addr, c.connectTimeout)
+	if err != nil {
+		logger.Warnf("tcp server %s: connect to %s failed: %v", r.name, addr, err)
+		atomic.AddInt64(&r.failedConns, 1)
+		return
+	}
+	defer upstream.Close()
+
+	in, out := pipe(client, upstream, c.idleTimeout)
+	atomic.AddInt64(&r.bytesIn, in)
+	atomic.AddInt64(&r.bytesOut, out)
+}
+
+// handshake does the TLS handshake if required, it returns the connection
+// to read from and write to the client, and the server name indicated by
+// the client.
+func (c *config) handshake(conn net.Conn) (net.Conn, string, error) {
+	if c.spec.TLS == nil {
+		return conn, "", nil
+	}
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if c.spec.TLS.Passthrough {
+		hello, peeked, err := peekClientHello(conn)
+		if err != nil {
+			return nil, "", err
+		}
+		return peeked, hello.ServerName, nil
+	}
+
+	tlsConn := tls.Server(conn, c.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, "", err
+	}
+	return tlsConn, tlsConn.ConnectionState().ServerName, nil
+}
+
+func (r *runtime) status() *Status {
+	r.mu.Lock()
+	s := &Status{
+		Name:  r.name,
+		State: r.state,
+	}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	r.mu.Unlock()
+
+	s.ActiveConnections = atomic.LoadInt64(&r.activeConns)
+	s.TotalConnections = atomic.LoadInt64(&r.totalConns)
+	s.RejectedConnections = atomic.LoadInt64(&r.rejectedConns)
+	s.FailedConnections = atomic.LoadInt64(&r.failedConns)
+	s.BytesIn = atomic.LoadInt64(&r.bytesIn)
+	s.BytesOut = atomic.LoadInt64(&r.bytesOut)
+	return s
+}
+
+// close closes the listener and all connections.
+func (r *runtime) close() {
+	close(r.done)
+
+	r.mu.Lock()
+	r.state = stateClosed
+	if r.listener != nil {
+		r.listener.Close()
+		r.listener = nil
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	if c := r.getConfig(); c != nil {
+		c.close()
+	}
+}
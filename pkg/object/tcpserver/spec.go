@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+type (
+	// Spec describes the TCPServer.
+	Spec struct {
+		Port           uint16         `json:"port" jsonschema:"required,minimum=1"`
+		MaxConnections uint32         `json:"maxConnections" jsonschema:"omitempty,minimum=1"`
+		ConnectTimeout string         `json:"connectTimeout" jsonschema:"omitempty,format=duration"`
+		IdleTimeout    string         `json:"idleTimeout" jsonschema:"omitempty,format=duration"`
+		IPFilter       *ipfilter.Spec `json:"ipFilter,omitempty" jsonschema:"omitempty"`
+		TLS            *TLSSpec       `json:"tls,omitempty" jsonschema:"omitempty"`
+		Rules          []*Rule        `json:"rules" jsonschema:"required"`
+	}
+
+	// TLSSpec describes the TLS of the TCPServer. The TLS is terminated by
+	// the server with the certificates, or passed through to the upstream
+	// servers if Passthrough is true, the server name indication (SNI) of
+	// the client is used to route connections in both cases.
+	TLSSpec struct {
+		Passthrough  bool              `json:"passthrough" jsonschema:"omitempty"`
+		Certs        map[string]string `json:"certs" jsonschema:"omitempty"`
+		Keys         map[string]string `json:"keys" jsonschema:"omitempty"`
+		CaCertBase64 string            `json:"caCertBase64" jsonschema:"omitempty,format=base64"`
+	}
+
+	// Rule routes the connections to a server pool, rules are matched in
+	// the order of their appearance in the spec.
+	Rule struct {
+		// Hosts are the server names to match, "*.example.com" matches
+		// all subdomains of example.com, empty means to match all.
+		Hosts []string                    `json:"hosts" jsonschema:"omitempty,uniqueItems=true"`
+		Pool  *proxy.Layer4ServerPoolSpec `json:"pool" jsonschema:"required"`
+	}
+)
+
+// Validate validates Spec.
+func (spec *Spec) Validate() error {
+	if len(spec.Rules) == 0 {
+		return fmt.Errorf("rules are empty")
+	}
+
+	for i, rule := range spec.Rules {
+		if len(rule.Hosts) > 0 && spec.TLS == nil {
+			return fmt.Errorf("rule %d: hosts require tls", i)
+		}
+	}
+
+	if spec.TLS == nil {
+		return nil
+	}
+
+	if spec.TLS.Passthrough {
+		if len(spec.TLS.Certs) > 0 || len(spec.TLS.Keys) > 0 || spec.TLS.CaCertBase64 != "" {
+			return fmt.Errorf("tls: certificates are not used in passthrough mode")
+		}
+		return nil
+	}
+
+	_, err := spec.TLS.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("tls: %v", err)
+	}
+	return nil
+}
+
+func (spec *Spec) connectTimeout() time.Duration {
+	d, _ := time.ParseDuration(spec.ConnectTimeout)
+	if d <= 0 {
+		d = defaultConnectTimeout
+	}
+	return d
+}
+
+func (spec *Spec) idleTimeout() time.Duration {
+	d, _ := time.ParseDuration(spec.IdleTimeout)
+	return d
+}
+
+func tryDecodeBase64Pem(pem string) []byte {
+	// the pem could be in base64 encoding or plain text, see the same
+	// function of HTTPServer for details.
+	d, err := base64.StdEncoding.DecodeString(pem)
+	if err == nil {
+		return d
+	}
+	return []byte(pem)
+}
+
+func (spec *TLSSpec) tlsConfig() (*tls.Config, error) {
+	var certificates []tls.Certificate
+
+	for k, v := range spec.Certs {
+		secret, exists := spec.Keys[k]
+		if !exists {
+			return nil, fmt.Errorf("certs %s hasn't secret corresponded to it", k)
+		}
+
+		cert, err := tls.X509KeyPair(tryDecodeBase64Pem(v), tryDecodeBase64Pem(secret))
+		if err != nil {
+			return nil, fmt.Errorf("generate x509 key pair for %s failed: %s ", k, err)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("none valid certs and secret")
+	}
+
+	tlsConf := &tls.Config{Certificates: certificates}
+
+	// verify the client certificates if the root certificate is provided.
+	if spec.CaCertBase64 != "" {
+		rootCertPem, _ := base64.StdEncoding.DecodeString(spec.CaCertBase64)
+		certPool := x509.NewCertPool()
+		certPool.AppendCertsFromPEM(rootCertPem)
+
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConf.ClientCAs = certPool
+	}
+
+	return tlsConf, nil
+}
+
+// match returns whether the rule matches the server name.
+func (rule *Rule) match(serverName string) bool {
+	if len(rule.Hosts) == 0 {
+		return true
+	}
+
+	serverName = strings.ToLower(serverName)
+	for _, host := range rule.Hosts {
+		host = strings.ToLower(host)
+		if host == serverName {
+			return true
+		}
+		if strings.HasPrefix(host, "*.") && len(serverName) > len(host)-1 &&
+			strings.HasSuffix(serverName, host[1:]) {
+			return true
+		}
+	}
+
+	return false
+}
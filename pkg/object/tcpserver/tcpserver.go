@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpserver
+
+import (
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/supervisor"
+)
+
+const (
+	// Category is the category of TCPServer.
+	Category = supervisor.CategoryTrafficGate
+
+	// Kind is the kind of TCPServer.
+	Kind = "TCPServer"
+)
+
+var _ supervisor.TrafficObject = (*TCPServer)(nil)
+
+func init() {
+	supervisor.Register(&TCPServer{})
+}
+
+type (
+	// TCPServer is Object TCPServer.
+	TCPServer struct {
+		runtime *runtime
+	}
+)
+
+// Category returns the category of TCPServer.
+func (ts *TCPServer) Category() supervisor.ObjectCategory {
+	return Category
+}
+
+// Kind returns the kind of TCPServer.
+func (ts *TCPServer) Kind() string {
+	return Kind
+}
+
+// DefaultSpec returns the default spec of TCPServer.
+func (ts *TCPServer) DefaultSpec() interface{} {
+	return &Spec{
+		MaxConnections: 10240,
+		ConnectTimeout: "5s",
+	}
+}
+
+// Init initializes TCPServer.
+func (ts *TCPServer) Init(superSpec *supervisor.Spec, muxMapper context.MuxMapper) {
+	ts.runtime = newRuntime(superSpec.Name())
+	ts.runtime.reload(superSpec.Super(), superSpec.ObjectSpec().(*Spec))
+}
+
+// Inherit inherits previous generation of TCPServer, the connections
+// established by the previous generation are kept.
+func (ts *TCPServer) Inherit(superSpec *supervisor.Spec, previousGeneration supervisor.Object, muxMapper context.MuxMapper) {
+	ts.runtime = previousGeneration.(*TCPServer).runtime
+	ts.runtime.reload(superSpec.Super(), superSpec.ObjectSpec().(*Spec))
+}
+
+// Status is the wrapper of runtime's Status.
+func (ts *TCPServer) Status() *supervisor.Status {
+	return &supervisor.Status{
+		ObjectStatus: ts.runtime.status(),
+	}
+}
+
+// Close closes TCPServer.
+func (ts *TCPServer) Close() {
+	ts.runtime.close()
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+func init() {
+	logger.InitNop()
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	yamlConfig := `
+name: tcp-server-test
+kind: TCPServer
+port: 13306
+rules:
+- pool:
+    servers:
+    - url: tcp://127.0.0.1:3306
+`
+	superSpec, err := supervisor.NewSpec(yamlConfig)
+	assert.NoError(err)
+	spec := superSpec.ObjectSpec().(*Spec)
+	assert.Equal(uint32(10240), spec.MaxConnections)
+	assert.Equal(5*time.Second, spec.connectTimeout())
+
+	yamlConfig = `
+name: tcp-server-test
+kind: TCPServer
+port: 13306
+rules: []
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.Error(err)
+
+	// hosts require tls.
+	yamlConfig = `
+name: tcp-server-test
+kind: TCPServer
+port: 13306
+rules:
+- hosts: [db.example.com]
+  pool:
+    servers:
+    - url: tcp://127.0.0.1:3306
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.Error(err)
+
+	yamlConfig = `
+name: tcp-server-test
+kind: TCPServer
+port: 13306
+tls:
+  passthrough: true
+rules:
+- hosts: [db.example.com]
+  pool:
+    servers:
+    - url: tcp://127.0.0.1:3306
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.NoError(err)
+
+	// certificates are required to terminate tls.
+	yamlConfig = `
+name: tcp-server-test
+kind: TCPServer
+port: 13306
+tls: {}
+rules:
+- pool:
+    servers:
+    - url: tcp://127.0.0.1:3306
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.Error(err)
+}
+
+func TestRuleMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &Rule{}
+	assert.True(rule.match(""))
+	assert.True(rule.match("www.example.com"))
+
+	rule.Hosts = []string{"db.example.com", "*.example.org"}
+	assert.True(rule.match("db.example.com"))
+	assert.True(rule.match("DB.Example.com"))
+	assert.True(rule.match("a.example.org"))
+	assert.True(rule.match("a.b.example.org"))
+	assert.False(rule.match("example.org"))
+	assert.False(rule.match("www.example.com"))
+	assert.False(rule.match(""))
+}
+
+func TestPeekClientHello(t *testing.T) {
+	assert := assert.New(t)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go tls.Client(client, &tls.Config{ServerName: "db.example.com", InsecureSkipVerify: true}).Handshake()
+
+	hello, conn, err := peekClientHello(server)
+	assert.NoError(err)
+	assert.Equal("db.example.com", hello.ServerName)
+
+	// the client hello message is replayed.
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	assert.NoError(err)
+	assert.Equal(byte(0x16), buf[0])
+}
+
+func startEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l
+}
+
+func freePort(t *testing.T) uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestTCPServer(t *testing.T) {
+	assert := assert.New(t)
+
+	upstream := startEchoServer(t)
+	defer upstream.Close()
+
+	spec := &Spec{
+		Port:           freePort(t),
+		MaxConnections: 1,
+		Rules: []*Rule{{
+			Pool: &proxy.Layer4ServerPoolSpec{
+				Servers: []*proxy.Server{{URL: "tcp://" + upstream.Addr().String()}},
+			},
+		}},
+	}
+
+	r := newRuntime("tcp-server-test")
+	r.reload(nil, spec)
+	defer r.close()
+	assert.Equal(stateRunning, r.status().State)
+
+	addr := fmt.Sprintf("127.0.0.1:%d", spec.Port)
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	assert.NoError(err)
+	assert.Equal("hello", string(buf))
+
+	// the connection limit is reached.
+	conn2, err := net.Dial("tcp", addr)
+	assert.NoError(err)
+	conn2.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn2.Read(buf)
+	assert.Equal(io.EOF, err)
+	conn2.Close()
+
+	// the connection is blocked by the ip filter.
+	spec2 := *spec
+	spec2.IPFilter = &ipfilter.Spec{BlockIPs: []string{"127.0.0.1"}}
+	r.reload(nil, &spec2)
+
+	conn3, err := net.Dial("tcp", addr)
+	assert.NoError(err)
+	conn3.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn3.Read(buf)
+	assert.Equal(io.EOF, err)
+	conn3.Close()
+
+	// the established connection is not affected by the reloading.
+	_, err = conn.Write([]byte("world"))
+	assert.NoError(err)
+	_, err = io.ReadFull(conn, buf)
+	assert.NoError(err)
+	assert.Equal("world", string(buf))
+
+	s := r.status()
+	assert.Equal(int64(1), s.ActiveConnections)
+	assert.Equal(int64(2), s.RejectedConnections)
+}
+
+func TestPipeIdleTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	client, proxyClient := net.Pipe()
+	proxyUpstream, upstream := net.Pipe()
+	defer client.Close()
+	defer upstream.Close()
+
+	done := make(chan struct{})
+	go func() {
+		pipe(proxyClient, proxyUpstream, 100*time.Millisecond)
+		close(done)
+	}()
+
+	go io.Copy(io.Discard, upstream)
+	client.Write([]byte(strings.Repeat("a", 10)))
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.Fail("pipe is not closed after idle timeout")
+	}
+}
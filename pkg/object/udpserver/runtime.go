@@ -0,0 +1,479 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpserver
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/graceupdate"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+const (
+	defaultIdleTimeout = 60 * time.Second
+
+	// listenRetryInterval is the interval to retry listening on the port
+	// after failures, e.g. the port is in use.
+	listenRetryInterval = 10 * time.Second
+
+	// maxDatagramSize is the max size of UDP datagrams.
+	maxDatagramSize = 64 * 1024
+
+	// maxPendingDatagrams is the max number of datagrams queued for a
+	// client while its session is being created.
+	maxPendingDatagrams = 16
+
+	stateNil     stateType = "nil"
+	stateFailed  stateType = "failed"
+	stateRunning stateType = "running"
+	stateClosed  stateType = "closed"
+)
+
+var (
+	// portsLock and ports record the ports listened by the UDPServers of
+	// this process and their names, SO_REUSEPORT does not reject two of
+	// them listening on the same port in the new process of a graceful
+	// update, so they are checked here.
+	portsLock sync.Mutex
+	ports     = map[uint16]string{}
+)
+
+type (
+	stateType string
+
+	// config is the immutable runtime configuration generated from the
+	// spec, sessions use the config when they are created.
+	config struct {
+		spec        *Spec
+		idleTimeout time.Duration
+		ipFilter    *ipfilter.IPFilter
+		pool        *proxy.Layer4ServerPool
+	}
+
+	// session forwards the datagrams between a client and the upstream
+	// server chosen for the client.
+	session struct {
+		// lastActive is accessed atomically, it must be the first field
+		// to guarantee the 64-bit alignment.
+		lastActive int64
+
+		key        string
+		clientAddr *net.UDPAddr
+		upstream   *net.UDPConn
+		done       func()
+		closeOnce  sync.Once
+	}
+
+	runtime struct {
+		// statistics accessed atomically, they must be the first fields
+		// to guarantee the 64-bit alignment.
+		totalSessions   int64
+		failedSessions  int64
+		rejectedPackets int64
+		bytesIn         int64
+		bytesOut        int64
+
+		name   string
+		config atomic.Value // *config
+		done   chan struct{}
+
+		// mu protects the fields below.
+		mu       sync.Mutex
+		conn     *net.UDPConn
+		port     uint16
+		sessions map[string]*session
+		// pending holds the datagrams of clients whose sessions are being
+		// created, the key is the same as sessions.
+		pending map[string][][]byte
+		state   stateType
+		err     error
+	}
+
+	// Status contains all status generated by runtime, for displaying to users.
+	Status struct {
+		Name  string    `json:"name"`
+		State stateType `json:"state"`
+		Error string    `json:"error,omitempty"`
+
+		ActiveSessions  int64 `json:"activeSessions"`
+		TotalSessions   int64 `json:"totalSessions"`
+		FailedSessions  int64 `json:"failedSessions"`
+		RejectedPackets int64 `json:"rejectedPackets"`
+		BytesIn         int64 `json:"bytesIn"`
+		BytesOut        int64 `json:"bytesOut"`
+	}
+)
+
+func newRuntime(name string) *runtime {
+	r := &runtime{
+		name:     name,
+		done:     make(chan struct{}),
+		sessions: map[string]*session{},
+		pending:  map[string][][]byte{},
+		state:    stateNil,
+	}
+
+	go r.retryListen()
+
+	return r
+}
+
+func newConfig(super *supervisor.Supervisor, name string, spec *Spec) *config {
+	c := &config{
+		spec:        spec,
+		idleTimeout: spec.idleTimeout(),
+		pool:        proxy.NewLayer4ServerPool(super, spec.Pool, name),
+	}
+
+	if spec.IPFilter != nil {
+		c.ipFilter = ipfilter.New(spec.IPFilter)
+	}
+
+	return c
+}
+
+func (r *runtime) getConfig() *config {
+	c, _ := r.config.Load().(*config)
+	return c
+}
+
+// reload applies the new spec, the connection is kept if the port is not
+// changed, so the established sessions are not affected.
+func (r *runtime) reload(super *supervisor.Supervisor, spec *Spec) {
+	next := newConfig(super, r.name, spec)
+
+	r.mu.Lock()
+	prev := r.getConfig()
+	r.config.Store(next)
+	if r.conn != nil && prev.spec.Port != spec.Port {
+		r._closeConn()
+	}
+	if r.conn == nil {
+		r._listen()
+	}
+	r.mu.Unlock()
+
+	if prev != nil {
+		prev.pool.Close()
+	}
+}
+
+// _listen listens on the port, the caller must hold the lock.
+func (r *runtime) _listen() {
+	port := r.getConfig().spec.Port
+
+	fail := func(err error) {
+		logger.Errorf("udp server %s listen on port %d failed: %v", r.name, port, err)
+		r.state, r.err = stateFailed, err
+	}
+
+	portsLock.Lock()
+	owner, ok := ports[port]
+	if ok && owner != r.name {
+		portsLock.Unlock()
+		fail(fmt.Errorf("port %d is used by udp server %s", port, owner))
+		return
+	}
+	ports[port] = r.name
+	portsLock.Unlock()
+
+	conn, err := graceupdate.ListenUDP(fmt.Sprintf(":%d", port))
+	if err != nil {
+		releasePort(port, r.name)
+		fail(err)
+		return
+	}
+
+	r.conn, r.port = conn, port
+	r.state, r.err = stateRunning, nil
+	go r.serve(conn)
+}
+
+// _closeConn closes the connection and releases its port, the caller
+// must hold the lock.
+func (r *runtime) _closeConn() {
+	r.conn.Close()
+	r.conn = nil
+	releasePort(r.port, r.name)
+}
+
+// releasePort releases the port if it is used by the UDPServer.
+func releasePort(port uint16, name string) {
+	portsLock.Lock()
+	defer portsLock.Unlock()
+
+	if ports[port] == name {
+		delete(ports, port)
+	}
+}
+
+func (r *runtime) retryListen() {
+	ticker := time.NewTicker(listenRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.state == stateFailed {
+				r._listen()
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *runtime) serve(conn *net.UDPConn) {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, clientAddr, err := conn.ReadFromUDP(buf)
+		if err == nil {
+			r.handleDatagram(conn, clientAddr, buf[:n])
+			continue
+		}
+
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			continue
+		}
+
+		r.mu.Lock()
+		// the error is expected if the connection has been closed.
+		if r.conn == conn {
+			logger.Errorf("udp server %s read failed: %v", r.name, err)
+			r._closeConn()
+			r.state, r.err = stateFailed, err
+		}
+		r.mu.Unlock()
+		return
+	}
+}
+
+func (r *runtime) handleDatagram(conn *net.UDPConn, clientAddr *net.UDPAddr, data []byte) {
+	c := r.getConfig()
+	if c.ipFilter != nil && !c.ipFilter.Allow(clientAddr.IP.String()) {
+		atomic.AddInt64(&r.rejectedPackets, 1)
+		return
+	}
+
+	key := clientAddr.String()
+
+	r.mu.Lock()
+	s := r.sessions[key]
+	if s == nil {
+		accepted := r._queueDatagram(conn, c, key, clientAddr, data)
+		r.mu.Unlock()
+		if !accepted {
+			atomic.AddInt64(&r.rejectedPackets, 1)
+		}
+		return
+	}
+	r.mu.Unlock()
+
+	r.forward(s, data)
+}
+
+// _queueDatagram queues the datagram of a client without session, and
+// starts creating the session for the first datagram. It returns false if
+// the datagram is rejected. The caller must hold the lock.
+func (r *runtime) _queueDatagram(conn *net.UDPConn, c *config, key string, clientAddr *net.UDPAddr, data []byte) bool {
+	if r.state == stateClosed {
+		return false
+	}
+
+	// data is a part of the read buffer, which is reused.
+	data = append([]byte(nil), data...)
+
+	if queue, ok := r.pending[key]; ok {
+		if len(queue) >= maxPendingDatagrams {
+			return false
+		}
+		r.pending[key] = append(queue, data)
+		return true
+	}
+
+	if c.spec.MaxSessions > 0 && len(r.sessions)+len(r.pending) >= int(c.spec.MaxSessions) {
+		return false
+	}
+
+	r.pending[key] = [][]byte{data}
+	go r.createSession(conn, c, key, clientAddr)
+	return true
+}
+
+// createSession creates the session of a client, and forwards the queued
+// datagrams of the client. It runs in its own goroutine, because choosing
+// and dialing the upstream server may be slow, e.g. it needs a DNS lookup,
+// and it must not block the datagrams of other clients.
+func (r *runtime) createSession(conn *net.UDPConn, c *config, key string, clientAddr *net.UDPAddr) {
+	atomic.AddInt64(&r.totalSessions, 1)
+	s, err := newSession(c.pool, key, clientAddr)
+
+	r.mu.Lock()
+	queue := r.pending[key]
+	delete(r.pending, key)
+	if err == nil && (r.state == stateClosed || r.conn != conn) {
+		err = fmt.Errorf("server is closed or is listening on another port")
+	}
+	if err != nil {
+		r.mu.Unlock()
+		if s != nil {
+			s.close()
+		}
+		logger.Warnf("udp server %s: create session for %s failed: %v", r.name, key, err)
+		atomic.AddInt64(&r.failedSessions, 1)
+		atomic.AddInt64(&r.rejectedPackets, int64(len(queue)))
+		return
+	}
+	r.sessions[key] = s
+	r.mu.Unlock()
+
+	go r.serveSession(conn, s, c.idleTimeout)
+	for _, data := range queue {
+		r.forward(s, data)
+	}
+}
+
+// forward forwards a datagram from the client to the upstream server.
+func (r *runtime) forward(s *session, data []byte) {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+	if _, err := s.upstream.Write(data); err != nil {
+		logger.Debugf("udp server %s: write to %s failed: %v", r.name, s.upstream.RemoteAddr(), err)
+		return
+	}
+	atomic.AddInt64(&r.bytesIn, int64(len(data)))
+}
+
+func newSession(pool *proxy.Layer4ServerPool, key string, clientAddr *net.UDPAddr) (*session, error) {
+	addr, done, err := pool.ChooseServer(key)
+	if err != nil {
+		return nil, err
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	upstream, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	return &session{
+		lastActive: time.Now().UnixNano(),
+		key:        key,
+		clientAddr: clientAddr,
+		upstream:   upstream,
+		done:       done,
+	}, nil
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		s.upstream.Close()
+		s.done()
+	})
+}
+
+// serveSession forwards the datagrams from the upstream to the client,
+// until the session is idle for idleTimeout.
+func (r *runtime) serveSession(conn *net.UDPConn, s *session, idleTimeout time.Duration) {
+	defer func() {
+		r.mu.Lock()
+		if r.sessions[s.key] == s {
+			delete(r.sessions, s.key)
+		}
+		r.mu.Unlock()
+		s.close()
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		s.upstream.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, err := s.upstream.Read(buf)
+		if err != nil {
+			// the client is active, keep reading.
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				idle := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&s.lastActive))
+				if idle < idleTimeout {
+					continue
+				}
+			}
+			return
+		}
+
+		atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+		if _, err = conn.WriteToUDP(buf[:n], s.clientAddr); err != nil {
+			logger.Debugf("udp server %s: write to %s failed: %v", r.name, s.key, err)
+			return
+		}
+		atomic.AddInt64(&r.bytesOut, int64(n))
+	}
+}
+
+func (r *runtime) status() *Status {
+	r.mu.Lock()
+	s := &Status{
+		Name:           r.name,
+		State:          r.state,
+		ActiveSessions: int64(len(r.sessions)),
+	}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	r.mu.Unlock()
+
+	s.TotalSessions = atomic.LoadInt64(&r.totalSessions)
+	s.FailedSessions = atomic.LoadInt64(&r.failedSessions)
+	s.RejectedPackets = atomic.LoadInt64(&r.rejectedPackets)
+	s.BytesIn = atomic.LoadInt64(&r.bytesIn)
+	s.BytesOut = atomic.LoadInt64(&r.bytesOut)
+	return s
+}
+
+// close closes the connection and all sessions.
+func (r *runtime) close() {
+	close(r.done)
+
+	r.mu.Lock()
+	r.state = stateClosed
+	if r.conn != nil {
+		r._closeConn()
+	}
+	for _, s := range r.sessions {
+		s.close()
+	}
+	r.pending = map[string][][]byte{}
+	r.mu.Unlock()
+
+	if c := r.getConfig(); c != nil {
+		c.pool.Close()
+	}
+}
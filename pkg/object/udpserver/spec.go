@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpserver
+
+import (
+	"time"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+type (
+	// Spec describes the UDPServer.
+	Spec struct {
+		Port        uint16                      `json:"port" jsonschema:"required,minimum=1"`
+		MaxSessions uint32                      `json:"maxSessions" jsonschema:"omitempty,minimum=1"`
+		IdleTimeout string                      `json:"idleTimeout" jsonschema:"omitempty,format=duration"`
+		IPFilter    *ipfilter.Spec              `json:"ipFilter,omitempty" jsonschema:"omitempty"`
+		Pool        *proxy.Layer4ServerPoolSpec `json:"pool" jsonschema:"required"`
+	}
+)
+
+func (spec *Spec) idleTimeout() time.Duration {
+	d, _ := time.ParseDuration(spec.IdleTimeout)
+	if d <= 0 {
+		d = defaultIdleTimeout
+	}
+	return d
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpserver
+
+import (
+	"github.com/megaease/easegress/pkg/context"
+	"github.com/megaease/easegress/pkg/supervisor"
+)
+
+const (
+	// Category is the category of UDPServer.
+	Category = supervisor.CategoryTrafficGate
+
+	// Kind is the kind of UDPServer.
+	Kind = "UDPServer"
+)
+
+var _ supervisor.TrafficObject = (*UDPServer)(nil)
+
+func init() {
+	supervisor.Register(&UDPServer{})
+}
+
+type (
+	// UDPServer is Object UDPServer.
+	UDPServer struct {
+		runtime *runtime
+	}
+)
+
+// Category returns the category of UDPServer.
+func (us *UDPServer) Category() supervisor.ObjectCategory {
+	return Category
+}
+
+// Kind returns the kind of UDPServer.
+func (us *UDPServer) Kind() string {
+	return Kind
+}
+
+// DefaultSpec returns the default spec of UDPServer.
+func (us *UDPServer) DefaultSpec() interface{} {
+	return &Spec{
+		MaxSessions: 10240,
+		IdleTimeout: "60s",
+	}
+}
+
+// Init initializes UDPServer.
+func (us *UDPServer) Init(superSpec *supervisor.Spec, muxMapper context.MuxMapper) {
+	us.runtime = newRuntime(superSpec.Name())
+	us.runtime.reload(superSpec.Super(), superSpec.ObjectSpec().(*Spec))
+}
+
+// Inherit inherits previous generation of UDPServer, the sessions
+// established by the previous generation are kept.
+func (us *UDPServer) Inherit(superSpec *supervisor.Spec, previousGeneration supervisor.Object, muxMapper context.MuxMapper) {
+	us.runtime = previousGeneration.(*UDPServer).runtime
+	us.runtime.reload(superSpec.Super(), superSpec.ObjectSpec().(*Spec))
+}
+
+// Status is the wrapper of runtime's Status.
+func (us *UDPServer) Status() *supervisor.Status {
+	return &supervisor.Status{
+		ObjectStatus: us.runtime.status(),
+	}
+}
+
+// Close closes UDPServer.
+func (us *UDPServer) Close() {
+	us.runtime.close()
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udpserver
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/filters/proxy"
+	"github.com/megaease/easegress/pkg/logger"
+	"github.com/megaease/easegress/pkg/supervisor"
+	"github.com/megaease/easegress/pkg/util/ipfilter"
+)
+
+func init() {
+	logger.InitNop()
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	yamlConfig := `
+name: udp-server-test
+kind: UDPServer
+port: 10053
+pool:
+  servers:
+  - url: udp://127.0.0.1:53
+`
+	superSpec, err := supervisor.NewSpec(yamlConfig)
+	assert.NoError(err)
+	spec := superSpec.ObjectSpec().(*Spec)
+	assert.Equal(uint32(10240), spec.MaxSessions)
+	assert.Equal(60*time.Second, spec.idleTimeout())
+
+	yamlConfig = `
+name: udp-server-test
+kind: UDPServer
+port: 10053
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.Error(err)
+
+	yamlConfig = `
+name: udp-server-test
+kind: UDPServer
+port: 10053
+pool:
+  servers:
+  - url: udp://127.0.0.1:53
+  loadBalance:
+    policy: headerHash
+`
+	_, err = supervisor.NewSpec(yamlConfig)
+	assert.Error(err)
+}
+
+func startEchoServer(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP(buf[:n], addr)
+		}
+	}()
+
+	return conn
+}
+
+func freePort(t *testing.T) uint16 {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func TestUDPServer(t *testing.T) {
+	assert := assert.New(t)
+
+	upstream := startEchoServer(t)
+	defer upstream.Close()
+
+	spec := &Spec{
+		Port:        freePort(t),
+		MaxSessions: 1,
+		IdleTimeout: "200ms",
+		Pool: &proxy.Layer4ServerPoolSpec{
+			Servers: []*proxy.Server{{URL: "udp://" + upstream.LocalAddr().String()}},
+		},
+	}
+
+	r := newRuntime("udp-server-test")
+	r.reload(nil, spec)
+	defer r.close()
+	assert.Equal(stateRunning, r.status().State)
+
+	addr := fmt.Sprintf("127.0.0.1:%d", spec.Port)
+	conn, err := net.Dial("udp", addr)
+	assert.NoError(err)
+	defer conn.Close()
+
+	buf := make([]byte, 16)
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write([]byte("hello"))
+		assert.NoError(err)
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, err := conn.Read(buf)
+		assert.NoError(err)
+		assert.Equal("hello", string(buf[:n]))
+	}
+
+	s := r.status()
+	assert.Equal(int64(1), s.ActiveSessions)
+	assert.Equal(int64(1), s.TotalSessions)
+	assert.Equal(int64(15), s.BytesIn)
+	assert.Equal(int64(15), s.BytesOut)
+
+	// the session limit is reached.
+	conn2, err := net.Dial("udp", addr)
+	assert.NoError(err)
+	defer conn2.Close()
+	conn2.Write([]byte("hello"))
+	conn2.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn2.Read(buf)
+	assert.Error(err)
+
+	// the session is closed after idle timeout.
+	assert.Eventually(func() bool {
+		return r.status().ActiveSessions == 0
+	}, 3*time.Second, 50*time.Millisecond)
+
+	// the datagrams are blocked by the ip filter.
+	spec2 := *spec
+	spec2.IPFilter = &ipfilter.Spec{BlockIPs: []string{"127.0.0.1"}}
+	r.reload(nil, &spec2)
+
+	conn.Write([]byte("hello"))
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(buf)
+	assert.Error(err)
+	assert.Equal(int64(0), r.status().ActiveSessions)
+}
+
+func TestPortConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Spec{
+		Port: freePort(t),
+		Pool: &proxy.Layer4ServerPoolSpec{
+			Servers: []*proxy.Server{{URL: "udp://127.0.0.1:9095"}},
+		},
+	}
+
+	r1 := newRuntime("udp-server-1")
+	r1.reload(nil, spec)
+	assert.Equal(stateRunning, r1.status().State)
+
+	r2 := newRuntime("udp-server-2")
+	r2.reload(nil, spec)
+	defer r2.close()
+	s := r2.status()
+	assert.Equal(stateFailed, s.State)
+	assert.Contains(s.Error, "udp-server-1")
+
+	// the port is available after the first server is closed.
+	r1.close()
+	r2.mu.Lock()
+	r2._listen()
+	r2.mu.Unlock()
+	assert.Equal(stateRunning, r2.status().State)
+}
+
+func TestQueueDatagram(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &runtime{
+		sessions: map[string]*session{},
+		pending:  map[string][][]byte{},
+		state:    stateRunning,
+	}
+	c := &config{spec: &Spec{MaxSessions: 2}}
+
+	// the session of the client is being created.
+	key := "127.0.0.1:10000"
+	r.pending[key] = [][]byte{}
+
+	buf := []byte("hello")
+	for i := 0; i < maxPendingDatagrams; i++ {
+		assert.True(r._queueDatagram(nil, c, key, nil, buf))
+	}
+	assert.False(r._queueDatagram(nil, c, key, nil, buf))
+
+	// the queued datagrams are copied from the read buffer.
+	copy(buf, "world")
+	assert.Equal("hello", string(r.pending[key][0]))
+
+	// sessions being created count for the session limit.
+	r.pending["127.0.0.1:10001"] = [][]byte{}
+	assert.False(r._queueDatagram(nil, c, "127.0.0.1:10002", nil, buf))
+
+	r.state = stateClosed
+	assert.False(r._queueDatagram(nil, c, key, nil, buf))
+}
@@ -69,6 +69,11 @@ type Options struct {
 	Name                     string            `yaml:"name" env:"EG_NAME"`
 	Labels                   map[string]string `yaml:"labels" env:"EG_LABELS"`
 	APIAddr                  string            `yaml:"api-addr"`
+	APIAuthConfigFile        string            `yaml:"api-auth-config-file"`
+	APICertFile              string            `yaml:"api-cert-file"`
+	APIKeyFile               string            `yaml:"api-key-file"`
+	APIClientCAFile          string            `yaml:"api-client-ca-file"`
+	APIMinTLSVersion         string            `yaml:"api-min-tls-version"`
 	Debug                    bool              `yaml:"debug"`
 	DisableAccessLog         bool              `yaml:"disable-access-log"`
 	InitialObjectConfigFiles []string          `yaml:"initial-object-config-files"`
@@ -138,6 +143,11 @@ func New() *Options {
 	opt.flags.BoolVar(&opt.UseStandaloneEtcd, "use-standalone-etcd", false, "Use standalone etcd instead of embedded .")
 	addClusterVars(opt)
 	opt.flags.StringVar(&opt.APIAddr, "api-addr", "localhost:2381", "Address([host]:port) to listen on for administration traffic.")
+	opt.flags.StringVar(&opt.APIAuthConfigFile, "api-auth-config-file", "", "Path to the authentication and authorization config file(yaml format) of the administration API, the API is open to anyone if not specified.")
+	opt.flags.StringVar(&opt.APICertFile, "api-cert-file", "", "Path to the certificate file of the administration API, the API is served over HTTPS if specified, the certificate is reloaded when the file changes.")
+	opt.flags.StringVar(&opt.APIKeyFile, "api-key-file", "", "Path to the private key file of the administration API.")
+	opt.flags.StringVar(&opt.APIClientCAFile, "api-client-ca-file", "", "Path to the CA file to verify client certificates of the administration API.")
+	opt.flags.StringVar(&opt.APIMinTLSVersion, "api-min-tls-version", "1.2", "Minimum TLS version of the administration API (1.0, 1.1, 1.2, 1.3).")
 	opt.flags.BoolVar(&opt.Debug, "debug", false, "Flag to set lowest log level from INFO downgrade DEBUG.")
 	opt.flags.StringSliceVar(&opt.InitialObjectConfigFiles, "initial-object-config-files", nil, "List of configuration files for initial objects, these objects will be created at startup if not already exist.")
 	opt.flags.StringVar(&opt.ObjectsDumpInterval, "objects-dump-interval", "", "The time interval to dump running objects config, for example: 30m")
@@ -331,6 +341,18 @@ func (opt *Options) validate() error {
 		return fmt.Errorf("invalid api-url: %v", err)
 	}
 
+	if (opt.APICertFile == "") != (opt.APIKeyFile == "") {
+		return fmt.Errorf("api-cert-file and api-key-file must be specified together")
+	}
+	if opt.APIClientCAFile != "" && opt.APICertFile == "" {
+		return fmt.Errorf("api-client-ca-file requires api-cert-file")
+	}
+	switch opt.APIMinTLSVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("invalid api-min-tls-version: %s", opt.APIMinTLSVersion)
+	}
+
 	// dirs
 	if opt.HomeDir == "" {
 		return fmt.Errorf("empty home-dir")
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpcprot implements the gRPC protocol.
+//
+// gRPC runs on top of HTTP/2, so the request and response of this package
+// are wrappers of the HTTP ones, that's, they could be processed by all
+// HTTP filters, and this package provides access to the gRPC specific
+// information, like service, method, metadata, trailers and status.
+package grpcprot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/megaease/easegress/pkg/protocols"
+)
+
+const (
+	// ContentType is the content type of gRPC requests and responses.
+	ContentType = "application/grpc"
+
+	// HeaderStatus is the header or trailer key of the gRPC status code.
+	HeaderStatus = "Grpc-Status"
+	// HeaderMessage is the header or trailer key of the gRPC status message.
+	HeaderMessage = "Grpc-Message"
+
+	// prefixSize is the size of the length-prefix of a gRPC message:
+	// 1 byte compressed flag and 4 bytes message length.
+	prefixSize = 5
+)
+
+func init() {
+	protocols.Register("grpc", &Protocol{})
+}
+
+// Protocol implements protocols.Protocol for gRPC.
+type Protocol struct{}
+
+var _ protocols.Protocol = (*Protocol)(nil)
+
+// IsGRPCContentType returns whether the content type is a gRPC one,
+// that's, "application/grpc" or "application/grpc+{subtype}".
+func IsGRPCContentType(ct string) bool {
+	if !strings.HasPrefix(ct, ContentType) {
+		return false
+	}
+	ct = ct[len(ContentType):]
+	return ct == "" || ct[0] == '+' || ct[0] == ';'
+}
+
+// IsGRPCRequest returns whether the HTTP request is a gRPC request.
+func IsGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && IsGRPCContentType(r.Header.Get("Content-Type"))
+}
+
+// CodeFromHTTPStatus converts an HTTP status code to a gRPC status code,
+// it follows the mapping of the gRPC specification, and adds several
+// codes used by Easegress.
+//
+// Reference: https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func CodeFromHTTPStatus(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusRequestTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case 499: // client closed request
+		return codes.Canceled
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// parseCode parses the value of a gRPC status header, ok is false if the
+// value is empty or invalid.
+func parseCode(v string) (code codes.Code, ok bool) {
+	if v == "" {
+		return codes.Unknown, false
+	}
+	c, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return codes.Unknown, false
+	}
+	return codes.Code(c), true
+}
+
+// encodeMessage percent encodes the status message as required by the
+// gRPC specification.
+func encodeMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// decodeMessage decodes a percent encoded status message, the message is
+// returned as is if it is invalid.
+func decodeMessage(msg string) string {
+	if v, err := url.PathUnescape(msg); err == nil {
+		return v
+	}
+	return msg
+}
+
+// isReservedHeader returns whether the header is a reserved one, which
+// is used by the gRPC protocol and is not a part of the metadata.
+func isReservedHeader(key string) bool {
+	switch key {
+	case "content-type", "content-length", "te", "trailer", "connection":
+		return true
+	}
+	return strings.HasPrefix(key, "grpc-")
+}
+
+// toMetadata converts an HTTP header to gRPC metadata, reserved headers
+// are excluded.
+func toMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, v := range h {
+		k = strings.ToLower(k)
+		if isReservedHeader(k) {
+			continue
+		}
+		md[k] = append(md[k], v...)
+	}
+	return md
+}
+
+// frame adds the length-prefix to an uncompressed message.
+func frame(msg []byte) []byte {
+	data := make([]byte, prefixSize+len(msg))
+	binary.BigEndian.PutUint32(data[1:], uint32(len(msg)))
+	copy(data[prefixSize:], msg)
+	return data
+}
+
+// CreateRequest creates a new request. The input argument should be a
+// *http.Request or nil.
+//
+// The caller need to handle the close of the body of the input request,
+// if it need to be closed.
+func (p *Protocol) CreateRequest(req interface{}) (protocols.Request, error) {
+	r, _ := req.(*http.Request)
+	return NewRequest(r)
+}
+
+// CreateResponse creates a new response. The input argument should be a
+// *http.Response or nil.
+//
+// The caller need to handle the close of the body of the input response,
+// if it need to be closed.
+func (p *Protocol) CreateResponse(resp interface{}) (protocols.Response, error) {
+	r, _ := resp.(*http.Response)
+	return NewResponse(r)
+}
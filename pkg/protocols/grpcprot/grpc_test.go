@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcprot
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestIsGRPCContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsGRPCContentType("application/grpc"))
+	assert.True(IsGRPCContentType("application/grpc+proto"))
+	assert.True(IsGRPCContentType("application/grpc;charset=utf-8"))
+	assert.False(IsGRPCContentType("application/grpc-web"))
+	assert.False(IsGRPCContentType("application/json"))
+	assert.False(IsGRPCContentType(""))
+
+	stdr, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1/helloworld.Greeter/SayHello", nil)
+	stdr.Header.Set("Content-Type", ContentType)
+	assert.False(IsGRPCRequest(stdr))
+	stdr.ProtoMajor = 2
+	assert.True(IsGRPCRequest(stdr))
+}
+
+func TestCodeFromHTTPStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(codes.OK, CodeFromHTTPStatus(http.StatusOK))
+	assert.Equal(codes.Unimplemented, CodeFromHTTPStatus(http.StatusNotFound))
+	assert.Equal(codes.Unavailable, CodeFromHTTPStatus(http.StatusServiceUnavailable))
+	assert.Equal(codes.DeadlineExceeded, CodeFromHTTPStatus(http.StatusRequestTimeout))
+	assert.Equal(codes.Canceled, CodeFromHTTPStatus(499))
+	assert.Equal(codes.Unknown, CodeFromHTTPStatus(http.StatusInternalServerError))
+}
+
+func TestMessageCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	msg := "invalid argument: 100% 错误\n"
+	encoded := encodeMessage(msg)
+	assert.Equal("invalid argument: 100%25 %E9%94%99%E8%AF%AF%0A", encoded)
+	assert.Equal(msg, decodeMessage(encoded))
+	assert.Equal("bad %zz", decodeMessage("bad %zz"))
+
+	code, ok := parseCode("14")
+	assert.True(ok)
+	assert.Equal(codes.Unavailable, code)
+	_, ok = parseCode("")
+	assert.False(ok)
+	_, ok = parseCode("abc")
+	assert.False(ok)
+}
+
+func TestFrame(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]byte{0, 0, 0, 0, 3, 'a', 'b', 'c'}, frame([]byte("abc")))
+	assert.Equal([]byte{0, 0, 0, 0, 0}, frame(nil))
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcprot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/megaease/easegress/pkg/protocols"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+)
+
+// Request wraps httpprot.Request and provides access to the gRPC
+// specific information.
+type Request struct {
+	*httpprot.Request
+}
+
+var _ protocols.Request = (*Request)(nil)
+
+// NewRequest creates a new request from a standard request. If stdr is not
+// nil, FetchPayload must be called before any read of the request body.
+func NewRequest(stdr *http.Request) (*Request, error) {
+	r, err := httpprot.NewRequest(stdr)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{Request: r}, nil
+}
+
+// FromHTTPRequest wraps an HTTP request as a gRPC request, the two
+// requests share the same underlying data.
+func FromHTTPRequest(r *httpprot.Request) *Request {
+	return &Request{Request: r}
+}
+
+// splitFullMethod splits a full method name, in the format of
+// "/service/method", to service and method.
+func splitFullMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	pos := strings.LastIndexByte(fullMethod, '/')
+	if pos < 0 {
+		return "", ""
+	}
+	return fullMethod[:pos], fullMethod[pos+1:]
+}
+
+// FullMethod returns the full method name of the call, in the format of
+// "/service/method".
+func (r *Request) FullMethod() string {
+	return r.Path()
+}
+
+// Service returns the full qualified service name of the call, e.g.
+// "helloworld.Greeter".
+func (r *Request) Service() string {
+	service, _ := splitFullMethod(r.Path())
+	return service
+}
+
+// Method returns the method name of the call, e.g. "SayHello". Note it
+// shadows the Method of httpprot.Request, please use Std().Method for the
+// HTTP method.
+func (r *Request) Method() string {
+	_, method := splitFullMethod(r.Path())
+	return method
+}
+
+// Metadata returns the metadata of the call, the keys are in lower case
+// and reserved headers of the gRPC protocol are excluded. The returned
+// metadata is a copy, modifications to it are not applied to the request.
+func (r *Request) Metadata() metadata.MD {
+	return toMetadata(r.HTTPHeader())
+}
+
+// requestInfo stores the information of a request.
+type requestInfo struct {
+	Service  string              `json:"service" yaml:"service" jsonschema:"required"`
+	Method   string              `json:"method" yaml:"method" jsonschema:"required"`
+	Metadata map[string][]string `json:"metadata" yaml:"metadata" jsonschema:"omitempty"`
+
+	// Body is the serialized message, without the length-prefix.
+	Body string `json:"body" yaml:"body" jsonschema:"omitempty"`
+}
+
+// NewRequestInfo returns a new requestInfo.
+func (p *Protocol) NewRequestInfo() interface{} {
+	return &requestInfo{}
+}
+
+// BuildRequest builds and returns a request according to the given reqInfo.
+func (p *Protocol) BuildRequest(reqInfo interface{}) (protocols.Request, error) {
+	ri, ok := reqInfo.(*requestInfo)
+	if !ok {
+		return nil, fmt.Errorf("invalid request info type: %T", reqInfo)
+	}
+
+	if ri.Service == "" || ri.Method == "" {
+		return nil, fmt.Errorf("service and method are required")
+	}
+
+	url := "/" + ri.Service + "/" + ri.Method
+	stdReq, err := http.NewRequest(http.MethodPost, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %v", err)
+	}
+	stdReq.Proto, stdReq.ProtoMajor, stdReq.ProtoMinor = "HTTP/2.0", 2, 0
+
+	for k, vs := range ri.Metadata {
+		for _, v := range vs {
+			stdReq.Header.Add(k, v)
+		}
+	}
+	stdReq.Header.Set("Content-Type", ContentType)
+	stdReq.Header.Set("Te", "trailers")
+
+	req, _ := NewRequest(stdReq)
+	req.SetPayload(frame([]byte(ri.Body)))
+	return req, nil
+}
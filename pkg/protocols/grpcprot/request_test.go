@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcprot
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	stdr, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1/helloworld.Greeter/SayHello", nil)
+	stdr.Header.Set("Content-Type", ContentType)
+	stdr.Header.Set("Te", "trailers")
+	stdr.Header.Set("Grpc-Timeout", "1S")
+	stdr.Header.Add("X-Tenant", "foo")
+	stdr.Header.Add("X-Tenant", "bar")
+
+	req, err := NewRequest(stdr)
+	assert.NoError(err)
+	assert.Equal("/helloworld.Greeter/SayHello", req.FullMethod())
+	assert.Equal("helloworld.Greeter", req.Service())
+	assert.Equal("SayHello", req.Method())
+	assert.Equal(http.MethodPost, req.Std().Method)
+
+	md := req.Metadata()
+	assert.Equal(1, md.Len())
+	assert.Equal([]string{"foo", "bar"}, md.Get("x-tenant"))
+
+	stdr.URL.Path = "/invalid"
+	assert.Equal("", req.Service())
+	assert.Equal("", req.Method())
+}
+
+func TestBuildRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Protocol{}
+
+	_, err := p.BuildRequest(p.NewResponseInfo())
+	assert.Error(err)
+
+	ri := p.NewRequestInfo().(*requestInfo)
+	_, err = p.BuildRequest(ri)
+	assert.Error(err)
+
+	ri.Service = "helloworld.Greeter"
+	ri.Method = "SayHello"
+	ri.Metadata = map[string][]string{"x-tenant": {"foo"}}
+	ri.Body = "abc"
+	r, err := p.BuildRequest(ri)
+	assert.NoError(err)
+
+	req := r.(*Request)
+	assert.True(IsGRPCRequest(req.Std()))
+	assert.Equal("helloworld.Greeter", req.Service())
+	assert.Equal("SayHello", req.Method())
+	assert.Equal([]string{"foo"}, req.Metadata().Get("x-tenant"))
+
+	data, _ := io.ReadAll(req.GetPayload())
+	assert.Equal(frame([]byte("abc")), data)
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcprot
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/megaease/easegress/pkg/protocols"
+	"github.com/megaease/easegress/pkg/protocols/httpprot"
+)
+
+// Response wraps httpprot.Response and provides access to the gRPC
+// specific information.
+type Response struct {
+	*httpprot.Response
+}
+
+var _ protocols.Response = (*Response)(nil)
+
+// NewResponse creates a new response from a standard response. If stdr is
+// not nil, FetchPayload must be called before any read of the response body.
+func NewResponse(stdr *http.Response) (*Response, error) {
+	r, err := httpprot.NewResponse(stdr)
+	if err != nil {
+		return nil, err
+	}
+	if stdr == nil {
+		r.HTTPHeader().Set("Content-Type", ContentType)
+	}
+	return &Response{Response: r}, nil
+}
+
+// FromHTTPResponse wraps an HTTP response as a gRPC response, the two
+// responses share the same underlying data.
+func FromHTTPResponse(r *httpprot.Response) *Response {
+	return &Response{Response: r}
+}
+
+// Metadata returns the header metadata of the call, the keys are in lower
+// case and reserved headers of the gRPC protocol are excluded.
+func (r *Response) Metadata() metadata.MD {
+	return toMetadata(r.HTTPHeader())
+}
+
+// Trailer returns the trailer metadata of the call. If the payload is a
+// stream, the trailer is only available after the payload is fully read.
+func (r *Response) Trailer() metadata.MD {
+	return toMetadata(r.Std().Trailer)
+}
+
+// GRPCStatus returns the gRPC status of the call. The status is read from
+// the trailer, or from the header for a Trailers-Only response, and it is
+// converted from the HTTP status code if neither exists.
+//
+// If the payload is a stream and has not been fully read, the status is
+// OK unless this is a Trailers-Only response.
+func (r *Response) GRPCStatus() *status.Status {
+	stdr := r.Std()
+
+	if code, ok := parseCode(stdr.Trailer.Get(HeaderStatus)); ok {
+		return status.New(code, decodeMessage(stdr.Trailer.Get(HeaderMessage)))
+	}
+
+	if code, ok := parseCode(stdr.Header.Get(HeaderStatus)); ok {
+		return status.New(code, decodeMessage(stdr.Header.Get(HeaderMessage)))
+	}
+
+	if stdr.StatusCode != http.StatusOK {
+		return status.New(CodeFromHTTPStatus(stdr.StatusCode), http.StatusText(stdr.StatusCode))
+	}
+
+	return status.New(codes.OK, "")
+}
+
+// SetGRPCStatus sets the gRPC status of the call into the header, that's,
+// makes the response a Trailers-Only one, it should only be used for
+// responses without payload.
+func (r *Response) SetGRPCStatus(code codes.Code, msg string) {
+	r.SetStatusCode(http.StatusOK)
+
+	h := r.HTTPHeader()
+	h.Set("Content-Type", ContentType)
+	h.Set(HeaderStatus, strconv.Itoa(int(code)))
+	if msg == "" {
+		h.Del(HeaderMessage)
+	} else {
+		h.Set(HeaderMessage, encodeMessage(msg))
+	}
+}
+
+// responseInfo stores the information of a response.
+type responseInfo struct {
+	Code     int                 `json:"code" jsonschema:"omitempty,minimum=0,maximum=16"`
+	Message  string              `json:"message" jsonschema:"omitempty"`
+	Metadata map[string][]string `json:"metadata" jsonschema:"omitempty"`
+	Trailers map[string][]string `json:"trailers" jsonschema:"omitempty"`
+
+	// Body is the serialized message, without the length-prefix.
+	Body string `json:"body" jsonschema:"omitempty"`
+}
+
+// NewResponseInfo returns a new responseInfo.
+func (p *Protocol) NewResponseInfo() interface{} {
+	return &responseInfo{}
+}
+
+// BuildResponse builds and returns a response according to the given respInfo.
+func (p *Protocol) BuildResponse(respInfo interface{}) (protocols.Response, error) {
+	ri, ok := respInfo.(*responseInfo)
+	if !ok {
+		return nil, fmt.Errorf("invalid response info type: %T", respInfo)
+	}
+
+	if ri.Code < 0 || ri.Code > int(codes.Unauthenticated) {
+		return nil, fmt.Errorf("invalid code: %d", ri.Code)
+	}
+
+	resp, _ := NewResponse(nil)
+	for k, vs := range ri.Metadata {
+		for _, v := range vs {
+			resp.HTTPHeader().Add(k, v)
+		}
+	}
+
+	// a response without message is a Trailers-Only one.
+	if ri.Body == "" {
+		for k, vs := range ri.Trailers {
+			for _, v := range vs {
+				resp.HTTPHeader().Add(k, v)
+			}
+		}
+		resp.SetGRPCStatus(codes.Code(ri.Code), ri.Message)
+		return resp, nil
+	}
+
+	trailer := http.Header{}
+	for k, vs := range ri.Trailers {
+		for _, v := range vs {
+			trailer.Add(k, v)
+		}
+	}
+	trailer.Set(HeaderStatus, strconv.Itoa(ri.Code))
+	if ri.Message != "" {
+		trailer.Set(HeaderMessage, encodeMessage(ri.Message))
+	}
+	resp.Std().Trailer = trailer
+	resp.SetPayload(frame([]byte(ri.Body)))
+
+	return resp, nil
+}
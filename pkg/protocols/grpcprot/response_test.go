@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcprot
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestResponseStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	resp, err := NewResponse(nil)
+	assert.NoError(err)
+	assert.Equal(ContentType, resp.HTTPHeader().Get("Content-Type"))
+	assert.Equal(codes.OK, resp.GRPCStatus().Code())
+
+	// Trailers-Only response.
+	resp.SetGRPCStatus(codes.NotFound, "user not found")
+	assert.Equal(http.StatusOK, resp.StatusCode())
+	s := resp.GRPCStatus()
+	assert.Equal(codes.NotFound, s.Code())
+	assert.Equal("user not found", s.Message())
+
+	// status in trailer takes precedence.
+	resp.Std().Trailer = http.Header{}
+	resp.Std().Trailer.Set(HeaderStatus, "13")
+	resp.Std().Trailer.Set(HeaderMessage, "internal%20error")
+	resp.Std().Trailer.Set("X-Cost", "10ms")
+	s = resp.GRPCStatus()
+	assert.Equal(codes.Internal, s.Code())
+	assert.Equal("internal error", s.Message())
+	assert.Equal([]string{"10ms"}, resp.Trailer().Get("x-cost"))
+	assert.Equal(0, len(resp.Trailer().Get(HeaderStatus)))
+
+	// converted from HTTP status code.
+	resp, _ = NewResponse(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}})
+	assert.Equal(codes.Unavailable, resp.GRPCStatus().Code())
+}
+
+func TestBuildResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Protocol{}
+
+	_, err := p.BuildResponse(p.NewRequestInfo())
+	assert.Error(err)
+
+	ri := p.NewResponseInfo().(*responseInfo)
+	ri.Code = 100
+	_, err = p.BuildResponse(ri)
+	assert.Error(err)
+
+	// Trailers-Only response.
+	ri.Code = int(codes.PermissionDenied)
+	ri.Message = "denied"
+	ri.Metadata = map[string][]string{"x-reason": {"blocked"}}
+	r, err := p.BuildResponse(ri)
+	assert.NoError(err)
+	resp := r.(*Response)
+	assert.Equal(codes.PermissionDenied, resp.GRPCStatus().Code())
+	assert.Equal([]string{"blocked"}, resp.Metadata().Get("x-reason"))
+	assert.Equal(int64(0), resp.PayloadSize())
+
+	ri.Code = 0
+	ri.Message = ""
+	ri.Body = "abc"
+	ri.Trailers = map[string][]string{"x-cost": {"10ms"}}
+	r, err = p.BuildResponse(ri)
+	assert.NoError(err)
+	resp = r.(*Response)
+	assert.Equal("", resp.HTTPHeader().Get(HeaderStatus))
+	assert.Equal(codes.OK, resp.GRPCStatus().Code())
+	assert.Equal([]string{"10ms"}, resp.Trailer().Get("x-cost"))
+
+	data, _ := io.ReadAll(resp.GetPayload())
+	assert.Equal(frame([]byte("abc")), data)
+}
@@ -19,7 +19,9 @@ package registry
 
 import (
 	// Filters
+	_ "github.com/megaease/easegress/pkg/filters/apikey"
 	_ "github.com/megaease/easegress/pkg/filters/builder"
+	_ "github.com/megaease/easegress/pkg/filters/cache"
 	_ "github.com/megaease/easegress/pkg/filters/certextractor"
 	_ "github.com/megaease/easegress/pkg/filters/connectcontrol"
 	_ "github.com/megaease/easegress/pkg/filters/corsadaptor"
@@ -56,6 +58,8 @@ import (
 	_ "github.com/megaease/easegress/pkg/object/nacosserviceregistry"
 	_ "github.com/megaease/easegress/pkg/object/pipeline"
 	_ "github.com/megaease/easegress/pkg/object/rawconfigtrafficcontroller"
+	_ "github.com/megaease/easegress/pkg/object/tcpserver"
 	_ "github.com/megaease/easegress/pkg/object/trafficcontroller"
+	_ "github.com/megaease/easegress/pkg/object/udpserver"
 	_ "github.com/megaease/easegress/pkg/object/zookeeperserviceregistry"
 )
@@ -148,6 +148,23 @@ func ObjectKinds() []string {
 	return kinds
 }
 
+// ObjectKindOrder returns the starting order of the objects of the kind,
+// objects with smaller order must be started first, as they could be
+// depended by objects with larger order. Unknown kinds are the last.
+func ObjectKindOrder(kind string) int {
+	o, exists := objectRegistry[kind]
+	if !exists {
+		return len(objectOrderedCategories)
+	}
+
+	for i, category := range objectOrderedCategories {
+		if category == o.Category() {
+			return i
+		}
+	}
+	return len(objectOrderedCategories)
+}
+
 // TrafficObjectKinds is a map that contains all kinds of TrafficObject.
 var TrafficObjectKinds = make(map[string]struct{})
 
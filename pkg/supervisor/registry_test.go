@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/megaease/easegress/pkg/context"
+)
+
+type (
+	mockObjectSpec struct{}
+
+	mockObject struct {
+		kind     string
+		category ObjectCategory
+	}
+
+	mockController struct {
+		mockObject
+	}
+
+	mockTrafficObject struct {
+		mockObject
+	}
+)
+
+func (o *mockObject) Category() ObjectCategory { return o.category }
+func (o *mockObject) Kind() string             { return o.kind }
+func (o *mockObject) DefaultSpec() interface{} { return &mockObjectSpec{} }
+func (o *mockObject) Status() *Status          { return &Status{} }
+func (o *mockObject) Close()                   {}
+
+func (c *mockController) Init(*Spec)            {}
+func (c *mockController) Inherit(*Spec, Object) {}
+
+func (o *mockTrafficObject) Init(*Spec, context.MuxMapper)            {}
+func (o *mockTrafficObject) Inherit(*Spec, Object, context.MuxMapper) {}
+
+func init() {
+	Register(&mockController{mockObject{"MockSystemController", CategorySystemController}})
+	Register(&mockController{mockObject{"MockBusinessController", CategoryBusinessController}})
+	Register(&mockTrafficObject{mockObject{"MockPipeline", CategoryPipeline}})
+	Register(&mockTrafficObject{mockObject{"MockTrafficGate", CategoryTrafficGate}})
+}
+
+func TestObjectKindOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	kinds := []string{
+		"MockSystemController",
+		"MockBusinessController",
+		"MockPipeline",
+		"MockTrafficGate",
+		"UnknownKind",
+	}
+	for i := 1; i < len(kinds); i++ {
+		assert.Less(ObjectKindOrder(kinds[i-1]), ObjectKindOrder(kinds[i]), kinds[i])
+	}
+	assert.Equal(len(objectOrderedCategories), ObjectKindOrder("UnknownKind"))
+}
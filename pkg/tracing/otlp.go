@@ -0,0 +1,409 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/megaease/easegress/pkg/logger"
+)
+
+const (
+	otlpProtocolHTTP = "http"
+	otlpProtocolGRPC = "grpc"
+
+	defaultOTLPTimeout      = 10 * time.Second
+	defaultOTLPBatchSize    = 512
+	defaultOTLPBatchTimeout = 5 * time.Second
+	otlpQueueSize           = 2048
+
+	instrumentationName = "github.com/megaease/easegress"
+)
+
+type (
+	// OTLPSpec describes the OpenTelemetry protocol exporter.
+	OTLPSpec struct {
+		Protocol     string            `json:"protocol" jsonschema:"required,enum=http,enum=grpc"`
+		Endpoint     string            `json:"endpoint" jsonschema:"required"`
+		Insecure     bool              `json:"insecure" jsonschema:"omitempty"`
+		Headers      map[string]string `json:"headers" jsonschema:"omitempty"`
+		Timeout      string            `json:"timeout" jsonschema:"omitempty,format=duration"`
+		BatchSize    int               `json:"batchSize" jsonschema:"omitempty,minimum=1"`
+		BatchTimeout string            `json:"batchTimeout" jsonschema:"omitempty,format=duration"`
+	}
+
+	// otlpExporter sends spans to the collector.
+	otlpExporter interface {
+		export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error
+		close() error
+	}
+
+	otlpHTTPExporter struct {
+		url     string
+		headers map[string]string
+		client  *http.Client
+	}
+
+	otlpGRPCExporter struct {
+		conn   *grpc.ClientConn
+		client coltracepb.TraceServiceClient
+		md     metadata.MD
+	}
+
+	// otlpReporter implements the reporter of zipkin, it converts zipkin
+	// spans to OpenTelemetry spans and exports them in batches.
+	otlpReporter struct {
+		resource     *resourcepb.Resource
+		exporter     otlpExporter
+		timeout      time.Duration
+		batchSize    int
+		batchTimeout time.Duration
+
+		spans chan *model.SpanModel
+		done  chan struct{}
+		wg    sync.WaitGroup
+		once  sync.Once
+	}
+)
+
+// Validate validates OTLPSpec.
+func (spec *OTLPSpec) Validate() error {
+	switch spec.Protocol {
+	case otlpProtocolHTTP:
+		u, err := url.Parse(spec.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %v", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("endpoint must be an http or https URL")
+		}
+	case otlpProtocolGRPC:
+		if _, _, err := net.SplitHostPort(spec.Endpoint); err != nil {
+			return fmt.Errorf("endpoint must be in the format of host:port")
+		}
+	}
+
+	return nil
+}
+
+func newOTLPReporter(serviceName string, spec *OTLPSpec) (*otlpReporter, error) {
+	r := &otlpReporter{
+		timeout:      defaultOTLPTimeout,
+		batchSize:    defaultOTLPBatchSize,
+		batchTimeout: defaultOTLPBatchTimeout,
+		spans:        make(chan *model.SpanModel, otlpQueueSize),
+		done:         make(chan struct{}),
+	}
+
+	if spec.Timeout != "" {
+		r.timeout, _ = time.ParseDuration(spec.Timeout)
+	}
+	if spec.BatchSize > 0 {
+		r.batchSize = spec.BatchSize
+	}
+	if spec.BatchTimeout != "" {
+		r.batchTimeout, _ = time.ParseDuration(spec.BatchTimeout)
+	}
+
+	r.resource = &resourcepb.Resource{
+		Attributes: []*commonpb.KeyValue{stringAttribute("service.name", serviceName)},
+	}
+
+	if spec.Protocol == otlpProtocolGRPC {
+		exporter, err := newOTLPGRPCExporter(spec)
+		if err != nil {
+			return nil, err
+		}
+		r.exporter = exporter
+	} else {
+		r.exporter = newOTLPHTTPExporter(spec, r.timeout)
+	}
+
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// Send implements zipkin reporter.Reporter, spans are dropped if the
+// queue is full.
+func (r *otlpReporter) Send(s model.SpanModel) {
+	select {
+	case r.spans <- &s:
+	default:
+	}
+}
+
+// Close implements zipkin reporter.Reporter, queued spans are flushed
+// before it returns.
+func (r *otlpReporter) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+	return r.exporter.close()
+}
+
+func (r *otlpReporter) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.batchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]*model.SpanModel, 0, r.batchSize)
+	for {
+		select {
+		case s := <-r.spans:
+			batch = append(batch, s)
+			if len(batch) >= r.batchSize {
+				r.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.export(batch)
+				batch = batch[:0]
+			}
+		case <-r.done:
+			r.flush(batch)
+			return
+		}
+	}
+}
+
+// flush exports the batch and all queued spans.
+func (r *otlpReporter) flush(batch []*model.SpanModel) {
+	for {
+		select {
+		case s := <-r.spans:
+			batch = append(batch, s)
+			if len(batch) >= r.batchSize {
+				r.export(batch)
+				batch = batch[:0]
+			}
+		default:
+			if len(batch) > 0 {
+				r.export(batch)
+			}
+			return
+		}
+	}
+}
+
+func (r *otlpReporter) export(batch []*model.SpanModel) {
+	spans := make([]*tracepb.Span, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, convertSpan(s))
+	}
+
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: r.resource,
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+				InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: instrumentationName},
+				Spans:                  spans,
+			}},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if err := r.exporter.export(ctx, req); err != nil {
+		logger.Errorf("export %d spans failed: %v", len(spans), err)
+	}
+}
+
+// convertSpan converts a zipkin span to an OpenTelemetry span.
+func convertSpan(s *model.SpanModel) *tracepb.Span {
+	span := &tracepb.Span{
+		TraceId:           traceIDBytes(s.TraceID),
+		SpanId:            spanIDBytes(s.ID),
+		Name:              s.Name,
+		Kind:              spanKind(s.Kind),
+		StartTimeUnixNano: uint64(s.Timestamp.UnixNano()),
+		EndTimeUnixNano:   uint64(s.Timestamp.Add(s.Duration).UnixNano()),
+		Status:            &tracepb.Status{},
+	}
+	if s.ParentID != nil {
+		span.ParentSpanId = spanIDBytes(*s.ParentID)
+	}
+
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// zipkin marks failed spans with the "error" tag.
+		if k == "error" {
+			span.Status.Code = tracepb.Status_STATUS_CODE_ERROR
+			span.Status.Message = s.Tags[k]
+			continue
+		}
+		span.Attributes = append(span.Attributes, stringAttribute(k, s.Tags[k]))
+	}
+
+	for _, a := range s.Annotations {
+		span.Events = append(span.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(a.Timestamp.UnixNano()),
+			Name:         a.Value,
+		})
+	}
+
+	return span
+}
+
+func spanKind(kind model.Kind) tracepb.Span_SpanKind {
+	switch kind {
+	case model.Server:
+		return tracepb.Span_SPAN_KIND_SERVER
+	case model.Client:
+		return tracepb.Span_SPAN_KIND_CLIENT
+	case model.Producer:
+		return tracepb.Span_SPAN_KIND_PRODUCER
+	case model.Consumer:
+		return tracepb.Span_SPAN_KIND_CONSUMER
+	default:
+		return tracepb.Span_SPAN_KIND_INTERNAL
+	}
+}
+
+func traceIDBytes(id model.TraceID) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], id.High)
+	binary.BigEndian.PutUint64(b[8:], id.Low)
+	return b
+}
+
+func spanIDBytes(id model.ID) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func newOTLPHTTPExporter(spec *OTLPSpec, timeout time.Duration) *otlpHTTPExporter {
+	client := &http.Client{Timeout: timeout}
+
+	// insecure skips the verification of the certificate of an https
+	// endpoint, as the plain http endpoint is insecure anyway.
+	if spec.Insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+
+	return &otlpHTTPExporter{
+		url:     spec.Endpoint,
+		headers: spec.Headers,
+		client:  client,
+	}
+}
+
+func (e *otlpHTTPExporter) export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpHTTPExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func newOTLPGRPCExporter(spec *OTLPSpec) (*otlpGRPCExporter, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if spec.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	// grpc.Dial does not block, connection is established in background.
+	conn, err := grpc.Dial(spec.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &otlpGRPCExporter{
+		conn:   conn,
+		client: coltracepb.NewTraceServiceClient(conn),
+		md:     metadata.New(spec.Headers),
+	}, nil
+}
+
+func (e *otlpGRPCExporter) export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	if len(e.md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.md)
+	}
+	_, err := e.client.Export(ctx, req)
+	return err
+}
+
+func (e *otlpGRPCExporter) close() error {
+	return e.conn.Close()
+}
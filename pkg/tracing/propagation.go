@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// The names of the propagators.
+const (
+	PropagatorB3           = "b3"
+	PropagatorTraceContext = "tracecontext"
+)
+
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+type (
+	// propagator extracts span context from and injects span context
+	// into HTTP headers.
+	propagator interface {
+		// extract returns nil if there's no valid span context.
+		extract(r *http.Request) (sc *model.SpanContext, traceState string)
+		inject(r *http.Request, sc model.SpanContext, traceState string)
+	}
+
+	b3Propagator struct{}
+
+	// traceContextPropagator implements the W3C Trace Context.
+	// Reference: https://www.w3.org/TR/trace-context/
+	traceContextPropagator struct{}
+)
+
+var propagators = map[string]propagator{
+	PropagatorB3:           b3Propagator{},
+	PropagatorTraceContext: traceContextPropagator{},
+}
+
+func (p b3Propagator) extract(r *http.Request) (*model.SpanContext, string) {
+	sc, err := b3.ExtractHTTP(r)()
+	if err != nil {
+		return nil, ""
+	}
+	return sc, ""
+}
+
+func (p b3Propagator) inject(r *http.Request, sc model.SpanContext, traceState string) {
+	inject := b3.InjectHTTP(r, b3.WithSingleHeaderOnly())
+	inject(sc)
+}
+
+func (p traceContextPropagator) extract(r *http.Request) (*model.SpanContext, string) {
+	sc := parseTraceParent(r.Header.Get(traceParentHeader))
+	if sc == nil {
+		return nil, ""
+	}
+	return sc, strings.Join(r.Header.Values(traceStateHeader), ",")
+}
+
+func (p traceContextPropagator) inject(r *http.Request, sc model.SpanContext, traceState string) {
+	if sc.TraceID.Empty() || sc.ID == 0 {
+		return
+	}
+
+	r.Header.Set(traceParentHeader, formatTraceParent(sc))
+	if traceState != "" {
+		r.Header.Set(traceStateHeader, traceState)
+	} else {
+		r.Header.Del(traceStateHeader)
+	}
+}
+
+// parseTraceParent parses the traceparent header, the format is:
+// version "-" trace-id "-" parent-id "-" trace-flags.
+func parseTraceParent(v string) *model.SpanContext {
+	v = strings.TrimSpace(v)
+	if len(v) < 55 {
+		return nil
+	}
+
+	version, err := hex.DecodeString(v[:2])
+	if err != nil || version[0] == 0xff {
+		return nil
+	}
+	// future versions may append fields, but version 00 must not.
+	if (version[0] == 0 && len(v) != 55) || (len(v) > 55 && v[55] != '-') {
+		return nil
+	}
+	if v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return nil
+	}
+
+	for _, c := range v[3:52] {
+		if c >= 'A' && c <= 'F' {
+			return nil
+		}
+	}
+
+	traceID, err := model.TraceIDFromHex(v[3:35])
+	if err != nil || traceID.Empty() {
+		return nil
+	}
+	id, err := hex.DecodeString(v[36:52])
+	if err != nil {
+		return nil
+	}
+	flags, err := hex.DecodeString(v[53:55])
+	if err != nil {
+		return nil
+	}
+
+	sc := &model.SpanContext{TraceID: traceID}
+	for _, b := range id {
+		sc.ID = sc.ID<<8 | model.ID(b)
+	}
+	if sc.ID == 0 {
+		return nil
+	}
+
+	sampled := flags[0]&0x01 == 0x01
+	sc.Sampled = &sampled
+	return sc
+}
+
+// formatTraceParent formats the span context as a version 00 traceparent.
+func formatTraceParent(sc model.SpanContext) string {
+	flags := 0
+	if sc.Debug || (sc.Sampled != nil && *sc.Sampled) {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%016x%016x-%016x-%02x", sc.TraceID.High, sc.TraceID.Low, uint64(sc.ID), flags)
+}
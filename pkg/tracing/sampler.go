@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"strings"
+
+	zipkingo "github.com/openzipkin/zipkin-go"
+)
+
+// The sampler types, they are the same as the values of the
+// OTEL_TRACES_SAMPLER environment variable of OpenTelemetry SDKs, but in
+// camel case.
+const (
+	SamplerAlwaysOn                = "alwaysOn"
+	SamplerAlwaysOff               = "alwaysOff"
+	SamplerTraceIDRatio            = "traceIDRatio"
+	SamplerParentBasedAlwaysOn     = "parentBasedAlwaysOn"
+	SamplerParentBasedAlwaysOff    = "parentBasedAlwaysOff"
+	SamplerParentBasedTraceIDRatio = "parentBasedTraceIDRatio"
+)
+
+// SamplerSpec describes the sampler of new traces.
+type SamplerSpec struct {
+	Type  string  `json:"type" jsonschema:"required,enum=alwaysOn,enum=alwaysOff,enum=traceIDRatio,enum=parentBasedAlwaysOn,enum=parentBasedAlwaysOff,enum=parentBasedTraceIDRatio"`
+	Ratio float64 `json:"ratio" jsonschema:"omitempty,minimum=0,maximum=1"`
+}
+
+// parentBased returns whether the sampling decision of the remote parent
+// is respected.
+func (spec *SamplerSpec) parentBased() bool {
+	return strings.HasPrefix(spec.Type, "parentBased")
+}
+
+// rootSampler returns the sampler used when there's no remote parent, or
+// the sampler is not parent based.
+func (spec *SamplerSpec) rootSampler() zipkingo.Sampler {
+	switch spec.Type {
+	case SamplerAlwaysOff, SamplerParentBasedAlwaysOff:
+		return zipkingo.NeverSample
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		return traceIDRatioSampler(spec.Ratio)
+	default:
+		return zipkingo.AlwaysSample
+	}
+}
+
+// traceIDRatioSampler samples a trace if the lower 63 bits of its trace ID
+// is less than the bound, which is the same as the TraceIDRatioBased
+// sampler of OpenTelemetry, so that the decision is consistent with
+// other services.
+func traceIDRatioSampler(ratio float64) zipkingo.Sampler {
+	if ratio >= 1 {
+		return zipkingo.AlwaysSample
+	}
+	if ratio <= 0 {
+		return zipkingo.NeverSample
+	}
+
+	bound := uint64(ratio * (1 << 63))
+	return func(id uint64) bool {
+		return id>>1 < bound
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"time"
 
 	zipkingo "github.com/openzipkin/zipkin-go"
-	"github.com/openzipkin/zipkin-go/propagation/b3"
 
 	"github.com/megaease/easegress/pkg/util/fasttime"
 )
@@ -48,6 +47,10 @@ type (
 	span struct {
 		zipkingo.Span
 		tracer *Tracer
+
+		// traceState is the W3C tracestate of the trace, it is propagated
+		// as is.
+		traceState string
 	}
 )
 
@@ -86,13 +89,16 @@ func (s *span) newChildWithStart(name string, startAt time.Time) Span {
 		zipkingo.StartTime(startAt))
 
 	return &span{
-		tracer: s.tracer,
-		Span:   child,
+		tracer:     s.tracer,
+		Span:       child,
+		traceState: s.traceState,
 	}
 }
 
 // InjectHTTP injects span context into an HTTP request.
 func (s *span) InjectHTTP(r *http.Request) {
-	inject := b3.InjectHTTP(r, b3.WithSingleHeaderOnly())
-	inject(s.Context())
+	sc := s.Context()
+	for _, p := range s.tracer.propagators {
+		p.inject(r, sc, s.traceState)
+	}
 }
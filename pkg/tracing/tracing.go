@@ -18,12 +18,15 @@
 package tracing
 
 import (
+	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/megaease/easegress/pkg/util/fasttime"
 
 	zipkingo "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
 	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
 	zipkingohttp "github.com/openzipkin/zipkin-go/reporter/http"
 )
@@ -33,7 +36,10 @@ type (
 	Spec struct {
 		ServiceName string            `json:"serviceName" jsonschema:"required"`
 		Tags        map[string]string `json:"tags" jsonschema:"omitempty"`
-		Zipkin      *ZipkinSpec       `json:"zipkin" jsonschema:"required"`
+		Sampler     *SamplerSpec      `json:"sampler,omitempty" jsonschema:"omitempty"`
+		Propagators []string          `json:"propagators,omitempty" jsonschema:"omitempty,uniqueItems=true"`
+		Zipkin      *ZipkinSpec       `json:"zipkin,omitempty" jsonschema:"omitempty"`
+		OTLP        *OTLPSpec         `json:"otlp,omitempty" jsonschema:"omitempty"`
 	}
 
 	// ZipkinSpec describes Zipkin.
@@ -48,15 +54,33 @@ type (
 
 	// Tracer is the tracer.
 	Tracer struct {
-		tracer *zipkingo.Tracer
-		tags   map[string]string
-		closer io.Closer
+		tracer      *zipkingo.Tracer
+		tags        map[string]string
+		closer      io.Closer
+		propagators []propagator
+		extractors  []propagator
+		parentBased bool
 	}
 
 	noopCloser struct{}
 )
 
 // Validate validates Spec.
+func (spec *Spec) Validate() error {
+	if (spec.Zipkin == nil) == (spec.OTLP == nil) {
+		return fmt.Errorf("exactly one of zipkin and otlp must be specified")
+	}
+
+	for _, name := range spec.Propagators {
+		if propagators[name] == nil {
+			return fmt.Errorf("unknown propagator %s", name)
+		}
+	}
+
+	return nil
+}
+
+// Validate validates ZipkinSpec.
 func (spec *ZipkinSpec) Validate() error {
 	if spec.Hostport != "" {
 		_, err := zipkingo.NewEndpoint("", spec.Hostport)
@@ -68,12 +92,57 @@ func (spec *ZipkinSpec) Validate() error {
 	return nil
 }
 
+// sampler returns the root sampler and whether to respect the sampling
+// decision of the remote parent.
+func (spec *Spec) sampler() (zipkingo.Sampler, bool, error) {
+	if spec.Sampler != nil {
+		return spec.Sampler.rootSampler(), spec.Sampler.parentBased(), nil
+	}
+
+	if spec.OTLP != nil {
+		return zipkingo.AlwaysSample, true, nil
+	}
+
+	sampler, err := zipkingo.NewBoundarySampler(spec.Zipkin.SampleRate, fasttime.Now().Unix())
+	return sampler, true, err
+}
+
+// propagators returns the propagators, the default one is B3 for Zipkin,
+// and W3C Trace Context for OpenTelemetry.
+func (spec *Spec) propagators() []propagator {
+	names := spec.Propagators
+	if len(names) == 0 {
+		if spec.OTLP != nil {
+			names = []string{PropagatorTraceContext}
+		} else {
+			names = []string{PropagatorB3}
+		}
+	}
+
+	result := make([]propagator, 0, len(names))
+	for _, name := range names {
+		result = append(result, propagators[name])
+	}
+	return result
+}
+
+// extractors returns the propagators to extract the trace context of
+// incoming requests. For Zipkin, it is only done if the propagators are
+// specified explicitly, so that existing tracing specs keep starting new
+// traces at HTTPServers.
+func (spec *Spec) extractors() []propagator {
+	if spec.Zipkin != nil && len(spec.Propagators) == 0 {
+		return nil
+	}
+	return spec.propagators()
+}
+
 // NoopTracer is the tracer doing nothing.
 var NoopTracer *Tracer
 
 func init() {
 	tracer, _ := zipkingo.NewTracer(nil)
-	NoopTracer = &Tracer{tracer: tracer, closer: nil}
+	NoopTracer = &Tracer{tracer: tracer, closer: nil, propagators: []propagator{b3Propagator{}}}
 	NoopSpan = &span{tracer: NoopTracer, Span: NoopTracer.tracer.StartSpan("")}
 }
 
@@ -83,37 +152,57 @@ func New(spec *Spec) (*Tracer, error) {
 		return NoopTracer, nil
 	}
 
-	endpoint, err := zipkingo.NewEndpoint(spec.ServiceName, spec.Zipkin.Hostport)
+	sampler, parentBased, err := spec.sampler()
 	if err != nil {
 		return nil, err
 	}
 
-	sampler, err := zipkingo.NewBoundarySampler(spec.Zipkin.SampleRate, fasttime.Now().Unix())
+	// OpenTelemetry requires 128-bit trace IDs and does not support
+	// sharing span IDs between client and server.
+	hostport, sameSpan, id128Bit := "", false, true
+	if spec.Zipkin != nil {
+		hostport = spec.Zipkin.Hostport
+		sameSpan = spec.Zipkin.SameSpan
+		id128Bit = spec.Zipkin.ID128Bit
+	}
+
+	endpoint, err := zipkingo.NewEndpoint(spec.ServiceName, hostport)
 	if err != nil {
 		return nil, err
 	}
 
 	var reporter zipkinreporter.Reporter
-	if spec.Zipkin.DisableReport {
+	switch {
+	case spec.OTLP != nil:
+		reporter, err = newOTLPReporter(spec.ServiceName, spec.OTLP)
+		if err != nil {
+			return nil, err
+		}
+	case spec.Zipkin.DisableReport:
 		reporter = zipkinreporter.NewNoopReporter()
-	} else {
+	default:
 		reporter = zipkingohttp.NewReporter(spec.Zipkin.ServerURL)
 	}
+
 	tracer, err := zipkingo.NewTracer(
 		reporter,
 		zipkingo.WithLocalEndpoint(endpoint),
-		zipkingo.WithSharedSpans(spec.Zipkin.SameSpan),
-		zipkingo.WithTraceID128Bit(spec.Zipkin.ID128Bit),
+		zipkingo.WithSharedSpans(sameSpan),
+		zipkingo.WithTraceID128Bit(id128Bit),
 		zipkingo.WithSampler(sampler),
 		zipkingo.WithTags(spec.Tags),
 	)
 	if err != nil {
+		reporter.Close()
 		return nil, err
 	}
 
 	return &Tracer{
-		tracer: tracer,
-		closer: reporter,
+		tracer:      tracer,
+		closer:      reporter,
+		propagators: spec.propagators(),
+		extractors:  spec.extractors(),
+		parentBased: parentBased,
 	}, nil
 }
 
@@ -151,3 +240,40 @@ func (t *Tracer) newSpanWithStart(name string, startAt time.Time) Span {
 	s := t.tracer.StartSpan(name, zipkingo.StartTime(startAt))
 	return &span{Span: s, tracer: t}
 }
+
+// NewSpanForHTTP creates a server span for an HTTP request, the span is a
+// child of the span context extracted from the request headers, if any.
+func (t *Tracer) NewSpanForHTTP(r *http.Request, name string, startAt time.Time) Span {
+	if t.IsNoopTracer() {
+		return NoopSpan
+	}
+
+	opts := []zipkingo.SpanOption{
+		zipkingo.Kind(model.Server),
+		zipkingo.StartTime(startAt),
+	}
+
+	sc, traceState := t.extractHTTP(r)
+	if sc != nil {
+		// the sampler decides whether to sample the trace if it does not
+		// respect the decision of the remote parent.
+		if !t.parentBased {
+			sc.Sampled, sc.Debug = nil, false
+		}
+		opts = append(opts, zipkingo.Parent(*sc))
+	}
+
+	s := t.tracer.StartSpan(name, opts...)
+	return &span{Span: s, tracer: t, traceState: traceState}
+}
+
+// extractHTTP extracts span context from the request headers, the first
+// extractor which finds a valid span context wins.
+func (t *Tracer) extractHTTP(r *http.Request) (*model.SpanContext, string) {
+	for _, p := range t.extractors {
+		if sc, traceState := p.extract(r); sc != nil {
+			return sc, traceState
+		}
+	}
+	return nil, ""
+}
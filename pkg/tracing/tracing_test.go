@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/stretchr/testify/assert"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTraceParent(t *testing.T) {
+	assert := assert.New(t)
+
+	sc := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NotNil(sc)
+	assert.Equal(uint64(0x4bf92f3577b34da6), sc.TraceID.High)
+	assert.Equal(uint64(0xa3ce929d0e0e4736), sc.TraceID.Low)
+	assert.Equal(model.ID(0x00f067aa0ba902b7), sc.ID)
+	assert.True(*sc.Sampled)
+	assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", formatTraceParent(*sc))
+
+	sc = parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.False(*sc.Sampled)
+
+	// future versions may have more fields.
+	assert.NotNil(parseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc"))
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01",
+	} {
+		assert.Nil(parseTraceParent(v), v)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &SamplerSpec{Type: SamplerAlwaysOff}
+	assert.False(spec.parentBased())
+	assert.False(spec.rootSampler()(1))
+
+	spec = &SamplerSpec{Type: SamplerParentBasedAlwaysOn}
+	assert.True(spec.parentBased())
+	assert.True(spec.rootSampler()(1))
+
+	spec = &SamplerSpec{Type: SamplerTraceIDRatio, Ratio: 0.5}
+	sampler := spec.rootSampler()
+	assert.True(sampler(0))
+	assert.True(sampler(1<<63 - 1))
+	assert.False(sampler(1 << 63))
+	assert.False(sampler(1<<64 - 1))
+}
+
+func TestPropagation(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer, err := New(&Spec{
+		ServiceName: "test",
+		Sampler:     &SamplerSpec{Type: SamplerParentBasedAlwaysOff},
+		Propagators: []string{PropagatorTraceContext, PropagatorB3},
+		Zipkin:      &ZipkinSpec{ServerURL: "http://localhost:9411", DisableReport: true},
+	})
+	assert.NoError(err)
+	defer tracer.Close()
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "congo=t61rcWkgMzE")
+
+	span := tracer.NewSpanForHTTP(r, "server", time.Now())
+	sc := span.Context()
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(model.ID(0x00f067aa0ba902b7), *sc.ParentID)
+	assert.True(*sc.Sampled)
+
+	child := span.NewChild("client")
+	out, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	child.InjectHTTP(out)
+	assert.Equal(formatTraceParent(child.Context()), out.Header.Get("traceparent"))
+	assert.Equal("congo=t61rcWkgMzE", out.Header.Get("tracestate"))
+	assert.NotEmpty(out.Header.Get("b3"))
+
+	// the root sampler is used if there's no parent.
+	r.Header.Del("traceparent")
+	span = tracer.NewSpanForHTTP(r, "server", time.Now())
+	assert.False(*span.Context().Sampled)
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	var lock sync.Mutex
+	var spans []*tracepb.Span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal("secret", r.Header.Get("X-Api-Key"))
+
+		body, _ := io.ReadAll(r.Body)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		assert.NoError(proto.Unmarshal(body, req))
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				spans = append(spans, ils.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	spec := &Spec{
+		ServiceName: "test",
+		Tags:        map[string]string{"env": "test"},
+		OTLP: &OTLPSpec{
+			Protocol: otlpProtocolHTTP,
+			Endpoint: server.URL + "/v1/traces",
+			Headers:  map[string]string{"X-Api-Key": "secret"},
+		},
+	}
+	assert.NoError(spec.Validate())
+	assert.NoError(spec.OTLP.Validate())
+
+	tracer, err := New(spec)
+	assert.NoError(err)
+
+	span := tracer.NewSpan("root")
+	child := span.NewChild("child")
+	child.Tag("error", "timeout")
+	child.Finish()
+	span.Finish()
+
+	// close flushes the queued spans.
+	tracer.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Len(spans, 2)
+	assert.Equal("child", spans[0].Name)
+	assert.Equal(tracepb.Status_STATUS_CODE_ERROR, spans[0].Status.Code)
+	assert.Equal("timeout", spans[0].Status.Message)
+	assert.Equal(spans[1].SpanId, spans[0].ParentSpanId)
+	assert.Len(spans[0].TraceId, 16)
+}
+
+func TestOTLPHTTPInsecure(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	spec := &OTLPSpec{Protocol: otlpProtocolHTTP, Endpoint: server.URL + "/v1/traces"}
+	req := &coltracepb.ExportTraceServiceRequest{}
+
+	// the certificate of the test server is self-signed.
+	exporter := newOTLPHTTPExporter(spec, time.Second)
+	assert.Error(exporter.export(context.Background(), req))
+	exporter.close()
+
+	spec.Insecure = true
+	exporter = newOTLPHTTPExporter(spec, time.Second)
+	assert.NoError(exporter.export(context.Background(), req))
+	exporter.close()
+}
+
+func TestZipkinExtraction(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Spec{
+		ServiceName: "test",
+		Zipkin:      &ZipkinSpec{ServerURL: "http://localhost:9411", DisableReport: true, SampleRate: 1},
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	r.Header.Set("b3", "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0")
+
+	// the trace context of incoming requests is not extracted by default.
+	tracer, err := New(spec)
+	assert.NoError(err)
+	span := tracer.NewSpanForHTTP(r, "server", time.Now())
+	assert.NotEqual("4bf92f3577b34da6a3ce929d0e0e4736", span.Context().TraceID.String())
+	assert.True(*span.Context().Sampled)
+	tracer.Close()
+
+	spec.Propagators = []string{PropagatorB3}
+	tracer, err = New(spec)
+	assert.NoError(err)
+	span = tracer.NewSpanForHTTP(r, "server", time.Now())
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.Context().TraceID.String())
+	assert.False(*span.Context().Sampled)
+	tracer.Close()
+}
+
+func TestSpecValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &Spec{ServiceName: "test"}
+	assert.Error(spec.Validate())
+
+	spec.Zipkin = &ZipkinSpec{}
+	spec.OTLP = &OTLPSpec{}
+	assert.Error(spec.Validate())
+
+	spec.OTLP = nil
+	assert.NoError(spec.Validate())
+
+	spec.Propagators = []string{"unknown"}
+	assert.Error(spec.Validate())
+
+	otlp := &OTLPSpec{Protocol: otlpProtocolGRPC, Endpoint: "localhost"}
+	assert.Error(otlp.Validate())
+	otlp.Endpoint = "localhost:4317"
+	assert.NoError(otlp.Validate())
+
+	otlp = &OTLPSpec{Protocol: otlpProtocolHTTP, Endpoint: "localhost:4318"}
+	assert.Error(otlp.Validate())
+}
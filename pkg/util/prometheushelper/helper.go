@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package prometheushelper provides helper functions to create Prometheus
+// metrics. Objects and filters are re-created when their specs are
+// updated, so metrics are registered only once and shared by all
+// generations, and the series of an object are reference counted, so that
+// they are deleted only after its last generation is closed.
+package prometheushelper
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/megaease/easegress/pkg/supervisor"
+)
+
+var (
+	lock       sync.Mutex
+	counters   = map[string]*prometheus.CounterVec{}
+	gauges     = map[string]*prometheus.GaugeVec{}
+	histograms = map[string]*prometheus.HistogramVec{}
+	// refs is the reference counts of the series, keyed by labelsKey.
+	refs = map[string]int{}
+
+	// DurationBuckets is the default buckets of duration histograms, in
+	// seconds.
+	DurationBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	// SizeBuckets is the default buckets of size histograms, in bytes.
+	SizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+)
+
+// BaseLabels is the names of the labels shared by all metrics.
+var BaseLabels = []string{"clusterName", "clusterRole", "instanceName"}
+
+// Labels returns the values of BaseLabels of the member and the
+// additional label values.
+func Labels(super *supervisor.Supervisor, values ...string) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if super != nil {
+		opt := super.Options()
+		labels["clusterName"] = opt.ClusterName
+		labels["clusterRole"] = opt.ClusterRole
+		labels["instanceName"] = opt.Name
+	} else {
+		for _, name := range BaseLabels {
+			labels[name] = ""
+		}
+	}
+
+	for i := 0; i+1 < len(values); i += 2 {
+		labels[values[i]] = values[i+1]
+	}
+	return labels
+}
+
+func labelNames(labels []string) []string {
+	return append(append([]string{}, BaseLabels...), labels...)
+}
+
+// register registers the collector, the existing one is returned if a
+// collector with the same description is already registered.
+func register(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// NewCounter creates a counter vector with BaseLabels and labels, or
+// returns the existing one with the same name.
+func NewCounter(name, help string, labels []string) *prometheus.CounterVec {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if c, ok := counters[name]; ok {
+		return c
+	}
+
+	opts := prometheus.CounterOpts{Name: name, Help: help}
+	c := register(prometheus.NewCounterVec(opts, labelNames(labels))).(*prometheus.CounterVec)
+	counters[name] = c
+	return c
+}
+
+// NewGauge creates a gauge vector with BaseLabels and labels, or returns
+// the existing one with the same name.
+func NewGauge(name, help string, labels []string) *prometheus.GaugeVec {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if g, ok := gauges[name]; ok {
+		return g
+	}
+
+	opts := prometheus.GaugeOpts{Name: name, Help: help}
+	g := register(prometheus.NewGaugeVec(opts, labelNames(labels))).(*prometheus.GaugeVec)
+	gauges[name] = g
+	return g
+}
+
+// NewHistogram creates a histogram vector with BaseLabels and labels, or
+// returns the existing one with the same name.
+func NewHistogram(name, help string, buckets []float64, labels []string) *prometheus.HistogramVec {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if h, ok := histograms[name]; ok {
+		return h
+	}
+
+	opts := prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}
+	h := register(prometheus.NewHistogramVec(opts, labelNames(labels))).(*prometheus.HistogramVec)
+	histograms[name] = h
+	return h
+}
+
+// Acquire increases the reference count of the series having the labels,
+// it should be called when an object or filter creates its metrics.
+func Acquire(labels prometheus.Labels) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	refs[labelsKey(labels)]++
+}
+
+// Release decreases the reference count of the series having the labels,
+// it should be called when an object or filter is closed. All series
+// having the labels are deleted from the metrics created by this package
+// once the count drops to zero, so metrics of deleted objects are not
+// exported anymore.
+func Release(labels prometheus.Labels) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	key := labelsKey(labels)
+	if refs[key] > 1 {
+		refs[key]--
+		return
+	}
+	delete(refs, key)
+
+	for _, c := range counters {
+		deletePartialMatch(c, labels)
+	}
+	for _, g := range gauges {
+		deletePartialMatch(g, labels)
+	}
+	for _, h := range histograms {
+		deletePartialMatch(h, labels)
+	}
+}
+
+func labelsKey(labels prometheus.Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// deletePartialMatch deletes the series of the vector whose labels contain
+// all the labels. The client library in use has no DeletePartialMatch, so
+// the series are collected and matched one by one.
+func deletePartialMatch(vec interface {
+	prometheus.Collector
+	Delete(prometheus.Labels) bool
+}, labels prometheus.Labels) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+
+	// series are deleted after the collection, as the vector is locked
+	// during it.
+	var matched []prometheus.Labels
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			continue
+		}
+
+		series := prometheus.Labels{}
+		for _, lp := range pb.GetLabel() {
+			series[lp.GetName()] = lp.GetValue()
+		}
+
+		match := true
+		for k, v := range labels {
+			if sv, ok := series[k]; !ok || sv != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			matched = append(matched, series)
+		}
+	}
+
+	for _, series := range matched {
+		vec.Delete(series)
+	}
+}
+
+// Handler returns the HTTP handler which exports all metrics in the
+// Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
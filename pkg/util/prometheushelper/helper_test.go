@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2017, MegaEase
+ * All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheushelper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	labels := Labels(nil, "pipelineName", "pipeline-demo")
+	assert.Len(labels, 4)
+	assert.Equal("", labels["clusterName"])
+	assert.Equal("pipeline-demo", labels["pipelineName"])
+}
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	c1 := NewCounter("test_counter_total", "test counter", []string{"name"})
+	c2 := NewCounter("test_counter_total", "test counter", []string{"name"})
+	assert.Same(c1, c2)
+	c1.With(Labels(nil, "name", "foo")).Add(3)
+
+	g := NewGauge("test_gauge", "test gauge", []string{"name"})
+	g.With(Labels(nil, "name", "foo")).Set(5)
+
+	h := NewHistogram("test_duration_seconds", "test histogram", DurationBuckets, []string{"name"})
+	h.With(Labels(nil, "name", "foo")).Observe(0.2)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.True(strings.Contains(body, `test_counter_total{clusterName="",clusterRole="",instanceName="",name="foo"} 3`))
+	assert.True(strings.Contains(body, `test_gauge{clusterName="",clusterRole="",instanceName="",name="foo"} 5`))
+	assert.True(strings.Contains(body, `test_duration_seconds_count{clusterName="",clusterRole="",instanceName="",name="foo"} 1`))
+}
+
+func TestRelease(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCounter("test_release_total", "test release", []string{"name", "code"})
+	foo := Labels(nil, "name", "release-foo")
+	Acquire(foo)
+	Acquire(foo)
+	c.MustCurryWith(foo).WithLabelValues("200").Inc()
+	c.MustCurryWith(foo).WithLabelValues("500").Inc()
+	c.With(Labels(nil, "name", "release-bar", "code", "200")).Inc()
+
+	count := func(name string) int {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		return strings.Count(w.Body.String(), `name="`+name+`"`)
+	}
+	assert.Equal(2, count("release-foo"))
+
+	// the series are deleted after the last release.
+	Release(foo)
+	assert.Equal(2, count("release-foo"))
+	Release(foo)
+	assert.Equal(0, count("release-foo"))
+	assert.Equal(1, count("release-bar"))
+}
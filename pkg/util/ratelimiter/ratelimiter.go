@@ -115,6 +115,13 @@ func (rl *RateLimiter) SetStateListener(listener EventListenerFunc) {
 	rl.listener = listener
 }
 
+// State returns the current state of the rate limiter.
+func (rl *RateLimiter) State() State {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	return rl.state
+}
+
 func (rl *RateLimiter) notifyListener(tm time.Time, state State) {
 	if rl.listener != nil {
 		event := Event{
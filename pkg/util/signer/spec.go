@@ -29,7 +29,6 @@ type Spec struct {
 	AccessKeyID     string            `json:"accessKeyId" json:"accessKeyId" jsonschema:"omitempty"`
 	AccessKeySecret string            `json:"accessKeySecret" json:"accessKeySecret" jsonschema:"omitempty"`
 	AccessKeys      map[string]string `json:"accessKeys" json:"accessKeys" jsonschema:"omitempty"`
-	// TODO: AccessKeys is used as an internal access key store, but an external store is also needed
 }
 
 type idSecretMap map[string]string